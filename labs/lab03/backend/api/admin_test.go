@@ -0,0 +1,65 @@
+package api
+
+import (
+	"lab03-backend/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminSnapshots(t *testing.T) {
+	handler := setupTestHandler()
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	handler.SetSnapshotter(storage.NewSnapshotter(handler.storage, path))
+	handler.SetAdminToken("secret")
+	router := handler.SetupRoutes()
+	handler.storage.Create("alice", "hello")
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/api/admin/snapshots", ""); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected status %v with a challenge, got %v", http.StatusUnauthorized, rr.Code)
+	}
+	if rr := do("POST", "/api/admin/snapshots", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %v for a wrong token, got %v", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr := do("POST", "/api/admin/snapshots", "secret"); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	restored := storage.NewMemoryStorage()
+	if err := restored.LoadSnapshotFile(path); err != nil || restored.Count() != 1 {
+		t.Errorf("Expected saved snapshot with 1 message, got %d, %v", restored.Count(), err)
+	}
+
+	rr := do("GET", "/api/admin/snapshot", "secret")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Disposition") == "" {
+		t.Fatalf("Expected snapshot download, got status %v", rr.Code)
+	}
+	restored = storage.NewMemoryStorage()
+	if err := restored.LoadSnapshot(rr.Body); err != nil || restored.Count() != 1 {
+		t.Errorf("Expected downloaded snapshot to load, got %d messages, %v", restored.Count(), err)
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/api/admin/snapshot", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %v, got %v", http.StatusServiceUnavailable, rr.Code)
+	}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"lab03-backend/blobstore"
+	"lab03-backend/models"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxAttachmentBytes caps the size of one uploaded file
+const DefaultMaxAttachmentBytes = 10 << 20
+
+// multipartOverhead allows for part headers and boundaries around the file
+const multipartOverhead = 64 << 10
+
+// sniffLen is how much content http.DetectContentType looks at
+const sniffLen = 512
+
+// maxFilenameBytes bounds stored file names
+const maxFilenameBytes = 255
+
+// allowedAttachmentTypes lists the sniffed media types users may upload.
+// Types a browser could execute, such as HTML and SVG, are deliberately
+// missing.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":                 true,
+	"image/jpeg":                true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"application/pdf":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+// Attachment errors
+var (
+	ErrAttachmentsDisabled = errors.New("attachments are not enabled on this server")
+	ErrInvalidMultipart    = errors.New("malformed multipart body")
+	ErrMissingFile         = errors.New(`multipart body must include a non-empty "file" part`)
+)
+
+// SetAttachmentStore enables attachments, keeping uploaded files of up to
+// maxBytes each in blobs. Call it before SetupRoutes.
+func (h *Handler) SetAttachmentStore(blobs *blobstore.Store, maxBytes int64) {
+	h.blobs = blobs
+	h.maxAttachmentBytes = maxBytes
+}
+
+// UploadAttachment handles POST /api/messages/{id}/attachments
+//
+// The body is multipart/form-data with the file in a part named "file". The
+// file's type is sniffed from its content; the client's claimed type is
+// ignored.
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.blobs == nil {
+		h.writeError(w, r, ErrAttachmentsDisabled)
+		return
+	}
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	// Fail before reading a large body for a message that does not exist
+	if _, err := h.storage.GetByID(id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		h.writeError(w, r, fmt.Errorf("%w: content type must be multipart/form-data", ErrUnsupportedMediaType))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxAttachmentBytes+multipartOverhead)
+
+	part, err := filePart(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	defer part.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		h.writeError(w, r, uploadError(err))
+		return
+	}
+	if n == 0 {
+		h.writeError(w, r, ErrMissingFile)
+		return
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !allowedAttachmentTypes[contentType] {
+		h.writeError(w, r, fmt.Errorf("%w: %s files are not allowed", ErrUnsupportedMediaType, contentType))
+		return
+	}
+
+	blob, err := h.blobs.Put(io.MultiReader(bytes.NewReader(head), part), h.maxAttachmentBytes)
+	if err != nil {
+		h.writeError(w, r, uploadError(err))
+		return
+	}
+
+	attachment, err := h.storage.AddAttachment(id, models.Attachment{
+		Filename:    sanitizeFilename(part.FileName()),
+		ContentType: contentType,
+		Size:        blob.Size,
+		SHA256:      blob.Digest,
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	withAttachmentURL(attachment)
+	w.Header().Set("Location", attachment.URL)
+	h.writeJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: attachment})
+}
+
+// GetAttachments handles GET /api/messages/{id}/attachments
+func (h *Handler) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	attachments, err := h.storage.Attachments(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	for i := range attachments {
+		withAttachmentURL(&attachments[i])
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: attachments})
+}
+
+// DownloadAttachment handles GET /api/attachments/{id}
+//
+// Range, If-Range and If-None-Match requests are supported. Images are
+// served inline; everything else downloads as a file.
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.blobs == nil {
+		h.writeError(w, r, ErrAttachmentsDisabled)
+		return
+	}
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	attachment, err := h.storage.Attachment(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	file, err := h.blobs.Open(attachment.SHA256)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	// An attachment's content never changes
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", attachment.CreatedAt, file)
+}
+
+// filePart returns the multipart part named "file", skipping any others
+func filePart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrInvalidMultipart
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, ErrMissingFile
+		}
+		if err != nil {
+			return nil, uploadError(err)
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// uploadError maps failures while reading an upload to API errors
+func uploadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesError), errors.Is(err, blobstore.ErrTooLarge):
+		return ErrBodyTooLarge
+	case errors.Is(err, io.ErrUnexpectedEOF), strings.HasPrefix(err.Error(), "multipart:"):
+		return ErrInvalidMultipart
+	default:
+		return err
+	}
+}
+
+// sanitizeFilename keeps the base name of a client-supplied file name,
+// without control characters, and bounded in length
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, name)
+	for len(name) > maxFilenameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// withAttachmentURL fills in where an attachment can be downloaded
+func withAttachmentURL(attachment *models.Attachment) {
+	attachment.URL = apiPrefix + "/attachments/" + strconv.Itoa(attachment.ID)
+}
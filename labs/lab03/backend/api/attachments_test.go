@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lab03-backend/blobstore"
+	"lab03-backend/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func uploadRequest(t *testing.T, path, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("note", "ignored")
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestAttachments(t *testing.T) {
+	blobs, err := blobstore.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := setupTestHandler()
+	handler.SetAttachmentStore(blobs, 64)
+	router := handler.SetupRoutes()
+	handler.storage.Create("alice", "look at this")
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	content := append(append([]byte{}, pngHeader...), "0123456789"...)
+	rr := serve(uploadRequest(t, "/api/messages/1/attachments", `C:\photos\cat.png`, content))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Data models.Attachment `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Data.ContentType != "image/png" || created.Data.Filename != "cat.png" || created.Data.Size != int64(len(content)) {
+		t.Errorf("Unexpected attachment %+v", created.Data)
+	}
+	if rr.Header().Get("Location") != created.Data.URL {
+		t.Errorf("Expected Location %q, got %q", created.Data.URL, rr.Header().Get("Location"))
+	}
+
+	rr = serve(httptest.NewRequest("GET", created.Data.URL, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Errorf("Expected full PNG download, got status %v type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	ranged := httptest.NewRequest("GET", created.Data.URL, nil)
+	ranged.Header.Set("Range", "bytes=0-3")
+	rr = serve(ranged)
+	if rr.Code != http.StatusPartialContent || !bytes.Equal(rr.Body.Bytes(), content[:4]) {
+		t.Errorf("Expected first 4 bytes with status %v, got %v %q", http.StatusPartialContent, rr.Code, rr.Body.Bytes())
+	}
+	if got, want := rr.Header().Get("Content-Range"), fmt.Sprintf("bytes 0-3/%d", len(content)); got != want {
+		t.Errorf("Expected Content-Range %q, got %q", want, got)
+	}
+
+	rr = serve(httptest.NewRequest("GET", "/api/messages/1/attachments", nil))
+	var listed struct {
+		Data []models.Attachment `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if len(listed.Data) != 1 || listed.Data[0].URL == "" {
+		t.Errorf("Expected 1 attachment with a URL, got %+v", listed.Data)
+	}
+
+	if rr := serve(uploadRequest(t, "/api/messages/1/attachments", "page.png", []byte("<html><script>"))); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status %v for HTML disguised as PNG, got %v", http.StatusUnsupportedMediaType, rr.Code)
+	}
+	if rr := serve(uploadRequest(t, "/api/messages/1/attachments", "big.txt", bytes.Repeat([]byte("a"), 65))); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %v for oversized file, got %v", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+	if rr := serve(uploadRequest(t, "/api/messages/1/attachments", "empty.txt", nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v for empty file, got %v", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serve(uploadRequest(t, "/api/messages/9/attachments", "cat.png", content)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for missing message, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	handler.storage.Delete(1)
+	if rr := serve(httptest.NewRequest("GET", created.Data.URL, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected attachments of deleted messages to be hidden, got %v", rr.Code)
+	}
+}
+
+func TestAttachmentsDisabled(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	handler.storage.Create("alice", "hello")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/api/messages/1/attachments", "a.txt", []byte("hi")))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %v, got %v", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"cat.png":             "cat.png",
+		"../../etc/passwd":    "passwd",
+		`C:\Users\me\a.pdf`:   "a.pdf",
+		"bad\x00\nname.txt":   "badname.txt",
+		"":                    "attachment",
+		"/":                   "attachment",
+		"kuva ääkkösillä.jpg": "kuva ääkkösillä.jpg",
+	}
+	for input, want := range cases {
+		if got := sanitizeFilename(input); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"net/http"
+	"strings"
+)
+
+// messageETag returns the strong entity tag for one version of a message
+func messageETag(message *models.Message) string {
+	return fmt.Sprintf(`"%d-%d"`, message.ID, message.Version)
+}
+
+// pageETag returns a weak entity tag that changes whenever any message on
+// the page, or the page boundaries, change
+func pageETag(page storage.Page) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d:%t", page.Total, page.HasMore)
+	for _, message := range page.Messages {
+		fmt.Fprintf(hash, ";%d-%d", message.ID, message.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// etagMatches reports whether etag is listed in an If-Match or If-None-Match
+// header value. Strong comparison (used for If-Match) never matches weak tags.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if candidate == etag && !strings.HasPrefix(candidate, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates the If-Match precondition for message id. It returns
+// the version the caller must hold when mutating, or false after writing an
+// error response.
+func (h *Handler) checkIfMatch(w http.ResponseWriter, r *http.Request, id int) (int, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return storage.AnyVersion, true
+	}
+
+	current, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return 0, false
+	}
+	if !etagMatches(ifMatch, messageETag(current), false) {
+		w.Header().Set("ETag", messageETag(current))
+		h.writeError(w, r, storage.ErrVersionMismatch)
+		return 0, false
+	}
+	return current.Version, true
+}
+
+// notModified writes a 304 and returns true if If-None-Match matches etag
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !etagMatches(ifNoneMatch, etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -1,153 +1,441 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
+	"lab03-backend/blobstore"
+	"lab03-backend/httpcat"
+	"lab03-backend/idempotency"
+	"lab03-backend/models"
+	"lab03-backend/moderation"
+	"lab03-backend/openapi"
+	"lab03-backend/ratelimit"
 	"lab03-backend/storage"
+	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // Handler holds the storage instance
 type Handler struct {
-	// TODO: Add storage field of type *storage.MemoryStorage
+	storage   *storage.MemoryStorage
+	heartbeat time.Duration
+	spec      *openapi.Document
+
+	readLimiter  *ratelimit.Limiter
+	writeLimiter *ratelimit.Limiter
+	rateLimitKey RateLimitKey
+
+	idempotency *idempotency.Store
+
+	blobs              *blobstore.Store // nil disables attachments
+	maxAttachmentBytes int64
+
+	adminToken  string               // Empty disables the admin endpoints
+	snapshotter *storage.Snapshotter // nil disables on-demand snapshots
+
+	statusImages *httpcat.Mirror
+	baseURL      string // Prefix of absolute links; empty makes them relative
+
+	moderation *moderation.Chain // nil disables moderation
+
+	logger *slog.Logger // nil disables request logging
 }
 
 // NewHandler creates a new handler instance
 func NewHandler(storage *storage.MemoryStorage) *Handler {
-	// TODO: Return a new Handler instance with provided storage
-	return nil
+	return &Handler{
+		storage:   storage,
+		heartbeat: defaultHeartbeatInterval,
+
+		readLimiter:  ratelimit.New(DefaultReadLimit),
+		writeLimiter: ratelimit.New(DefaultWriteLimit),
+		rateLimitKey: KeyByIP,
+
+		idempotency: idempotency.New(DefaultIdempotencyTTL),
+
+		maxAttachmentBytes: DefaultMaxAttachmentBytes,
+
+		// Placeholders only until SetStatusImages configures a real mirror
+		statusImages: httpcat.New("", ""),
+
+		moderation: moderation.Default(),
+
+		logger: slog.Default(),
+	}
 }
 
 // SetupRoutes configures all API routes
 func (h *Handler) SetupRoutes() *mux.Router {
-	// TODO: Create a new mux router
-	// TODO: Add CORS middleware
-	// TODO: Create API v1 subrouter with prefix "/api"
-	// TODO: Add the following routes:
-	// GET /messages -> h.GetMessages
-	// POST /messages -> h.CreateMessage
-	// PUT /messages/{id} -> h.UpdateMessage
-	// DELETE /messages/{id} -> h.DeleteMessage
-	// GET /status/{code} -> h.GetHTTPStatus
-	// GET /health -> h.HealthCheck
-	// TODO: Return the router
-	return nil
+	router := mux.NewRouter()
+	// Requests that match no route are logged too
+	router.NotFoundHandler = h.observe(http.NotFoundHandler())
+	router.MethodNotAllowedHandler = h.observe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	router.Use(h.observe)
+	router.Use(corsMiddleware)
+	// Give preflight requests a matching route so the CORS middleware runs for them
+	router.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	routes := h.routes()
+	h.spec = buildSpec(routes)
+
+	api := router.PathPrefix(apiPrefix).Subrouter()
+	api.Use(h.rateLimitMiddleware)
+	for _, rt := range routes {
+		handler := rt.Handler
+		if rt.Body != nil && bodyType(rt) == "application/json" {
+			operation := (*h.spec.Paths[apiPrefix+rt.Path])[strings.ToLower(rt.Method)]
+			schema := operation.RequestBody.Content[bodyType(rt)].Schema
+			handler = h.validateBody(schema, bodyType(rt))(handler)
+		}
+		if rt.Body != nil {
+			handler = limitBody(handler)
+		}
+		if rt.Admin {
+			handler = h.requireAdmin(handler)
+		}
+		api.HandleFunc(rt.Path, handler).Methods(rt.Method)
+	}
+
+	return router
 }
 
 // GetMessages handles GET /api/messages
+//
+// Supported query parameters:
+//   - limit: page size (1-100, default 50)
+//   - cursor: opaque position returned as next_cursor by a previous page
+//   - offset: number of messages to skip (cannot be combined with cursor)
+//   - username: only messages from this user
+//   - since: only messages at or after this RFC 3339 time
+//   - sort: "timestamp" (oldest first, default) or "-timestamp" (newest first)
 func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetMessages handler
-	// Get all messages from storage
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle any errors appropriately
+	opts, err := parseListOptions(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	page := h.storage.List(opts)
+
+	meta := &models.PageMeta{
+		Total:  page.Total,
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}
+	if page.HasMore {
+		next := r.URL.Query()
+		if opts.After == nil && opts.Offset > 0 {
+			next.Set("offset", strconv.Itoa(opts.Offset+len(page.Messages)))
+		} else {
+			last := page.Messages[len(page.Messages)-1]
+			meta.NextCursor = encodeCursor(storage.Cursor{Timestamp: last.Timestamp, ID: last.ID})
+			// offset=0 may have started the listing, and offset cannot
+			// be combined with a cursor
+			next.Del("offset")
+			next.Set("cursor", meta.NextCursor)
+		}
+		meta.Next = r.URL.Path + "?" + next.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", meta.Next))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if notModified(w, r, pageETag(page)) {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    page.Messages,
+		Meta:    meta,
+	})
+}
+
+// GetMessage handles GET /api/messages/{id}
+func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	message, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, messageETag(message)) {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: message})
 }
 
 // CreateMessage handles POST /api/messages
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement CreateMessage handler
-	// Parse JSON request body into CreateMessageRequest
-	// Validate the request
-	// Create message in storage
-	// Create successful API response
-	// Write JSON response with status 201
-	// Handle validation and storage errors appropriately
+	var req models.CreateMessageRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, r, ErrInvalidJSON)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	key, err := idempotencyKey(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if key == "" {
+		message, err := h.create(req)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		h.writeCreated(w, message)
+		return
+	}
+
+	// Retries with the same key replay the first result; concurrent
+	// duplicates wait for it, so only one message is ever created. Keys
+	// are per client, so clients that pick the same key never see each
+	// other's messages.
+	claim, result, err := h.idempotency.Begin(r.Context(), idempotencyScope(r)+" "+key, requestFingerprint(req))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if claim == nil {
+		w.Header().Set("Idempotent-Replayed", "true")
+		h.writeCreated(w, result.(*models.Message))
+		return
+	}
+
+	var message *models.Message
+	defer func() {
+		// Also runs if create panics, so duplicates are not left waiting
+		// on a claim nobody will finish
+		if message == nil {
+			claim.Release()
+		}
+	}()
+	message, err = h.create(req)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	claim.Complete(message)
+	h.writeCreated(w, message)
+}
+
+// create moderates and stores the message described by req, as a reply if
+// it has a parent
+func (h *Handler) create(req models.CreateMessageRequest) (*models.Message, error) {
+	content, reasons, err := h.moderate(req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var message *models.Message
+	if req.ParentID != nil {
+		message, err = h.storage.Reply(*req.ParentID, req.Username, content)
+	} else {
+		message, err = h.storage.Create(req.Username, content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.flag(message, reasons)
+	return message, nil
+}
+
+// writeCreated writes the 201 response for a new message
+func (h *Handler) writeCreated(w http.ResponseWriter, message *models.Message) {
+	w.Header().Set("ETag", messageETag(message))
+	h.writeJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: message})
 }
 
 // UpdateMessage handles PUT /api/messages/{id}
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement UpdateMessage handler
-	// Extract ID from URL path variables
-	// Parse JSON request body into UpdateMessageRequest
-	// Validate the request
-	// Update message in storage
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle validation, parsing, and storage errors appropriately
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req models.UpdateMessageRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, r, ErrInvalidJSON)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	content, reasons, err := h.moderate(req.Content)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	expected, ok := h.checkIfMatch(w, r, id)
+	if !ok {
+		return
+	}
+
+	message, err := h.storage.Edit(id, storage.MessageEdit{
+		Content:  &content,
+		EditedBy: requestUser(r),
+	}, expected)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.flag(message, reasons)
+
+	w.Header().Set("ETag", messageETag(message))
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: message})
 }
 
 // DeleteMessage handles DELETE /api/messages/{id}
 func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement DeleteMessage handler
-	// Extract ID from URL path variables
-	// Delete message from storage
-	// Write response with status 204 (No Content)
-	// Handle parsing and storage errors appropriately
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	expected, ok := h.checkIfMatch(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteIfVersion(id, expected); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRevisions handles GET /api/messages/{id}/revisions
+func (h *Handler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	revisions, err := h.storage.Revisions(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: revisions})
+}
+
+// RestoreMessage handles POST /api/messages/{id}/restore
+func (h *Handler) RestoreMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	message, err := h.storage.Restore(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", messageETag(message))
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: message})
 }
 
 // GetHTTPStatus handles GET /api/status/{code}
 func (h *Handler) GetHTTPStatus(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetHTTPStatus handler
-	// Extract status code from URL path variables
-	// Validate status code (must be between 100-599)
-	// Create HTTPStatusResponse with:
-	//   - StatusCode: parsed code
-	//   - ImageURL: "https://http.cat/{code}"
-	//   - Description: HTTP status description
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle parsing and validation errors appropriately
+	code, err := parseStatusCode(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.HTTPStatusResponse{
+			StatusCode:  code,
+			ImageURL:    h.statusImageURL(code),
+			Description: getHTTPStatusDescription(code),
+		},
+	})
 }
 
 // HealthCheck handles GET /api/health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement HealthCheck handler
-	// Create a simple health check response with:
-	//   - status: "ok"
-	//   - message: "API is running"
-	//   - timestamp: current time
-	//   - total_messages: count from storage
-	// Write JSON response with status 200
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "ok",
+		"message":        "API is running",
+		"timestamp":      time.Now(),
+		"total_messages": h.storage.Count(),
+	})
 }
 
 // Helper function to write JSON responses
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	// TODO: Implement writeJSON helper
-	// Set Content-Type header to "application/json"
-	// Set status code
-	// Encode data as JSON and write to response
-	// Log any encoding errors
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	h.encode(w, data)
 }
 
-// Helper function to write error responses
-func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
-	// TODO: Implement writeError helper
-	// Create APIResponse with Success: false and Error: message
-	// Use writeJSON to send the error response
+// Helper function to encode a response body after the header is written
+func (h *Handler) encode(w http.ResponseWriter, data interface{}) {
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
 }
 
 // Helper function to parse JSON request body
 func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
-	// TODO: Implement parseJSON helper
-	// Create JSON decoder from request body
-	// Decode into destination interface
-	// Return any decoding errors
-	return nil
+	return json.NewDecoder(r.Body).Decode(dst)
 }
 
-// Helper function to get HTTP status description
-func getHTTPStatusDescription(code int) string {
-	// TODO: Implement getHTTPStatusDescription
-	// Return appropriate description for common HTTP status codes
-	// Use a switch statement or map to handle:
-	// 200: "OK", 201: "Created", 204: "No Content"
-	// 400: "Bad Request", 401: "Unauthorized", 404: "Not Found"
-	// 500: "Internal Server Error", etc.
-	// Return "Unknown Status" for unrecognized codes
-	return "Unknown Status"
+// Helper function to identify the user making the request. Clients send it
+// in the X-Username header; it is empty for anonymous requests.
+func requestUser(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-Username"))
+}
+
+// Helper function to parse the {id} path variable
+func parseID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, storage.ErrInvalidID
+	}
+	return id, nil
 }
 
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
-	// TODO: Implement CORS middleware
-	// Set the following headers:
-	// Access-Control-Allow-Origin: *
-	// Access-Control-Allow-Methods: GET, POST, PUT, DELETE, OPTIONS
-	// Access-Control-Allow-Headers: Content-Type, Authorization
-	// Handle OPTIONS preflight requests
-	// Call next handler for non-OPTIONS requests
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Implement CORS logic here
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, Last-Event-ID, X-Username, X-Request-ID, Idempotency-Key, Range")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Link, Location, X-Request-ID, Content-Disposition, Content-Range, X-Total-Count, Idempotent-Replayed, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
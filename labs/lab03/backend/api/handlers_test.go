@@ -12,7 +12,10 @@ import (
 
 func setupTestHandler() *Handler {
 	storage := storage.NewMemoryStorage()
-	return NewHandler(storage)
+	handler := NewHandler(storage)
+	// Tests that check request logs set their own logger
+	handler.SetLogger(nil)
+	return handler
 }
 
 func TestGetMessages(t *testing.T) {
@@ -187,6 +190,81 @@ func TestGetHTTPStatus(t *testing.T) {
 	}
 }
 
+func TestGetHTTPStatusDescriptions(t *testing.T) {
+	cases := map[int]string{
+		103: "Early Hints",
+		226: "IM Used",
+		308: "Permanent Redirect",
+		418: "I'm a teapot",
+		422: "Unprocessable Content",
+		451: "Unavailable For Legal Reasons",
+		511: "Network Authentication Required",
+		299: "Unknown Status",
+	}
+	for code, want := range cases {
+		if got := getHTTPStatusDescription(code); got != want {
+			t.Errorf("getHTTPStatusDescription(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestGetHTTPStatusImage(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	imageURL := func() string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/status/404", nil)
+		req.Host = "attacker.example"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response struct {
+			Data models.HTTPStatusResponse `json:"data"`
+		}
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response.Data.ImageURL
+	}
+	// The Host header never makes it into links
+	if want := "/api/cat/404"; imageURL() != want {
+		t.Errorf("Expected relative image URL %q, got %q", want, imageURL())
+	}
+	if err := handler.SetBaseURL("https://chat.example.com/"); err != nil {
+		t.Fatalf("SetBaseURL failed: %v", err)
+	}
+	if want := "https://chat.example.com/api/cat/404"; imageURL() != want {
+		t.Errorf("Expected image URL %q, got %q", want, imageURL())
+	}
+	for _, base := range []string{"chat.example.com", "ftp://chat.example.com", "https://"} {
+		if err := handler.SetBaseURL(base); err != ErrInvalidBaseURL {
+			t.Errorf("Expected ErrInvalidBaseURL for %q, got %v", base, err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/cat/404", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected PNG placeholder, got status %v type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Cache-Control") == "" {
+		t.Error("Expected ETag and Cache-Control headers")
+	}
+
+	req := httptest.NewRequest("GET", "/api/cat/404", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %v for matching ETag, got %v", http.StatusNotModified, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/cat/700", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v for invalid code, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
 func TestHealthCheck(t *testing.T) {
 	handler := setupTestHandler()
 	router := handler.SetupRoutes()
@@ -209,3 +287,178 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected Content-Type application/json, got %s", contentType)
 	}
 }
+
+func TestGetMessagesPagination(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	for i := 0; i < 5; i++ {
+		if _, err := handler.storage.Create("testuser", "message"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		start   string
+		skipped int
+	}{
+		{"/api/messages?limit=2", 0},
+		{"/api/messages?offset=0&limit=2", 0},
+		{"/api/messages?offset=1&limit=2", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.start, func(t *testing.T) {
+			pageThrough(t, router, tt.start, tt.skipped)
+		})
+	}
+}
+
+// pageThrough follows next links from start, which skips the first skipped
+// messages, and checks it sees the rest of the five in order
+func pageThrough(t *testing.T, router http.Handler, start string, skipped int) {
+	t.Helper()
+	seen := skipped
+	next := start
+	for next != "" {
+		req, _ := http.NewRequest("GET", next, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+		}
+
+		var response struct {
+			Data []models.Message `json:"data"`
+			Meta models.PageMeta  `json:"meta"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if response.Meta.Total != 5 {
+			t.Errorf("Expected total 5, got %d", response.Meta.Total)
+		}
+		for _, message := range response.Data {
+			seen++
+			if message.ID != seen {
+				t.Errorf("Expected message %d, got %d", seen, message.ID)
+			}
+		}
+		next = response.Meta.Next
+	}
+
+	if seen != 5 {
+		t.Errorf("Expected to page through to message 5, got to %d", seen)
+	}
+}
+
+func TestGetMessagesInvalidQuery(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	for _, query := range []string{"limit=0", "limit=abc", "offset=-1", "cursor=bm9wZQ", "cursor=abc&offset=1", "since=yesterday", "sort=name"} {
+		t.Run(query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/messages?"+query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %v, got %v", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	handler.storage.Create("testuser", "original")
+
+	req, _ := http.NewRequest("GET", "/api/messages/1", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with ETag, got %v %q", rr.Code, etag)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/messages/1", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %v for unchanged read, got %v", http.StatusNotModified, rr.Code)
+	}
+
+	update := func(ifMatch string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.UpdateMessageRequest{Content: "edited"})
+		req, _ := http.NewRequest("PUT", "/api/messages/1", bytes.NewBuffer(body))
+		req.Header.Set("If-Match", ifMatch)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = update(etag)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v for matching If-Match, got %v", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to change after update")
+	}
+
+	if rr = update(etag); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %v for stale If-Match, got %v", http.StatusPreconditionFailed, rr.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/api/messages/1", nil)
+	req.Header.Set("If-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %v for stale delete, got %v", http.StatusPreconditionFailed, rr.Code)
+	}
+}
+
+func TestRevisionsAndRestore(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	handler.storage.Create("testuser", "original")
+	handler.storage.Update(1, "edited")
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("GET", "/api/messages/1/revisions")
+	var response struct {
+		Data []models.Revision `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if rr.Code != http.StatusOK || len(response.Data) != 2 {
+		t.Fatalf("Expected 2 revisions, got status %v with %d", rr.Code, len(response.Data))
+	}
+
+	if rr := do("POST", "/api/messages/1/restore"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %v restoring a live message, got %v", http.StatusConflict, rr.Code)
+	}
+	if rr := do("DELETE", "/api/messages/1"); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %v, got %v", http.StatusNoContent, rr.Code)
+	}
+	if rr := do("GET", "/api/messages/1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for deleted message, got %v", http.StatusNotFound, rr.Code)
+	}
+	if rr := do("POST", "/api/messages/1/restore"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %v restoring a deleted message, got %v", http.StatusOK, rr.Code)
+	}
+	if rr := do("GET", "/api/messages/1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %v for restored message, got %v", http.StatusOK, rr.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long the result of an Idempotency-Key is kept
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// ErrInvalidIdempotencyKey is returned for keys that are too long or contain
+// non-printable characters
+var ErrInvalidIdempotencyKey = errors.New("idempotency key must be 1-255 printable ASCII characters")
+
+// idempotencyKey reads the Idempotency-Key header; it is empty when absent
+func idempotencyKey(r *http.Request) (string, error) {
+	values, ok := r.Header["Idempotency-Key"]
+	if !ok {
+		return "", nil
+	}
+	key := values[0]
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return "", ErrInvalidIdempotencyKey
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return "", ErrInvalidIdempotencyKey
+		}
+	}
+	return key, nil
+}
+
+// idempotencyScope names the client whose keys a request belongs to: the
+// X-Username user, or the client IP for anonymous requests. A user keeps
+// their keys when a retry comes from another network.
+func idempotencyScope(r *http.Request) string {
+	return KeyByUser(r)
+}
+
+// requestFingerprint identifies a request body independently of JSON
+// formatting, so a retry only has to send the same values
+func requestFingerprint(req interface{}) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/moderation"
+	"lab03-backend/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func postWithKey(router *mux.Router, key, body string) *httptest.ResponseRecorder {
+	return postAs(router, "", key, body)
+}
+
+// postAs creates a message as the X-Username user, or anonymously
+func postAs(router *mux.Router, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if user != "" {
+		req.Header.Set("X-Username", user)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateMessageIdempotencyKey(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	first := postWithKey(router, "abc", `{"username":"alice","content":"hi"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", first.Code)
+	}
+
+	replay := postWithKey(router, "abc", `{ "content": "hi", "username": "alice" }`)
+	if replay.Code != http.StatusCreated {
+		t.Fatalf("expected replay to return 201, got %d", replay.Code)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on replay")
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("expected original response, got %s", replay.Body.String())
+	}
+	if count := handler.storage.Count(); count != 1 {
+		t.Errorf("expected 1 message, got %d", count)
+	}
+
+	reused := postWithKey(router, "abc", `{"username":"alice","content":"changed"}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a different body, got %d", reused.Code)
+	}
+
+	invalid := postWithKey(router, "bad\x01key", `{"username":"alice","content":"hi"}`)
+	if invalid.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid key, got %d", invalid.Code)
+	}
+}
+
+func TestCreateMessageConcurrentDuplicates(t *testing.T) {
+	handler := setupTestHandler()
+	handler.SetRateLimits(DefaultReadLimit, ratelimit.Config{Rate: 100, Burst: 100}, KeyByIP)
+	router := handler.SetupRoutes()
+
+	var wg sync.WaitGroup
+	ids := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := postWithKey(router, "same", `{"username":"alice","content":"hi"}`)
+			if rr.Code != http.StatusCreated {
+				t.Errorf("expected 201, got %d", rr.Code)
+				return
+			}
+			var response struct{ Data models.Message }
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Errorf("could not decode response: %v", err)
+				return
+			}
+			ids <- response.Data.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	for id := range ids {
+		if id != 1 {
+			t.Errorf("expected every response to carry message 1, got %d", id)
+		}
+	}
+	if count := handler.storage.Count(); count != 1 {
+		t.Errorf("expected 1 message, got %d", count)
+	}
+}
+
+func TestCreateMessageIdempotencyKeyPerClient(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	body := `{"username":"alice","content":"hi"}`
+
+	first := postAs(router, "alice", "shared", body)
+	second := postAs(router, "bob", "shared", body)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for both clients, got %d and %d", first.Code, second.Code)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected another client's key not to replay")
+	}
+	if count := handler.storage.Count(); count != 2 {
+		t.Errorf("expected a message per client, got %d", count)
+	}
+
+	// Anonymous clients are told apart by address
+	req := httptest.NewRequest("POST", "/api/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "shared")
+	req.RemoteAddr = "192.0.2.7:4000"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	anonymous := postWithKey(router, "shared", body)
+	if rr.Code != http.StatusCreated || anonymous.Code != http.StatusCreated || anonymous.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected separate messages for two addresses, got %d and %d", rr.Code, anonymous.Code)
+	}
+}
+
+// panicRule stands in for a moderation rule with a bug
+type panicRule struct{}
+
+func (panicRule) Name() string { return "panic" }
+
+func (panicRule) Check(content string) (string, *moderation.Finding) {
+	panic("rule failed")
+}
+
+func TestCreateMessageIdempotencyPanicReleasesKey(t *testing.T) {
+	handler := setupTestHandler()
+	handler.SetModeration(moderation.NewChain(panicRule{}))
+	router := handler.SetupRoutes()
+	body := `{"username":"alice","content":"hi"}`
+
+	if rr := postWithKey(router, "retry", body); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 from the panic, got %d", rr.Code)
+	}
+	if n := handler.idempotency.Len(); n != 0 {
+		t.Errorf("expected the key to be released, %d still held", n)
+	}
+
+	// The retry gets the key instead of waiting for the failed request
+	handler.SetModeration(nil)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postWithKey(router, "retry", body) }()
+	select {
+	case rr := <-done:
+		if rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("expected the retry to create the message, got %d", rr.Code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry is still waiting on the released key")
+	}
+}
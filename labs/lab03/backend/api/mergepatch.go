@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"mime"
+	"net/http"
+)
+
+// mergePatchContentType is the media type for RFC 7396 JSON Merge Patch
+const mergePatchContentType = "application/merge-patch+json"
+
+// maxPatchAttempts bounds retries when a concurrent edit lands between
+// reading a message and writing the patched version
+const maxPatchAttempts = 3
+
+// Merge patch errors
+var (
+	ErrPatchNotObject = errors.New("merge patch must be a JSON object")
+)
+
+// PatchMessage handles PATCH /api/messages/{id}
+func (h *Handler) PatchMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchContentType {
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		h.writeError(w, r, fmt.Errorf("%w: content type must be %s", ErrUnsupportedMediaType, mergePatchContentType))
+		return
+	}
+
+	var patch interface{}
+	if err := h.parseJSON(r, &patch); err != nil {
+		h.writeError(w, r, bodyError(err))
+		return
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		h.writeError(w, r, ErrPatchNotObject)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	for attempt := 1; ; attempt++ {
+		current, err := h.storage.GetByID(id)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if ifMatch != "" && !etagMatches(ifMatch, messageETag(current), false) {
+			w.Header().Set("ETag", messageETag(current))
+			h.writeError(w, r, storage.ErrVersionMismatch)
+			return
+		}
+
+		merged, err := h.applyMessagePatch(current, patch)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if err := merged.Validate(); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		content, reasons, err := h.moderate(merged.Content)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+
+		message, err := h.storage.Edit(id, storage.MessageEdit{
+			Username: &merged.Username,
+			Content:  &content,
+			EditedBy: requestUser(r),
+		}, current.Version)
+		if errors.Is(err, storage.ErrVersionMismatch) && ifMatch == "" && attempt < maxPatchAttempts {
+			continue
+		}
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+
+		h.flag(message, reasons)
+
+		w.Header().Set("ETag", messageETag(message))
+		h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: message})
+		return
+	}
+}
+
+// applyMessagePatch merges patch into the editable fields of message and
+// returns the result as a create request. The merged document is checked
+// against the create schema, so a patch fails exactly as a new message would.
+func (h *Handler) applyMessagePatch(message *models.Message, patch interface{}) (*models.CreateMessageRequest, error) {
+	// Moving a reply to another thread is not an edit
+	if object, ok := patch.(map[string]interface{}); ok {
+		if _, ok := object["parent_id"]; ok {
+			return nil, validationErrors{{Field: "parent_id", Message: "cannot be changed"}}
+		}
+	}
+
+	target := map[string]interface{}{
+		"username": message.Username,
+		"content":  message.Content,
+	}
+	merged := mergePatch(target, patch)
+
+	schema := h.spec.SchemaFor(models.CreateMessageRequest{})
+	if errs := h.spec.Validate(schema, merged); len(errs) > 0 {
+		return nil, validationErrors(errs)
+	}
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var req models.CreateMessageRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	return &req, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch to target and returns the result
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396, Appendix A
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var target, patch, want interface{}
+			json.Unmarshal([]byte(tt.target), &target)
+			json.Unmarshal([]byte(tt.patch), &patch)
+			json.Unmarshal([]byte(tt.want), &want)
+
+			if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchMessage(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	handler.storage.Create("testuser", "original")
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/api/messages/1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Username", "moderator")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch(mergePatchContentType, `{"content":"patched"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Data models.Message `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if response.Data.Content != "patched" || response.Data.Username != "testuser" {
+		t.Errorf("Unexpected patched message: %+v", response.Data)
+	}
+	if response.Data.EditedBy != "moderator" || response.Data.EditedAt == nil {
+		t.Errorf("Expected edit to be recorded, got edited_by=%q edited_at=%v", response.Data.EditedBy, response.Data.EditedAt)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"wrong content type", "application/json", `{"content":"x"}`, http.StatusUnsupportedMediaType},
+		{"not an object", mergePatchContentType, `"x"`, http.StatusBadRequest},
+		{"removes required field", mergePatchContentType, `{"content":null}`, http.StatusBadRequest},
+		{"read-only field", mergePatchContentType, `{"id":5}`, http.StatusBadRequest},
+		{"wrong type", mergePatchContentType, `{"content":42}`, http.StatusBadRequest},
+		{"body too large", mergePatchContentType, `{"content":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := patch(tt.contentType, tt.body); rr.Code != tt.status {
+				t.Errorf("Expected status %v, got %v", tt.status, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"lab03-backend/models"
+	"lab03-backend/moderation"
+	"log"
+	"net/http"
+)
+
+// Moderation errors
+var (
+	ErrContentRejected = errors.New("content rejected by moderation")
+)
+
+// SetModeration replaces the chain that screens message content on create
+// and update. nil turns moderation off.
+func (h *Handler) SetModeration(chain *moderation.Chain) {
+	h.moderation = chain
+}
+
+// moderate runs content through the moderation chain and returns what to
+// store along with the reasons to flag it for review, if any
+func (h *Handler) moderate(content string) (string, []models.FlagReason, error) {
+	if h.moderation == nil {
+		return content, nil, nil
+	}
+
+	result := h.moderation.Moderate(content)
+	if rejection := result.Rejection(); rejection != nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrContentRejected, rejection.Reason)
+	}
+	var reasons []models.FlagReason
+	for _, finding := range result.Findings {
+		if finding.Action == moderation.Flag {
+			reasons = append(reasons, models.FlagReason{Rule: finding.Rule, Reason: finding.Reason})
+		}
+	}
+	return result.Content, reasons, nil
+}
+
+// flag queues a stored message for review. The message is already saved,
+// so a failure, such as the message being deleted meanwhile, is only logged.
+func (h *Handler) flag(message *models.Message, reasons []models.FlagReason) {
+	if len(reasons) == 0 {
+		return
+	}
+	if _, err := h.storage.Flag(message.ID, reasons); err != nil {
+		log.Printf("failed to flag message %d: %v", message.ID, err)
+	}
+}
+
+// GetReviewQueue handles GET /api/moderation/queue
+func (h *Handler) GetReviewQueue(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: h.storage.ReviewQueue()})
+}
+
+// ReviewFlag handles POST /api/moderation/queue/{id}
+//
+// Approving keeps the message as it is; removing deletes it, after which it
+// can still be restored during the usual grace period. Either way the flag
+// leaves the queue.
+func (h *Handler) ReviewFlag(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req models.ReviewRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, r, ErrInvalidJSON)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	flag, err := h.storage.ResolveFlag(id, req.Action == models.ReviewRemove)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: flag})
+}
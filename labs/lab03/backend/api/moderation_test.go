@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModeration(t *testing.T) {
+	handler := setupTestHandler()
+	handler.SetAdminToken("secret")
+	router := handler.SetupRoutes()
+
+	do := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if strings.HasPrefix(path, "/api/moderation") {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/api/messages", "application/json", `{"username":"alice","content":"oh sh1t"}`)
+	var created struct {
+		Data models.Message `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.Data.Content != "oh ****" {
+		t.Errorf("Expected masked message to be created, got status %v with %q", rr.Code, created.Data.Content)
+	}
+
+	links := `{"username":"bob","content":"www.a.com www.b.com www.c.com www.d.com"}`
+	rr = do("POST", "/api/messages", "application/json", links)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "4 links") {
+		t.Errorf("Expected content-rejected, got status %v: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("PATCH", "/api/messages/1", mergePatchContentType, `{"content":"www.a.com www.b.com www.c.com www.d.com"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected patch to be rejected, got status %v", rr.Code)
+	}
+
+	spam := `{"content":"` + strings.Repeat("!", 20) + `"}`
+	if rr := do("PUT", "/api/messages/1", "application/json", spam); rr.Code != http.StatusOK {
+		t.Fatalf("Expected flagged update to be stored, got status %v", rr.Code)
+	}
+
+	rr = do("GET", "/api/moderation/queue", "", "")
+	var queue struct {
+		Data []models.Flag `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&queue)
+	if rr.Code != http.StatusOK || len(queue.Data) != 1 || queue.Data[0].MessageID != 1 {
+		t.Fatalf("Expected message 1 in the review queue, got status %v with %+v", rr.Code, queue.Data)
+	}
+
+	if rr := do("POST", "/api/moderation/queue/1", "application/json", `{"action":"ignore"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v for an unknown action, got %v", http.StatusBadRequest, rr.Code)
+	}
+	if rr := do("POST", "/api/moderation/queue/1", "application/json", `{"action":"remove"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected flag to be resolved, got status %v", rr.Code)
+	}
+	if rr := do("GET", "/api/messages/1", "", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected removed message to be gone, got status %v", rr.Code)
+	}
+	if rr := do("POST", "/api/moderation/queue/1", "application/json", `{"action":"approve"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for a resolved flag, got %v", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestModerationDisabled(t *testing.T) {
+	handler := setupTestHandler()
+	handler.SetModeration(nil)
+	router := handler.SetupRoutes()
+
+	req := httptest.NewRequest("POST", "/api/messages", bytes.NewBufferString(`{"username":"alice","content":"oh shit"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "oh shit") {
+		t.Errorf("Expected content stored unchanged, got status %v: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"lab03-backend/storage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size bounds for list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// Pagination errors
+var (
+	ErrInvalidLimit   = fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	ErrInvalidOffset  = errors.New("offset must be a non-negative integer")
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorOffset   = errors.New("cursor and offset cannot be used together")
+	ErrInvalidSince   = errors.New("since must be an RFC 3339 timestamp")
+	ErrInvalidSortKey = errors.New(`sort must be "timestamp" or "-timestamp"`)
+)
+
+// parseListOptions reads pagination, filter and sort parameters from the query string
+func parseListOptions(r *http.Request) (storage.ListOptions, error) {
+	query := r.URL.Query()
+	opts := storage.ListOptions{
+		Username: query.Get("username"),
+		Limit:    defaultPageLimit,
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return opts, ErrInvalidLimit
+		}
+		opts.Limit = limit
+	}
+
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return opts, ErrInvalidOffset
+		}
+		opts.Offset = offset
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		if query.Has("offset") {
+			return opts, ErrCursorOffset
+		}
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = &cursor
+	}
+
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return opts, ErrInvalidSince
+		}
+		opts.Since = since
+	}
+
+	switch query.Get("sort") {
+	case "", "timestamp":
+	case "-timestamp":
+		opts.Desc = true
+	default:
+		return opts, ErrInvalidSortKey
+	}
+
+	return opts, nil
+}
+
+// encodeCursor turns a list position into an opaque, URL-safe token
+func encodeCursor(cursor storage.Cursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.Timestamp.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by encodeCursor
+func decodeCursor(token string) (storage.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return storage.Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return storage.Cursor{}, ErrInvalidCursor
+	}
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return storage.Cursor{}, ErrInvalidCursor
+	}
+	messageID, err := strconv.Atoi(id)
+	if err != nil {
+		return storage.Cursor{}, ErrInvalidCursor
+	}
+
+	return storage.Cursor{Timestamp: time.Unix(0, ts), ID: messageID}, nil
+}
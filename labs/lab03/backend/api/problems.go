@@ -0,0 +1,224 @@
+package api
+
+import (
+	"errors"
+	"lab03-backend/blobstore"
+	"lab03-backend/idempotency"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// problemContentType is the RFC 7807 media type for problem details
+const problemContentType = "application/problem+json"
+
+// problemTypeBase prefixes problem type slugs to form their type URI. Each
+// type URI resolves to a description served by GetProblemType.
+const problemTypeBase = apiPrefix + "/problems/"
+
+// Request errors that are not tied to storage or a specific handler
+var (
+	ErrInvalidJSON          = errors.New("invalid JSON body")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrBodyTooLarge         = errors.New("request body too large")
+	ErrInvalidStatusCode    = errors.New("status code must be between 100 and 599")
+	ErrStreamingUnsupported = errors.New("streaming not supported")
+	ErrUnknownProblemType   = errors.New("unknown problem type")
+)
+
+// ProblemType is a stable kind of error clients can branch on
+type ProblemType struct {
+	Slug   string  `json:"-"`
+	Type   string  `json:"type"`
+	Title  string  `json:"title"`
+	Status int     `json:"status"`
+	errors []error // Sentinel errors reported as this type
+}
+
+// problemTypes lists every documented problem type. Clients should compare
+// the type URI, never the title or detail.
+var problemTypes = []*ProblemType{
+	newProblemType("message-not-found", "Message not found", http.StatusNotFound,
+		storage.ErrMessageNotFound),
+	newProblemType("attachment-not-found", "Attachment not found", http.StatusNotFound,
+		storage.ErrAttachmentNotFound, blobstore.ErrBlobNotFound),
+	newProblemType("attachments-disabled", "Attachments are not enabled", http.StatusServiceUnavailable,
+		ErrAttachmentsDisabled),
+	newProblemType("invalid-multipart", "Malformed multipart body", http.StatusBadRequest,
+		ErrInvalidMultipart, ErrMissingFile),
+	newProblemType("invalid-id", "Invalid message ID", http.StatusBadRequest,
+		storage.ErrInvalidID),
+	newProblemType("validation-failed", "Request validation failed", http.StatusBadRequest,
+		ErrValidationFailed),
+	newProblemType("invalid-json", "Malformed JSON body", http.StatusBadRequest,
+		ErrInvalidJSON, ErrPatchNotObject),
+	newProblemType("invalid-query", "Invalid query parameter", http.StatusBadRequest,
+		ErrInvalidLimit, ErrInvalidOffset, ErrInvalidCursor, ErrCursorOffset, ErrInvalidSince, ErrInvalidSortKey),
+	newProblemType("invalid-last-event-id", "Invalid Last-Event-ID", http.StatusBadRequest,
+		ErrInvalidLastEventID),
+	newProblemType("invalid-status-code", "Invalid HTTP status code", http.StatusBadRequest,
+		ErrInvalidStatusCode),
+	newProblemType("parent-not-found", "Parent message not found", http.StatusUnprocessableEntity,
+		storage.ErrParentNotFound),
+	newProblemType("invalid-emoji", "Invalid reaction emoji", http.StatusBadRequest,
+		ErrInvalidEmoji),
+	newProblemType("user-required", "User required", http.StatusBadRequest,
+		ErrUserRequired),
+	newProblemType("content-rejected", "Content rejected by moderation", http.StatusUnprocessableEntity,
+		ErrContentRejected),
+	newProblemType("flag-not-found", "Flag not found", http.StatusNotFound,
+		storage.ErrFlagNotFound),
+	newProblemType("version-mismatch", "Message has changed", http.StatusPreconditionFailed,
+		storage.ErrVersionMismatch),
+	newProblemType("not-deleted", "Message is not deleted", http.StatusConflict,
+		storage.ErrNotDeleted),
+	newProblemType("restore-expired", "Restore period has expired", http.StatusGone,
+		storage.ErrRestoreExpired),
+	newProblemType("body-too-large", "Request body too large", http.StatusRequestEntityTooLarge,
+		ErrBodyTooLarge),
+	newProblemType("unsupported-media-type", "Unsupported media type", http.StatusUnsupportedMediaType,
+		ErrUnsupportedMediaType),
+	newProblemType("invalid-idempotency-key", "Invalid Idempotency-Key", http.StatusBadRequest,
+		ErrInvalidIdempotencyKey),
+	newProblemType("idempotency-key-reused", "Idempotency-Key reused", http.StatusUnprocessableEntity,
+		idempotency.ErrKeyReused),
+	newProblemType("rate-limited", "Too many requests", http.StatusTooManyRequests,
+		ErrRateLimited),
+	newProblemType("unauthorized", "Authentication required", http.StatusUnauthorized,
+		ErrUnauthorized),
+	newProblemType("admin-disabled", "Admin endpoints are disabled", http.StatusServiceUnavailable,
+		ErrAdminDisabled),
+	newProblemType("snapshots-disabled", "Snapshots are not enabled", http.StatusServiceUnavailable,
+		ErrSnapshotsDisabled),
+	newProblemType("unknown-problem-type", "Unknown problem type", http.StatusNotFound,
+		ErrUnknownProblemType),
+}
+
+// problemInternal covers errors without a documented type. Its type URI is
+// about:blank, which RFC 7807 reserves for "no more than the status code".
+var problemInternal = &ProblemType{
+	Type:   "about:blank",
+	Title:  http.StatusText(http.StatusInternalServerError),
+	Status: http.StatusInternalServerError,
+}
+
+func newProblemType(slug, title string, status int, errs ...error) *ProblemType {
+	return &ProblemType{
+		Slug:   slug,
+		Type:   problemTypeBase + slug,
+		Title:  title,
+		Status: status,
+		errors: errs,
+	}
+}
+
+// problemFor returns the problem type that err is reported as
+func problemFor(err error) *ProblemType {
+	var fieldError *models.FieldError
+	var fieldErrors validationErrors
+	if errors.As(err, &fieldError) || errors.As(err, &fieldErrors) {
+		err = ErrValidationFailed
+	}
+
+	for _, pt := range problemTypes {
+		for _, target := range pt.errors {
+			if errors.Is(err, target) {
+				return pt
+			}
+		}
+	}
+	return problemInternal
+}
+
+// validationErrors carries every field problem found in one request
+type validationErrors []models.FieldError
+
+func (e validationErrors) Error() string {
+	return ErrValidationFailed.Error()
+}
+
+// fieldErrorsOf extracts per-field errors from a validation failure
+func fieldErrorsOf(err error) []models.FieldError {
+	var fieldErrors validationErrors
+	if errors.As(err, &fieldErrors) {
+		return fieldErrors
+	}
+	var fieldError *models.FieldError
+	if errors.As(err, &fieldError) {
+		return []models.FieldError{*fieldError}
+	}
+	return nil
+}
+
+// Helper function to write error responses. Clients that list
+// application/problem+json in Accept get RFC 7807 problem details; everyone
+// else gets the usual APIResponse envelope.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	pt := problemFor(err)
+	fieldErrors := fieldErrorsOf(err)
+
+	detail := err.Error()
+	if fieldErrors != nil {
+		// The per-field errors carry the specifics
+		detail = ErrValidationFailed.Error()
+	}
+	if pt == problemInternal {
+		// Unexpected errors may carry internals that clients should not see
+		detail = "an unexpected error occurred"
+	}
+
+	if !wantsProblem(r) {
+		h.writeJSON(w, pt.Status, models.APIResponse{
+			Success:   false,
+			Error:     detail,
+			Errors:    fieldErrors,
+			RequestID: requestID(r),
+		})
+		return
+	}
+
+	problem := models.Problem{
+		Type:      pt.Type,
+		Title:     pt.Title,
+		Status:    pt.Status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		Errors:    fieldErrors,
+		RequestID: requestID(r),
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(pt.Status)
+	h.encode(w, problem)
+}
+
+// wantsProblem reports whether the Accept header lists problem+json
+func wantsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != problemContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// GetProblemType handles GET /api/problems/{slug}
+func (h *Handler) GetProblemType(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	for _, pt := range problemTypes {
+		if pt.Slug == slug {
+			h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: pt})
+			return
+		}
+	}
+	h.writeError(w, r, ErrUnknownProblemType)
+}
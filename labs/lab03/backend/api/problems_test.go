@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemDetails(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		typeSlug string
+	}{
+		{"message not found", "GET", "/api/messages/999", "", http.StatusNotFound, "message-not-found"},
+		{"invalid id", "DELETE", "/api/messages/abc", "", http.StatusBadRequest, "invalid-id"},
+		{"invalid query", "GET", "/api/messages?limit=0", "", http.StatusBadRequest, "invalid-query"},
+		{"invalid json", "POST", "/api/messages", "{", http.StatusBadRequest, "invalid-json"},
+		{"validation", "POST", "/api/messages", `{"username":""}`, http.StatusBadRequest, "validation-failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Accept", "application/json, application/problem+json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("Expected status %v, got %v", tt.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+				t.Fatalf("Expected Content-Type %s, got %s", problemContentType, ct)
+			}
+
+			var problem models.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+				t.Fatalf("Could not decode problem: %v", err)
+			}
+			if problem.Type != problemTypeBase+tt.typeSlug {
+				t.Errorf("Expected type %s, got %s", problemTypeBase+tt.typeSlug, problem.Type)
+			}
+			if problem.Status != tt.status || problem.Title == "" || problem.Instance != tt.path {
+				t.Errorf("Unexpected problem %+v", problem)
+			}
+			if tt.typeSlug == "validation-failed" && len(problem.Errors) != 2 {
+				t.Errorf("Expected 2 field errors, got %v", problem.Errors)
+			}
+		})
+	}
+}
+
+func TestProblemDetailsNegotiation(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	for accept, want := range map[string]string{
+		"":                                    "application/json",
+		"application/json":                    "application/json",
+		"application/problem+json;q=0":        "application/json",
+		"application/problem+json;q=0.5":      problemContentType,
+		"text/html, application/problem+json": problemContentType,
+	} {
+		t.Run(accept, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/messages/999", nil)
+			req.Header.Set("Accept", accept)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if ct := rr.Header().Get("Content-Type"); ct != want {
+				t.Errorf("Expected Content-Type %s, got %s", want, ct)
+			}
+		})
+	}
+}
+
+func TestGetProblemType(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	for _, pt := range problemTypes {
+		req, _ := http.NewRequest("GET", pt.Type, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected type URI %s to resolve, got %v", pt.Type, rr.Code)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", problemTypeBase+"nope", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for unknown type, got %v", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"lab03-backend/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default per-client limits. Reads cover polling and page fetches; writes
+// are tighter to stop one client flooding POST /api/messages.
+var (
+	DefaultReadLimit  = ratelimit.Config{Rate: 20, Burst: 40}
+	DefaultWriteLimit = ratelimit.Config{Rate: 1, Burst: 10}
+)
+
+// ErrRateLimited is reported when a client has used up its request budget
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitKey identifies the client a request is charged to
+type RateLimitKey func(r *http.Request) string
+
+// KeyByIP charges requests to the client's IP address
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByUser charges requests to the X-Username header, falling back to the
+// client IP for anonymous requests. Only use it behind authentication, since
+// the header is otherwise client-controlled.
+func KeyByUser(r *http.Request) string {
+	if user := requestUser(r); user != "" {
+		return "user:" + user
+	}
+	return KeyByIP(r)
+}
+
+// SetRateLimits replaces the read and write limits and how clients are
+// identified. Call it before SetupRoutes.
+func (h *Handler) SetRateLimits(read, write ratelimit.Config, key RateLimitKey) {
+	h.readLimiter = ratelimit.New(read)
+	h.writeLimiter = ratelimit.New(write)
+	h.rateLimitKey = key
+}
+
+// rateLimitMiddleware charges each request to its client's read or write
+// bucket and rejects it with 429 once the bucket is empty
+func (h *Handler) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := h.writeLimiter
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			limiter = h.readLimiter
+		}
+
+		decision := limiter.Allow(h.rateLimitKey(r))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+
+		if !decision.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			h.writeError(w, r, ErrRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds rounds up so clients never retry too early
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"lab03-backend/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	handler := setupTestHandler()
+	handler.SetRateLimits(
+		ratelimit.Config{Rate: 0.001, Burst: 5},
+		ratelimit.Config{Rate: 0.001, Burst: 2},
+		KeyByIP,
+	)
+	router := handler.SetupRoutes()
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		body := bytes.NewBufferString(`{"username":"alice","content":"hi"}`)
+		req := httptest.NewRequest("POST", "/api/messages", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := post("10.0.0.1:1234"); rr.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected 201, got %d", i+1, rr.Code)
+		}
+	}
+
+	rr := post("10.0.0.1:5678")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("expected RateLimit-Limit 2, got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", got)
+	}
+
+	if rr := post("10.0.0.2:1234"); rr.Code != http.StatusCreated {
+		t.Errorf("expected other clients to be unaffected, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/messages", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected reads to use their own limit, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("RateLimit-Remaining"); got != "4" {
+		t.Errorf("expected RateLimit-Remaining 4, got %q", got)
+	}
+}
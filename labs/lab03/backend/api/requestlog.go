@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// requestIDHeader carries the request ID in both directions, so one ID can
+// follow a request through proxies and into client bug reports
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from clients so they cannot bloat
+// every log line
+const maxRequestIDLength = 128
+
+// errPanic reports a recovered panic. It has no problem type, so clients
+// only see a generic 500.
+var errPanic = errors.New("handler panicked")
+
+type requestIDKey struct{}
+
+// SetLogger replaces the logger that receives one line per request. nil
+// turns request logging off; request IDs and panic recovery stay on.
+func (h *Handler) SetLogger(logger *slog.Logger) {
+	h.logger = logger
+}
+
+// requestID returns the ID assigned to r by observe, if any
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// observe assigns each request an ID, recovers panics into 500 responses
+// and logs the outcome once the request completes
+func (h *Handler) observe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		recorder := &responseRecorder{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered != nil {
+				h.logPanic(r, recovered)
+				// Once the header is out, the client has to see a broken response
+				if !recorder.wroteHeader {
+					h.writeError(recorder, r, errPanic)
+				}
+			}
+			h.logRequest(r, recorder, time.Since(start))
+			// The server aborts the connection for this one, as it would without us
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// logRequest writes the structured log line for a completed request
+func (h *Handler) logRequest(r *http.Request, recorder *responseRecorder, elapsed time.Duration) {
+	if h.logger == nil {
+		return
+	}
+
+	status := recorder.status
+	if status == 0 {
+		// Handlers that write nothing get an implicit 200
+		status = http.StatusOK
+	}
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	h.logger.LogAttrs(r.Context(), level, "request",
+		slog.String("request_id", requestID(r)),
+		slog.String("method", r.Method),
+		slog.String("route", routeTemplate(r)),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(elapsed.Microseconds())/1000),
+		slog.Int64("bytes", recorder.bytes),
+		slog.String("user", requestUser(r)),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+func (h *Handler) logPanic(r *http.Request, recovered interface{}) {
+	if h.logger == nil || recovered == http.ErrAbortHandler {
+		return
+	}
+	h.logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+		slog.String("request_id", requestID(r)),
+		slog.String("error", fmt.Sprint(recovered)),
+		slog.String("stack", string(debug.Stack())),
+	)
+}
+
+// routeTemplate returns the path template of the matched route, such as
+// /api/messages/{id}, so logs group requests by endpoint rather than by ID.
+// It is empty for requests that matched no route.
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
+
+// validRequestID reports whether a client-supplied ID is safe to echo back
+// and log: non-empty, bounded and printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder notes the status and size of a response on its way out
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps event streams working through the recorder
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLogging(t *testing.T) {
+	handler := setupTestHandler()
+	var logs bytes.Buffer
+	handler.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	router := handler.SetupRoutes()
+	handler.storage.Create("alice", "hello")
+
+	req := httptest.NewRequest("GET", "/api/messages/1", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	req.Header.Set("X-Username", "bob")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if got := rr.Header().Get(requestIDHeader); got != "abc-123" {
+		t.Errorf("Expected request ID to be propagated, got %q", got)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q: %v", logs.String(), err)
+	}
+	want := map[string]interface{}{
+		"request_id": "abc-123",
+		"method":     "GET",
+		"route":      "/api/messages/{id}",
+		"status":     float64(http.StatusOK),
+		"bytes":      float64(rr.Body.Len()),
+		"user":       "bob",
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, line[key])
+		}
+	}
+
+	// Unsafe IDs are replaced rather than echoed back
+	req = httptest.NewRequest("GET", "/api/nowhere", nil)
+	req.Header.Set(requestIDHeader, "has spaces")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if got := rr.Header().Get(requestIDHeader); got == "" || got == "has spaces" {
+		t.Errorf("Expected a generated request ID, got %q", got)
+	}
+	if !strings.Contains(logs.String(), fmt.Sprintf(`"route":"","path":"/api/nowhere","status":%d`, rr.Code)) {
+		t.Errorf("Expected unmatched request to be logged with status %v, got %s", rr.Code, logs.String())
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	handler := setupTestHandler()
+	var logs bytes.Buffer
+	handler.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	router := handler.SetupRoutes()
+	router.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("Accept", problemContentType)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %v, got %v", http.StatusInternalServerError, rr.Code)
+	}
+	var problem struct {
+		Detail    string `json:"detail"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if problem.RequestID == "" || problem.RequestID != rr.Header().Get(requestIDHeader) {
+		t.Errorf("Expected response to carry request ID %q, got %q", rr.Header().Get(requestIDHeader), problem.RequestID)
+	}
+	if strings.Contains(problem.Detail, "boom") {
+		t.Errorf("Expected panic value to stay out of the response, got %q", problem.Detail)
+	}
+	if !strings.Contains(logs.String(), `"msg":"panic"`) || !strings.Contains(logs.String(), `"status":500`) {
+		t.Errorf("Expected panic and request to be logged, got %s", logs.String())
+	}
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"lab03-backend/models"
+	"lab03-backend/openapi"
+	"lab03-backend/storage"
+	"net/http"
+)
+
+// apiPrefix is where every route in the table is mounted
+const apiPrefix = "/api"
+
+// route describes one API endpoint. The same table registers the handlers
+// and generates the OpenAPI document, so the two cannot drift apart.
+type route struct {
+	Method  string
+	Path    string // Relative to apiPrefix, in gorilla/mux syntax
+	Name    string // OpenAPI operationId
+	Summary string
+	Handler http.HandlerFunc
+
+	Params   []openapi.Parameter // Query and header parameters (path parameters are derived from Path)
+	Body     interface{}         // Request body type, validated before Handler runs
+	BodyType string              // Media type of Body (default application/json)
+
+	Status   int         // Success status code
+	Data     interface{} // Type carried in APIResponse.Data, nil for an empty body
+	Raw      bool        // Data is the whole response body rather than wrapped in APIResponse
+	Produces string      // Response media type (default application/json)
+
+	Admin bool // Requires the admin bearer token
+}
+
+// routes returns the API route table in registration order. Literal paths
+// must come before parameterised ones that would also match them.
+func (h *Handler) routes() []route {
+	return []route{
+		{
+			Method: http.MethodGet, Path: "/messages", Name: "listMessages",
+			Summary: "List messages with filtering, sorting and pagination",
+			Handler: h.GetMessages,
+			Params: []openapi.Parameter{
+				queryParam("limit", "Page size (1-100, default 50)", &openapi.Schema{Type: "integer", Minimum: float(1), Maximum: float(maxPageLimit)}),
+				queryParam("cursor", "next_cursor from the previous page", &openapi.Schema{Type: "string"}),
+				queryParam("offset", "Number of messages to skip; cannot be combined with cursor", &openapi.Schema{Type: "integer", Minimum: float(0)}),
+				queryParam("username", "Only messages from this user", &openapi.Schema{Type: "string"}),
+				queryParam("since", "Only messages at or after this time", &openapi.Schema{Type: "string", Format: "date-time"}),
+				queryParam("sort", "Sort order", &openapi.Schema{Type: "string", Enum: []interface{}{"timestamp", "-timestamp"}}),
+			},
+			Status: http.StatusOK, Data: []models.Message{},
+		},
+		{
+			Method: http.MethodPost, Path: "/messages", Name: "createMessage",
+			Summary: "Create a message",
+			Handler: h.CreateMessage,
+			Params: []openapi.Parameter{
+				headerParam("Idempotency-Key", "Retries with the same key and body return the original response", &openapi.Schema{Type: "string"}),
+			},
+			Body:   models.CreateMessageRequest{},
+			Status: http.StatusCreated, Data: models.Message{},
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/stream", Name: "streamMessages",
+			Summary: "Stream message changes as Server-Sent Events",
+			Handler: h.StreamMessages,
+			Status:  http.StatusOK, Data: storage.Event{}, Raw: true, Produces: "text/event-stream",
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/{id}", Name: "getMessage",
+			Summary: "Get a message",
+			Handler: h.GetMessage,
+			Status:  http.StatusOK, Data: models.Message{},
+		},
+		{
+			Method: http.MethodPut, Path: "/messages/{id}", Name: "updateMessage",
+			Summary: "Replace the content of a message",
+			Handler: h.UpdateMessage,
+			Body:    models.UpdateMessageRequest{},
+			Status:  http.StatusOK, Data: models.Message{},
+		},
+		{
+			Method: http.MethodPatch, Path: "/messages/{id}", Name: "patchMessage",
+			Summary: "Edit a message with a JSON Merge Patch",
+			Handler: h.PatchMessage,
+			Body:    models.CreateMessageRequest{}, BodyType: mergePatchContentType,
+			Status: http.StatusOK, Data: models.Message{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/messages/{id}", Name: "deleteMessage",
+			Summary: "Delete a message (restorable during the grace period)",
+			Handler: h.DeleteMessage,
+			Status:  http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/{id}/revisions", Name: "listRevisions",
+			Summary: "List every version of a message",
+			Handler: h.GetRevisions,
+			Status:  http.StatusOK, Data: []models.Revision{},
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/{id}/thread", Name: "getThread",
+			Summary: "Get a message and every reply beneath it",
+			Handler: h.GetThread,
+			Status:  http.StatusOK, Data: models.Thread{},
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/{id}/reactions", Name: "listReactions",
+			Summary: "List reactions to a message with counts per emoji",
+			Handler: h.GetReactions,
+			Status:  http.StatusOK, Data: []models.ReactionSummary{},
+		},
+		{
+			Method: http.MethodPut, Path: "/messages/{id}/reactions/{emoji}", Name: "addReaction",
+			Summary: "React to a message as the X-Username user",
+			Handler: h.AddReaction,
+			Params:  reactionParams(),
+			Status:  http.StatusOK, Data: []models.ReactionSummary{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/messages/{id}/reactions/{emoji}", Name: "removeReaction",
+			Summary: "Remove the X-Username user's reaction from a message",
+			Handler: h.RemoveReaction,
+			Params:  reactionParams(),
+			Status:  http.StatusOK, Data: []models.ReactionSummary{},
+		},
+		{
+			Method: http.MethodGet, Path: "/messages/{id}/attachments", Name: "listAttachments",
+			Summary: "List the files attached to a message",
+			Handler: h.GetAttachments,
+			Status:  http.StatusOK, Data: []models.Attachment{},
+		},
+		{
+			Method: http.MethodPost, Path: "/messages/{id}/attachments", Name: "uploadAttachment",
+			Summary: "Attach a file, sent as the multipart/form-data part named \"file\"",
+			Handler: h.UploadAttachment,
+			Status:  http.StatusCreated, Data: models.Attachment{},
+		},
+		{
+			Method: http.MethodGet, Path: "/attachments/{id}", Name: "downloadAttachment",
+			Summary: "Download an attachment; supports Range requests",
+			Handler: h.DownloadAttachment,
+			Status:  http.StatusOK, Data: "", Raw: true, Produces: "application/octet-stream",
+		},
+		{
+			Method: http.MethodPost, Path: "/messages/{id}/restore", Name: "restoreMessage",
+			Summary: "Restore a deleted message",
+			Handler: h.RestoreMessage,
+			Status:  http.StatusOK, Data: models.Message{},
+		},
+		{
+			Method: http.MethodGet, Path: "/status/{code}", Name: "getHTTPStatus",
+			Summary: "Describe an HTTP status code",
+			Handler: h.GetHTTPStatus,
+			Status:  http.StatusOK, Data: models.HTTPStatusResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/cat/{code}", Name: "getHTTPStatusImage",
+			Summary: "Get the http.cat image for an HTTP status code, or a placeholder",
+			Handler: h.GetHTTPStatusImage,
+			Status:  http.StatusOK, Data: "", Raw: true, Produces: "image/*",
+		},
+		{
+			Method: http.MethodGet, Path: "/health", Name: "healthCheck",
+			Summary: "Report server health",
+			Handler: h.HealthCheck,
+			Status:  http.StatusOK, Data: map[string]interface{}{}, Raw: true,
+		},
+		{
+			Method: http.MethodGet, Path: "/problems/{slug}", Name: "getProblemType",
+			Summary: "Describe a problem type URI used in error responses",
+			Handler: h.GetProblemType,
+			Params: []openapi.Parameter{
+				{Name: "slug", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			},
+			Status: http.StatusOK, Data: ProblemType{},
+		},
+		{
+			Method: http.MethodPost, Path: "/admin/snapshots", Name: "createSnapshot",
+			Summary: "Save a snapshot of all messages to the server's snapshot file",
+			Handler: h.CreateSnapshot,
+			Status:  http.StatusCreated, Data: storage.SnapshotInfo{},
+			Admin: true,
+		},
+		{
+			Method: http.MethodGet, Path: "/admin/snapshot", Name: "downloadSnapshot",
+			Summary: "Download a snapshot of all messages for backup",
+			Handler: h.DownloadSnapshot,
+			Status:  http.StatusOK, Data: map[string]interface{}{}, Raw: true,
+			Admin: true,
+		},
+		{
+			Method: http.MethodGet, Path: "/moderation/queue", Name: "getReviewQueue",
+			Summary: "List messages flagged by moderation, oldest first",
+			Handler: h.GetReviewQueue,
+			Status:  http.StatusOK, Data: []models.Flag{},
+			Admin: true,
+		},
+		{
+			Method: http.MethodPost, Path: "/moderation/queue/{id}", Name: "reviewFlag",
+			Summary: "Approve or remove a flagged message",
+			Handler: h.ReviewFlag,
+			Body:    models.ReviewRequest{},
+			Status:  http.StatusOK, Data: models.Flag{},
+			Admin: true,
+		},
+		{
+			Method: http.MethodGet, Path: "/openapi.json", Name: "getOpenAPI",
+			Summary: "This OpenAPI document",
+			Handler: h.GetOpenAPI,
+			Status:  http.StatusOK, Data: map[string]interface{}{}, Raw: true,
+		},
+	}
+}
+
+// buildSpec generates the OpenAPI document for a route table
+func buildSpec(routes []route) *openapi.Document {
+	doc := openapi.New("Lab 03 Chat API", "1.0.0")
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		adminSecurityScheme: {Type: "http", Scheme: "bearer"},
+	}
+
+	for _, rt := range routes {
+		op := &openapi.Operation{
+			OperationID: rt.Name,
+			Summary:     rt.Summary,
+			Parameters:  rt.Params,
+			Responses:   make(map[string]*openapi.Response),
+		}
+
+		if rt.Body != nil {
+			schema := doc.SchemaFor(rt.Body)
+			if bodyType(rt) == mergePatchContentType {
+				schema = patchSchema(doc, schema)
+			}
+			op.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]*openapi.MediaType{bodyType(rt): {Schema: schema}},
+			}
+		}
+
+		success := &openapi.Response{Description: openapi.DescribeStatus(rt.Status)}
+		if rt.Data != nil {
+			produces := rt.Produces
+			if produces == "" {
+				produces = "application/json"
+			}
+			schema := doc.SchemaFor(rt.Data)
+			if !rt.Raw {
+				schema = envelopeSchema(doc, schema)
+			}
+			success.Content = map[string]*openapi.MediaType{produces: {Schema: schema}}
+		}
+		op.Responses[openapi.StatusKey(rt.Status)] = success
+		if rt.Admin {
+			op.Security = []openapi.SecurityRequirement{{adminSecurityScheme: {}}}
+		}
+		op.Responses[openapi.StatusKey(0)] = &openapi.Response{
+			Description: openapi.DescribeStatus(0),
+			Content: map[string]*openapi.MediaType{
+				"application/json": {Schema: envelopeSchema(doc, nil)},
+				problemContentType: {Schema: doc.SchemaFor(models.Problem{})},
+			},
+		}
+
+		doc.AddOperation(rt.Method, apiPrefix+rt.Path, op)
+	}
+	return doc
+}
+
+// envelopeSchema describes models.APIResponse with Data of the given schema
+func envelopeSchema(doc *openapi.Document, data *openapi.Schema) *openapi.Schema {
+	schema := doc.Resolve(doc.SchemaFor(models.APIResponse{}))
+	envelope := *schema
+	envelope.Properties = make(map[string]*openapi.Schema, len(schema.Properties))
+	for name, property := range schema.Properties {
+		envelope.Properties[name] = property
+	}
+	if data == nil {
+		delete(envelope.Properties, "data")
+	} else {
+		envelope.Properties["data"] = data
+	}
+	return &envelope
+}
+
+// patchSchema relaxes a request schema for merge patches: every field is
+// optional and may be null to remove it. Fields that are fixed once a
+// message exists are left out, so patches that set them are rejected.
+func patchSchema(doc *openapi.Document, schema *openapi.Schema) *openapi.Schema {
+	patch := *doc.Resolve(schema)
+	patch.Required = nil
+	patch.Properties = make(map[string]*openapi.Schema, len(patch.Properties))
+	for name, property := range doc.Resolve(schema).Properties {
+		if name == "parent_id" {
+			continue
+		}
+		nullable := *property
+		nullable.Nullable = true
+		patch.Properties[name] = &nullable
+	}
+	return &patch
+}
+
+// reactionParams documents the reaction routes' emoji and user
+func reactionParams() []openapi.Parameter {
+	return []openapi.Parameter{
+		{Name: "emoji", In: "path", Required: true, Description: "A single emoji, URL-encoded", Schema: &openapi.Schema{Type: "string"}},
+		headerParam("X-Username", "User the reaction belongs to", &openapi.Schema{Type: "string"}),
+	}
+}
+
+func bodyType(rt route) string {
+	if rt.BodyType == "" {
+		return "application/json"
+	}
+	return rt.BodyType
+}
+
+func queryParam(name, description string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func headerParam(name, description string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "header", Description: description, Schema: schema}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"lab03-backend/httpcat"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Cache lifetimes for status images. Placeholders expire sooner so a real
+// image added to the mirror shows up without clients clearing their cache.
+const (
+	statusImageMaxAge      = 7 * 24 * 60 * 60
+	placeholderImageMaxAge = 60 * 60
+)
+
+// statusDescriptions lists every status code in the IANA HTTP Status Code
+// Registry (https://www.iana.org/assignments/http-status-codes), using the
+// reason phrases from RFC 9110 where it defines them.
+var statusDescriptions = map[int]string{
+	100: "Continue",
+	101: "Switching Protocols",
+	102: "Processing",
+	103: "Early Hints",
+
+	200: "OK",
+	201: "Created",
+	202: "Accepted",
+	203: "Non-Authoritative Information",
+	204: "No Content",
+	205: "Reset Content",
+	206: "Partial Content",
+	207: "Multi-Status",
+	208: "Already Reported",
+	226: "IM Used",
+
+	300: "Multiple Choices",
+	301: "Moved Permanently",
+	302: "Found",
+	303: "See Other",
+	304: "Not Modified",
+	305: "Use Proxy",
+	306: "(Unused)",
+	307: "Temporary Redirect",
+	308: "Permanent Redirect",
+
+	400: "Bad Request",
+	401: "Unauthorized",
+	402: "Payment Required",
+	403: "Forbidden",
+	404: "Not Found",
+	405: "Method Not Allowed",
+	406: "Not Acceptable",
+	407: "Proxy Authentication Required",
+	408: "Request Timeout",
+	409: "Conflict",
+	410: "Gone",
+	411: "Length Required",
+	412: "Precondition Failed",
+	413: "Content Too Large",
+	414: "URI Too Long",
+	415: "Unsupported Media Type",
+	416: "Range Not Satisfiable",
+	417: "Expectation Failed",
+	// IANA reserves 418 as unused; the lab keeps RFC 2324's teapot
+	418: "I'm a teapot",
+	421: "Misdirected Request",
+	422: "Unprocessable Content",
+	423: "Locked",
+	424: "Failed Dependency",
+	425: "Too Early",
+	426: "Upgrade Required",
+	428: "Precondition Required",
+	429: "Too Many Requests",
+	431: "Request Header Fields Too Large",
+	451: "Unavailable For Legal Reasons",
+
+	500: "Internal Server Error",
+	501: "Not Implemented",
+	502: "Bad Gateway",
+	503: "Service Unavailable",
+	504: "Gateway Timeout",
+	505: "HTTP Version Not Supported",
+	506: "Variant Also Negotiates",
+	507: "Insufficient Storage",
+	508: "Loop Detected",
+	510: "Not Extended (OBSOLETED)",
+	511: "Network Authentication Required",
+}
+
+// SetStatusImages replaces the mirror that GetHTTPStatusImage serves from
+func (h *Handler) SetStatusImages(mirror *httpcat.Mirror) {
+	h.statusImages = mirror
+}
+
+// GetHTTPStatusImage handles GET /api/cat/{code}
+func (h *Handler) GetHTTPStatusImage(w http.ResponseWriter, r *http.Request) {
+	code, err := parseStatusCode(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	image := h.statusImages.Image(r.Context(), code)
+	maxAge := statusImageMaxAge
+	if image.Placeholder {
+		maxAge = placeholderImageMaxAge
+	}
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("ETag", image.ETag)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", image.ModTime, bytes.NewReader(image.Data))
+}
+
+// parseStatusCode reads the code path parameter
+func parseStatusCode(r *http.Request) (int, error) {
+	code, err := strconv.Atoi(mux.Vars(r)["code"])
+	if err != nil || code < 100 || code > 599 {
+		return 0, ErrInvalidStatusCode
+	}
+	return code, nil
+}
+
+// ErrInvalidBaseURL is returned by SetBaseURL for anything but an absolute
+// http or https URL
+var ErrInvalidBaseURL = errors.New("base URL must be an absolute http or https URL")
+
+// SetBaseURL sets the public address of the server, such as
+// https://chat.example.com, that links in responses start with. Without
+// one, links are relative to the server. The request's Host header is never
+// used, since clients control it.
+func (h *Handler) SetBaseURL(base string) error {
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidBaseURL
+		}
+	}
+	h.baseURL = strings.TrimSuffix(base, "/")
+	return nil
+}
+
+// statusImageURL returns the URL of code's image on this server, absolute
+// when a base URL is set, so clients can load it directly
+func (h *Handler) statusImageURL(code int) string {
+	return fmt.Sprintf("%s%s/cat/%d", h.baseURL, apiPrefix, code)
+}
+
+// Helper function to get HTTP status description
+func getHTTPStatusDescription(code int) string {
+	if description, ok := statusDescriptions[code]; ok {
+		return description
+	}
+	return "Unknown Status"
+}
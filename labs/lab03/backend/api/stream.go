@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab03-backend/storage"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultHeartbeatInterval keeps idle SSE connections open through proxies
+const defaultHeartbeatInterval = 15 * time.Second
+
+// ErrInvalidLastEventID is returned for resume positions that are not event IDs
+var ErrInvalidLastEventID = errors.New("invalid Last-Event-ID")
+
+// StreamMessages handles GET /api/messages/stream
+//
+// It sends every message mutation as a Server-Sent Event whose event name is
+// the mutation type ("created", "updated", "deleted", "restored" or
+// "reacted") and whose data is the storage.Event as JSON. Clients resume with
+// the Last-Event-ID header (or the last_event_id query parameter); if the
+// requested events are no longer buffered a "reset" event tells the client
+// to reload the message list.
+func (h *Handler) StreamMessages(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, r, ErrStreamingUnsupported)
+		return
+	}
+
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	replay, events, cancel, complete := h.storage.Events().Subscribe(lastID)
+	defer cancel()
+
+	// SSE connections outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("failed to clear write deadline for event stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one event in text/event-stream framing
+func writeEvent(w http.ResponseWriter, event storage.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode event %d: %v", event.ID, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+// parseLastEventID reads the resume position sent by a reconnecting client
+func parseLastEventID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidLastEventID, raw)
+	}
+	return id, nil
+}
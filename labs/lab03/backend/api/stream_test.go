@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads SSE lines until a blank line and returns the non-comment fields
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			fields["comment"] = line
+			continue
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func TestStreamMessages(t *testing.T) {
+	handler := setupTestHandler()
+	handler.heartbeat = 50 * time.Millisecond
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	handler.storage.Create("testuser", "before connect")
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	if event := readEvent(t, reader); event["retry"] == "" {
+		t.Errorf("Expected retry hint first, got %v", event)
+	}
+
+	handler.storage.Update(1, "after connect")
+	event := readEvent(t, reader)
+	if event["event"] != "updated" || event["id"] != "2" {
+		t.Errorf("Expected updated event with id 2, got %v", event)
+	}
+
+	if event := readEvent(t, reader); event["comment"] != ": heartbeat" {
+		t.Errorf("Expected heartbeat, got %v", event)
+	}
+}
+
+func TestStreamMessagesResume(t *testing.T) {
+	handler := setupTestHandler()
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	handler.storage.Create("testuser", "one")
+	handler.storage.Create("testuser", "two")
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	readEvent(t, reader) // retry hint
+	event := readEvent(t, reader)
+	if event["event"] != "created" || event["id"] != "2" {
+		t.Errorf("Expected replay of created event 2, got %v", event)
+	}
+}
+
+func TestStreamMessagesDisconnect(t *testing.T) {
+	handler := setupTestHandler()
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/messages/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handler.storage.Events().SubscriberCount() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", handler.storage.Events().SubscriberCount())
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.storage.Events().SubscriberCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected subscriber to be removed after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
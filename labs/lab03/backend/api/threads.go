@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"lab03-backend/models"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// maxEmojiRunes fits the longest ZWJ sequences (e.g. family emoji)
+const maxEmojiRunes = 10
+
+// Reaction errors
+var (
+	ErrInvalidEmoji = errors.New("reaction must be a single emoji")
+	ErrUserRequired = errors.New("X-Username header is required")
+)
+
+// GetThread handles GET /api/messages/{id}/thread
+func (h *Handler) GetThread(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	thread, err := h.storage.Thread(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: thread})
+}
+
+// GetReactions handles GET /api/messages/{id}/reactions
+func (h *Handler) GetReactions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	reactions, err := h.storage.Reactions(id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: reactions})
+}
+
+// AddReaction handles PUT /api/messages/{id}/reactions/{emoji}
+func (h *Handler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	h.changeReaction(w, r, h.storage.React)
+}
+
+// RemoveReaction handles DELETE /api/messages/{id}/reactions/{emoji}
+func (h *Handler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	h.changeReaction(w, r, h.storage.Unreact)
+}
+
+// changeReaction applies the requesting user's reaction change and responds
+// with the message's aggregated reactions
+func (h *Handler) changeReaction(w http.ResponseWriter, r *http.Request, change func(id int, user, emoji string) ([]models.ReactionSummary, error)) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	emoji, err := parseEmoji(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	user := requestUser(r)
+	if user == "" {
+		h.writeError(w, r, ErrUserRequired)
+		return
+	}
+
+	reactions, err := change(id, user, emoji)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: reactions})
+}
+
+// parseEmoji reads the emoji path parameter. It accepts one emoji including
+// modifiers, variation selectors, keycaps and zero-width-joiner sequences.
+func parseEmoji(r *http.Request) (string, error) {
+	emoji := mux.Vars(r)["emoji"]
+	if emoji == "" || !utf8.ValidString(emoji) || utf8.RuneCountInString(emoji) > maxEmojiRunes {
+		return "", ErrInvalidEmoji
+	}
+
+	symbols := 0
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbols++
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf):
+			// Skin tones, variation selectors, keycap marks and joiners
+		case r == '#' || r == '*' || ('0' <= r && r <= '9'):
+			// Keycap bases
+		default:
+			return "", ErrInvalidEmoji
+		}
+	}
+	if symbols == 0 && !keycap(emoji) {
+		return "", ErrInvalidEmoji
+	}
+	return emoji, nil
+}
+
+// keycap reports whether emoji is a keycap sequence such as "1️⃣"
+func keycap(emoji string) bool {
+	last, _ := utf8.DecodeLastRuneInString(emoji)
+	return last == '\u20e3' && utf8.RuneCountInString(emoji) > 1
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestThreadsAndReactions(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if user != "" {
+			req.Header.Set("X-Username", user)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do("POST", "/api/messages", "", `{"username":"alice","content":"root"}`)
+	if rr := do("POST", "/api/messages", "", `{"username":"bob","content":"reply","parent_id":1}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected reply to be created, got %v", rr.Code)
+	}
+	if rr := do("POST", "/api/messages", "", `{"username":"bob","content":"reply","parent_id":42}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %v for missing parent, got %v", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	rr := do("GET", "/api/messages/1/thread", "", "")
+	var thread struct {
+		Data models.Thread `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&thread); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if rr.Code != http.StatusOK || len(thread.Data.Replies) != 1 {
+		t.Fatalf("Expected thread with 1 reply, got status %v with %d", rr.Code, len(thread.Data.Replies))
+	}
+
+	patch := httptest.NewRequest("PATCH", "/api/messages/2", bytes.NewBufferString(`{"parent_id":null}`))
+	patch.Header.Set("Content-Type", mergePatchContentType)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, patch)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v moving a reply, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	thumbs := "/api/messages/1/reactions/" + url.PathEscape("👍")
+	if rr := do("PUT", thumbs, "", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v without X-Username, got %v", http.StatusBadRequest, rr.Code)
+	}
+	if rr := do("PUT", "/api/messages/1/reactions/abc", "bob", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v for non-emoji, got %v", http.StatusBadRequest, rr.Code)
+	}
+	do("PUT", thumbs, "bob", "")
+	rr = do("PUT", thumbs, "carol", "")
+	var reactions struct {
+		Data []models.ReactionSummary `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&reactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if rr.Code != http.StatusOK || len(reactions.Data) != 1 || reactions.Data[0].Count != 2 {
+		t.Fatalf("Expected 2 reactions, got status %v with %+v", rr.Code, reactions.Data)
+	}
+
+	if rr := do("DELETE", thumbs, "bob", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %v removing a reaction, got %v", http.StatusOK, rr.Code)
+	}
+	rr = do("GET", "/api/messages/1/reactions", "", "")
+	if err := json.NewDecoder(rr.Body).Decode(&reactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(reactions.Data) != 1 || reactions.Data[0].Count != 1 {
+		t.Errorf("Expected 1 reaction left, got %+v", reactions.Data)
+	}
+}
+
+func TestParseEmoji(t *testing.T) {
+	valid := []string{"👍", "👍🏽", "❤️", "1️⃣", "🇫🇮", "👩‍👩‍👧‍👦"}
+	invalid := []string{"", "a", "+1", "👍 ", "1", "‍"}
+
+	for _, emoji := range valid {
+		if err := checkEmoji(emoji); err != nil {
+			t.Errorf("Expected %q to be accepted, got %v", emoji, err)
+		}
+	}
+	for _, emoji := range invalid {
+		if err := checkEmoji(emoji); err == nil {
+			t.Errorf("Expected %q to be rejected", emoji)
+		}
+	}
+}
+
+func checkEmoji(emoji string) error {
+	req := httptest.NewRequest("GET", "/", nil)
+	req = mux.SetURLVars(req, map[string]string{"emoji": emoji})
+	_, err := parseEmoji(req)
+	return err
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lab03-backend/openapi"
+	"mime"
+	"net/http"
+)
+
+// maxBodyBytes caps the body of every route that takes one, validated or
+// not; attachment uploads set their own cap
+const maxBodyBytes = 1 << 20
+
+// ErrValidationFailed is the summary error for requests that break the schema
+var ErrValidationFailed = errors.New("request validation failed")
+
+// GetOpenAPI handles GET /api/openapi.json
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.spec)
+}
+
+// limitBody caps the request body at maxBodyBytes. Reading past the cap
+// fails with *http.MaxBytesError, which bodyError maps to ErrBodyTooLarge.
+func limitBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next(w, r)
+	}
+}
+
+// bodyError maps a failure to read or decode a JSON body to an API error
+func bodyError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return ErrBodyTooLarge
+	}
+	return ErrInvalidJSON
+}
+
+// validateBody returns middleware that checks request bodies of mediaType
+// against schema before calling next. A missing Content-Type is treated as
+// mediaType; any other type is rejected.
+func (h *Handler) validateBody(schema *openapi.Schema, mediaType string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if got, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); got != "" && got != mediaType {
+				h.writeError(w, r, fmt.Errorf("%w: content type must be %s", ErrUnsupportedMediaType, mediaType))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err != nil {
+				h.writeError(w, r, ErrBodyTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var value interface{}
+			if err := json.Unmarshal(body, &value); err != nil {
+				h.writeError(w, r, ErrInvalidJSON)
+				return
+			}
+
+			if errs := h.spec.Validate(schema, value); len(errs) > 0 {
+				h.writeError(w, r, validationErrors(errs))
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/openapi"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetOpenAPI(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Could not decode document: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("Expected openapi %s, got %s", openapi.Version, doc.OpenAPI)
+	}
+
+	for _, rt := range handler.routes() {
+		item, ok := doc.Paths[apiPrefix+rt.Path]
+		if !ok {
+			t.Errorf("Path %s missing from document", rt.Path)
+			continue
+		}
+		if _, ok := (*item)[map[string]string{
+			"GET": "get", "POST": "post", "PUT": "put", "PATCH": "patch", "DELETE": "delete",
+		}[rt.Method]]; !ok {
+			t.Errorf("Operation %s %s missing from document", rt.Method, rt.Path)
+		}
+	}
+	for _, name := range []string{"Message", "CreateMessageRequest", "UpdateMessageRequest"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s missing from components", name)
+		}
+	}
+}
+
+func TestRequestValidationShape(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	handler.storage.Create("testuser", "original")
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		field  string
+	}{
+		{"POST", "/api/messages", `{"content":"hi"}`, "username"},
+		{"POST", "/api/messages", `{"username":"testuser","content":"  "}`, "content"},
+		{"POST", "/api/messages", `{"username":"testuser","content":"hi","admin":true}`, "admin"},
+		{"PUT", "/api/messages/1", `{"content":42}`, "content"},
+		{"PUT", "/api/messages/1", `{}`, "content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.body, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status %v, got %v", http.StatusBadRequest, rr.Code)
+			}
+			var response models.APIResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if response.Success || response.Error != ErrValidationFailed.Error() {
+				t.Errorf("Unexpected envelope %+v", response)
+			}
+			if len(response.Errors) != 1 || response.Errors[0].Field != tt.field {
+				t.Errorf("Expected one error for %s, got %v", tt.field, response.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package blobstore keeps immutable blobs on local disk under their SHA-256
+// digest, so identical uploads are stored once.
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Blob store errors
+var (
+	ErrTooLarge      = errors.New("blob exceeds size limit")
+	ErrBlobNotFound  = errors.New("blob not found")
+	ErrInvalidDigest = errors.New("invalid blob digest")
+)
+
+// Blob identifies stored content
+type Blob struct {
+	Digest string // Hex-encoded SHA-256 of the content
+	Size   int64
+}
+
+// Store is a directory of content-addressed blobs. Blobs live at
+// <dir>/<first two digest characters>/<digest>.
+type Store struct {
+	dir string
+}
+
+// New creates a store rooted at dir, creating the directory if needed
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Put stores everything read from r. Content larger than limit bytes is
+// rejected with ErrTooLarge and nothing is kept.
+func (s *Store) Put(r io.Reader, limit int64) (Blob, error) {
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	// Removing fails harmlessly once the file has been renamed into place
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, limit+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+	if size > limit {
+		return Blob{}, ErrTooLarge
+	}
+
+	blob := Blob{Digest: hex.EncodeToString(hash.Sum(nil)), Size: size}
+	path := s.path(blob.Digest)
+	if _, err := os.Stat(path); err == nil {
+		// Already stored by an earlier upload. Touching it keeps Sweep from
+		// taking it as unused before this upload is linked to a message.
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return Blob{}, err
+		}
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Blob{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Blob{}, err
+	}
+	return blob, nil
+}
+
+// Open returns the blob with the given digest for reading
+func (s *Store) Open(digest string) (*os.File, error) {
+	if !validDigest(digest) {
+		return nil, ErrInvalidDigest
+	}
+	file, err := os.Open(s.path(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+// Sweep removes the blobs whose digest is not in referenced and that were
+// last stored before cutoff, and returns how many were removed. The cutoff
+// spares blobs that were just uploaded but not yet linked to a message.
+func (s *Store) Sweep(referenced map[string]bool, cutoff time.Time) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		digest := entry.Name()
+		if entry.IsDir() || !validDigest(digest) || path != s.path(digest) || referenced[digest] {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// Only succeeds once the prefix directory is empty
+		os.Remove(filepath.Dir(path))
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// StartSweeper runs Sweep every interval in the background until the
+// returned stop function is called. Each run keeps the blobs referenced
+// returns and those stored within grace of the run.
+func (s *Store) StartSweeper(interval, grace time.Duration, referenced func() map[string]bool) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				removed, err := s.Sweep(referenced(), now.Add(-grace))
+				if err != nil {
+					log.Printf("blob sweep failed: %v", err)
+				}
+				if removed > 0 {
+					log.Printf("removed %d unreferenced blobs", removed)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (s *Store) path(digest string) string {
+	return filepath.Join(s.dir, digest[:2], digest)
+}
+
+// validDigest keeps digests from escaping the store directory
+func validDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
@@ -0,0 +1,102 @@
+package blobstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorePutAndOpen(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := store.Put(strings.NewReader("hello"), 10)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	const digest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if blob.Digest != digest || blob.Size != 5 {
+		t.Fatalf("Expected %s (5 bytes), got %+v", digest, blob)
+	}
+
+	again, err := store.Put(strings.NewReader("hello"), 10)
+	if err != nil || again != blob {
+		t.Errorf("Expected identical content to dedupe, got %+v, %v", again, err)
+	}
+
+	file, err := store.Open(digest)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	content, _ := io.ReadAll(file)
+	if string(content) != "hello" {
+		t.Errorf("Expected stored content, got %q", content)
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := New(dir)
+
+	if _, err := store.Put(strings.NewReader("too long"), 4); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected rejected upload to leave nothing behind, found %d entries", len(entries))
+	}
+
+	if _, err := store.Open("../" + filepath.Base(dir)); err != ErrInvalidDigest {
+		t.Errorf("Expected ErrInvalidDigest, got %v", err)
+	}
+	if _, err := store.Open(strings.Repeat("0", 64)); err != ErrBlobNotFound {
+		t.Errorf("Expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestStoreSweep(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := New(dir)
+
+	kept, _ := store.Put(strings.NewReader("kept"), 10)
+	orphan, _ := store.Put(strings.NewReader("orphan"), 10)
+	fresh, _ := store.Put(strings.NewReader("fresh"), 10)
+	reused, _ := store.Put(strings.NewReader("reused"), 10)
+
+	// Every blob but the fresh one was stored a day ago
+	old := time.Now().Add(-24 * time.Hour)
+	for _, blob := range []Blob{kept, orphan, reused} {
+		if err := os.Chtimes(store.path(blob.Digest), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Uploading existing content again marks it as recently stored
+	if _, err := store.Put(strings.NewReader("reused"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Sweep(map[string]bool{kept.Digest: true}, time.Now().Add(-time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected only the orphan to be removed, got %d, %v", removed, err)
+	}
+	if _, err := store.Open(orphan.Digest); err != ErrBlobNotFound {
+		t.Errorf("Expected the orphan to be gone, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(store.path(orphan.Digest))); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty prefix directory to be removed, got %v", err)
+	}
+	for _, blob := range []Blob{kept, fresh, reused} {
+		file, err := store.Open(blob.Digest)
+		if err != nil {
+			t.Errorf("Expected %s to be kept, got %v", blob.Digest, err)
+			continue
+		}
+		file.Close()
+	}
+}
@@ -0,0 +1,260 @@
+// Package httpcat serves http.cat status code images from a local mirror.
+// Images missing from the mirror are fetched from upstream once and kept;
+// codes upstream cannot provide get a generated placeholder instead.
+package httpcat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUpstream is where images missing from the mirror are fetched
+const DefaultUpstream = "https://http.cat/"
+
+// Fetch limits
+const (
+	maxImageBytes = 5 << 20
+	fetchTimeout  = 10 * time.Second
+	// missTTL stops every request for an unavailable code reaching upstream
+	missTTL = 10 * time.Minute
+)
+
+// Image is one status code image ready to serve
+type Image struct {
+	Data        []byte
+	ContentType string
+	ETag        string // Strong entity tag derived from Data
+	ModTime     time.Time
+	Placeholder bool // Generated because no real image was available
+}
+
+// fetch is an upstream request shared by concurrent callers
+type fetch struct {
+	done  chan struct{}
+	image *Image
+}
+
+// Mirror serves status images from a cache directory
+type Mirror struct {
+	dir      string // Empty keeps fetched images in memory only
+	upstream string // Empty disables fetching
+	client   *http.Client
+
+	mutex        sync.Mutex
+	images       map[int]*Image
+	placeholders map[int]*Image
+	misses       map[int]time.Time
+	inflight     map[int]*fetch
+}
+
+// New creates a mirror that keeps images in dir and fetches missing ones
+// from upstream, a URL prefix the status code is appended to. Either may be
+// empty: without dir nothing is written to disk, and without upstream only
+// mirrored images and placeholders are served.
+func New(dir, upstream string) *Mirror {
+	if upstream != "" && !strings.HasSuffix(upstream, "/") {
+		upstream += "/"
+	}
+	return &Mirror{
+		dir:          dir,
+		upstream:     upstream,
+		client:       &http.Client{Timeout: fetchTimeout},
+		images:       make(map[int]*Image),
+		placeholders: make(map[int]*Image),
+		misses:       make(map[int]time.Time),
+		inflight:     make(map[int]*fetch),
+	}
+}
+
+// Image returns the image for code. It never fails: when neither the mirror
+// nor upstream has an image, a placeholder is generated.
+func (m *Mirror) Image(ctx context.Context, code int) *Image {
+	m.mutex.Lock()
+	if image, ok := m.images[code]; ok {
+		m.mutex.Unlock()
+		return image
+	}
+	m.mutex.Unlock()
+
+	if image := m.load(code); image != nil {
+		m.remember(code, image)
+		return image
+	}
+	if image := m.fetch(ctx, code); image != nil {
+		return image
+	}
+	return m.placeholder(code)
+}
+
+// load reads code's image from the cache directory
+func (m *Mirror) load(code int) *Image {
+	if m.dir == "" {
+		return nil
+	}
+	path := m.path(code)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read cached status image %s: %v", path, err)
+		}
+		return nil
+	}
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	image, ok := newImage(data, modTime)
+	if !ok {
+		log.Printf("ignoring cached status image %s: not an image", path)
+		return nil
+	}
+	return image
+}
+
+// fetch downloads code's image from upstream. Concurrent calls for the same
+// code share one request, and failures are remembered for missTTL.
+func (m *Mirror) fetch(ctx context.Context, code int) *Image {
+	if m.upstream == "" {
+		return nil
+	}
+
+	m.mutex.Lock()
+	if missed, ok := m.misses[code]; ok && time.Since(missed) < missTTL {
+		m.mutex.Unlock()
+		return nil
+	}
+	if f, ok := m.inflight[code]; ok {
+		m.mutex.Unlock()
+		select {
+		case <-f.done:
+			return f.image
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	f := &fetch{done: make(chan struct{})}
+	m.inflight[code] = f
+	m.mutex.Unlock()
+
+	// The request outlives any one caller so waiting callers still get it
+	image, err := m.download(code)
+	if err != nil {
+		log.Printf("failed to fetch status image %d: %v", code, err)
+	} else if err := m.store(code, image.Data); err != nil {
+		log.Printf("failed to cache status image %d: %v", code, err)
+	}
+
+	m.mutex.Lock()
+	delete(m.inflight, code)
+	if image != nil {
+		m.images[code] = image
+	} else {
+		m.misses[code] = time.Now()
+	}
+	m.mutex.Unlock()
+
+	f.image = image
+	close(f.done)
+	return image
+}
+
+func (m *Mirror) download(code int) (*Image, error) {
+	resp, err := m.client.Get(fmt.Sprintf("%s%d", m.upstream, code))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", maxImageBytes)
+	}
+	image, ok := newImage(data, time.Now())
+	if !ok {
+		return nil, errors.New("upstream did not return an image")
+	}
+	return image, nil
+}
+
+// store writes an image into the cache directory atomically
+func (m *Mirror) store(code int, data []byte) error {
+	if m.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(m.dir, ".fetch-*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file has been renamed into place
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path(code))
+}
+
+func (m *Mirror) placeholder(code int) *Image {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if image, ok := m.placeholders[code]; ok {
+		return image
+	}
+	image, _ := newImage(placeholderPNG(code), time.Now())
+	image.Placeholder = true
+	m.placeholders[code] = image
+	return image
+}
+
+func (m *Mirror) remember(code int, image *Image) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.images[code] = image
+}
+
+// path is where code's image lives in the cache directory. http.cat serves
+// JPEG, but the type is always sniffed from the content.
+func (m *Mirror) path(code int) string {
+	return filepath.Join(m.dir, fmt.Sprintf("%d.jpg", code))
+}
+
+// newImage wraps data if it sniffs as an image
+func newImage(data []byte, modTime time.Time) (*Image, bool) {
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, false
+	}
+	sum := sha256.Sum256(data)
+	return &Image{
+		Data:        data,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		ModTime:     modTime,
+	}, true
+}
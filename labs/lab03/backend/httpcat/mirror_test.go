@@ -0,0 +1,110 @@
+package httpcat
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jpegData sniffs as image/jpeg
+var jpegData = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00fake cat")
+
+func TestPlaceholder(t *testing.T) {
+	mirror := New("", "")
+	image := mirror.Image(context.Background(), 404)
+
+	if !image.Placeholder || image.ContentType != "image/png" {
+		t.Fatalf("Expected PNG placeholder, got %+v", image)
+	}
+	decoded, err := png.Decode(bytes.NewReader(image.Data))
+	if err != nil {
+		t.Fatalf("Placeholder is not a valid PNG: %v", err)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() != placeholderWidth || bounds.Dy() != placeholderHeight {
+		t.Errorf("Unexpected placeholder size %v", bounds)
+	}
+	if other := mirror.Image(context.Background(), 500); other.ETag == image.ETag {
+		t.Error("Expected different codes to get different placeholders")
+	}
+}
+
+func TestMirrorServesCachedImages(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "200.jpg"), jpegData, 0o644)
+	os.WriteFile(filepath.Join(dir, "201.jpg"), []byte("<html>not a cat</html>"), 0o644)
+	mirror := New(dir, "")
+
+	image := mirror.Image(context.Background(), 200)
+	if image.Placeholder || image.ContentType != "image/jpeg" || !bytes.Equal(image.Data, jpegData) {
+		t.Errorf("Expected cached JPEG, got %+v", image)
+	}
+	if !mirror.Image(context.Background(), 201).Placeholder {
+		t.Error("Expected non-image cache files to be ignored")
+	}
+}
+
+func TestMirrorFetchesFromUpstream(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/200":
+			<-release
+			w.Write(jpegData)
+		case "/202":
+			w.Write([]byte("<html>error page</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	mirror := New(dir, upstream.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if image := mirror.Image(context.Background(), 200); image.Placeholder {
+				t.Error("Expected fetched image")
+			}
+		}()
+	}
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected concurrent requests to share one fetch, got %d", got)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "200.jpg")); err != nil || !bytes.Equal(data, jpegData) {
+		t.Errorf("Expected fetched image to be cached on disk, got %v", err)
+	}
+
+	if !mirror.Image(context.Background(), 599).Placeholder {
+		t.Error("Expected placeholder when upstream has no image")
+	}
+	mirror.Image(context.Background(), 599)
+	if !mirror.Image(context.Background(), 202).Placeholder {
+		t.Error("Expected placeholder when upstream returns something other than an image")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected upstream misses to be remembered, got %d requests", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "202.jpg")); !os.IsNotExist(err) {
+		t.Error("Expected non-images not to be cached")
+	}
+}
@@ -0,0 +1,78 @@
+package httpcat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+)
+
+// Placeholder dimensions, roughly the aspect ratio of http.cat images
+const (
+	placeholderWidth  = 375
+	placeholderHeight = 300
+	glyphScale        = 12 // Pixels per font dot
+)
+
+// digitGlyphs is a 5x7 bitmap font for the digits of a status code
+var digitGlyphs = [10][7]string{
+	{" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	{"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	{" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	{"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	{"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	{"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	{"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	{"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	{" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	{" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+}
+
+// classColors gives each status class (1xx to 5xx) its own background
+var classColors = map[int]color.RGBA{
+	1: {0x60, 0x7d, 0x8b, 0xff},
+	2: {0x2e, 0x7d, 0x32, 0xff},
+	3: {0x15, 0x65, 0xc0, 0xff},
+	4: {0xef, 0x6c, 0x00, 0xff},
+	5: {0xc6, 0x28, 0x28, 0xff},
+}
+
+// placeholderPNG draws code in large digits on its class colour
+func placeholderPNG(code int) []byte {
+	background, ok := classColors[code/100]
+	if !ok {
+		background = classColors[1]
+	}
+	palette := color.Palette{background, color.White}
+	img := image.NewPaletted(image.Rect(0, 0, placeholderWidth, placeholderHeight), palette)
+
+	digits := strconv.Itoa(code)
+	glyphWidth := 5 * glyphScale
+	gap := glyphScale * 2
+	textWidth := len(digits)*glyphWidth + (len(digits)-1)*gap
+	left := (placeholderWidth - textWidth) / 2
+	top := (placeholderHeight - 7*glyphScale) / 2
+
+	for i, digit := range digits {
+		glyph := digitGlyphs[digit-'0']
+		x0 := left + i*(glyphWidth+gap)
+		for row, line := range glyph {
+			for col, dot := range line {
+				if dot != '#' {
+					continue
+				}
+				for y := 0; y < glyphScale; y++ {
+					for x := 0; x < glyphScale; x++ {
+						img.SetColorIndex(x0+col*glyphScale+x, top+row*glyphScale+y, 1)
+					}
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	// Encoding an in-memory paletted image cannot fail
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
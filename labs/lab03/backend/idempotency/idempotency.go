@@ -0,0 +1,145 @@
+// Package idempotency remembers the results of requests sent with an
+// Idempotency-Key so that retries replay the original result instead of
+// repeating the side effect.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrKeyReused is returned when a key is sent again with a different request
+var ErrKeyReused = errors.New("idempotency key reused with a different request")
+
+type entry struct {
+	fingerprint string
+	result      interface{}
+	done        chan struct{} // Closed once the first request finishes
+	expires     time.Time     // Zero while the first request is in flight
+}
+
+// Store holds the result of each key for a fixed TTL
+type Store struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mutex     sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// New creates a store that keeps results for ttl
+func New(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Claim is held by the one request allowed to act on a key. It must end with
+// exactly one call to Complete or Release.
+type Claim struct {
+	store *Store
+	key   string
+	entry *entry
+}
+
+// Begin looks up key. If a request with the same fingerprint already
+// finished, its result is returned. If one is still in flight, Begin waits
+// for it. Otherwise the caller gets a Claim and must perform the request.
+// A key seen with a different fingerprint yields ErrKeyReused.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Claim, interface{}, error) {
+	for {
+		s.mutex.Lock()
+		now := s.now()
+		if now.Sub(s.lastSweep) >= s.ttl {
+			s.sweep(now)
+		}
+
+		e, ok := s.entries[key]
+		if ok && !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(s.entries, key)
+			ok = false
+		}
+		if !ok {
+			e = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.entries[key] = e
+			s.mutex.Unlock()
+			return &Claim{store: s, key: key, entry: e}, nil, nil
+		}
+		s.mutex.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, nil, ErrKeyReused
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if result := s.result(e); result != nil {
+			return nil, result, nil
+		}
+		// The first request failed and released the key; try to claim it
+	}
+}
+
+// result returns the stored result of a finished entry, or nil if the entry
+// was released
+func (s *Store) result(e *entry) interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return e.result
+}
+
+// Complete stores result for the key and wakes any waiting duplicates
+func (c *Claim) Complete(result interface{}) {
+	c.store.mutex.Lock()
+	c.entry.result = result
+	c.entry.expires = c.store.now().Add(c.store.ttl)
+	c.store.mutex.Unlock()
+
+	close(c.entry.done)
+}
+
+// Release forgets the key so the request can be retried, for example after
+// it failed without side effects
+func (c *Claim) Release() {
+	c.store.mutex.Lock()
+	if c.store.entries[c.key] == c.entry {
+		delete(c.store.entries, c.key)
+	}
+	c.store.mutex.Unlock()
+
+	close(c.entry.done)
+}
+
+// Len returns the number of tracked keys
+func (s *Store) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.entries)
+}
+
+// Sweep evicts results whose TTL has passed
+func (s *Store) Sweep(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep(now)
+}
+
+func (s *Store) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
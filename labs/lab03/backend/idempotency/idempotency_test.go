@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreReplaysResult(t *testing.T) {
+	store := New(time.Hour)
+	ctx := context.Background()
+
+	claim, _, err := store.Begin(ctx, "k", "a")
+	if err != nil || claim == nil {
+		t.Fatalf("expected a claim for a new key, got %v, %v", claim, err)
+	}
+	claim.Complete("first")
+
+	claim, result, err := store.Begin(ctx, "k", "a")
+	if err != nil || claim != nil {
+		t.Fatalf("expected a replay, got claim %v, err %v", claim, err)
+	}
+	if result != "first" {
+		t.Errorf("expected stored result, got %v", result)
+	}
+
+	if _, _, err := store.Begin(ctx, "k", "b"); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("expected ErrKeyReused, got %v", err)
+	}
+}
+
+func TestStoreRelease(t *testing.T) {
+	store := New(time.Hour)
+	ctx := context.Background()
+
+	claim, _, _ := store.Begin(ctx, "k", "a")
+	claim.Release()
+
+	claim, _, err := store.Begin(ctx, "k", "b")
+	if err != nil || claim == nil {
+		t.Fatalf("expected a released key to be claimable, got %v, %v", claim, err)
+	}
+}
+
+func TestStoreConcurrentDuplicatesWait(t *testing.T) {
+	store := New(time.Hour)
+	ctx := context.Background()
+
+	first, _, _ := store.Begin(ctx, "k", "a")
+
+	var wg sync.WaitGroup
+	results := make(chan interface{}, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claim, result, err := store.Begin(ctx, "k", "a")
+			if err != nil || claim != nil {
+				t.Errorf("expected duplicates to wait for a replay, got %v, %v", claim, err)
+				return
+			}
+			results <- result
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	first.Complete(42)
+	wg.Wait()
+	close(results)
+
+	for result := range results {
+		if result != 42 {
+			t.Errorf("expected 42, got %v", result)
+		}
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	store := New(time.Minute)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	claim, _, _ := store.Begin(ctx, "k", "a")
+	claim.Complete("first")
+
+	now = now.Add(time.Minute)
+	claim, _, err := store.Begin(ctx, "k", "b")
+	if err != nil || claim == nil {
+		t.Fatalf("expected an expired key to be claimable, got %v, %v", claim, err)
+	}
+	claim.Release()
+
+	store.Begin(ctx, "other", "a")
+	store.Sweep(now.Add(2 * time.Minute))
+	if store.Len() != 1 {
+		t.Errorf("expected in-flight keys to survive a sweep, got %d keys", store.Len())
+	}
+}
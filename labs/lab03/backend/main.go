@@ -1,21 +1,125 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"lab03-backend/api"
+	"lab03-backend/blobstore"
+	"lab03-backend/httpcat"
+	"lab03-backend/storage"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Paths of persistent data, relative to the working directory
+const (
+	attachmentDir  = "data/attachments"
+	snapshotPath   = "data/snapshot.json"
+	statusImageDir = "data/http-cat"
+)
+
+// defaultBaseURL is where clients reach the server unless BASE_URL says
+// otherwise, such as behind a proxy
+const defaultBaseURL = "http://localhost:8080"
+
+// snapshotInterval bounds how much is lost if the server crashes
+const snapshotInterval = time.Minute
+
+// Unreferenced attachment blobs are removed hourly, once they are older
+// than any upload still waiting to be linked to its message
+const (
+	blobSweepInterval = time.Hour
+	blobSweepGrace    = time.Hour
 )
 
 func main() {
-	// TODO: Create a new memory storage instance
-	// TODO: Create a new API handler with the storage
-	// TODO: Setup routes using the handler
-	// TODO: Configure server with:
-	//   - Address: ":8080"
-	//   - Handler: the router
-	//   - ReadTimeout: 15 seconds
-	//   - WriteTimeout: 15 seconds
-	//   - IdleTimeout: 60 seconds
-	// TODO: Add logging to show server is starting
-	// TODO: Start the server and handle any errors
-
-	log.Println("TODO: Implement main function")
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves until it is interrupted or the server fails. Returning, rather
+// than exiting, runs the deferred cleanup such as the final snapshot.
+func run() error {
+	// One JSON object per line, including the log.Printf calls elsewhere
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	store := storage.NewMemoryStorage()
+	switch err := store.LoadSnapshotFile(snapshotPath); {
+	case err == nil:
+		log.Printf("Loaded %d messages from %s", store.Count(), snapshotPath)
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	stopPurger := store.StartPurger(time.Minute)
+	defer stopPurger()
+
+	snapshotter := storage.NewSnapshotter(store, snapshotPath)
+	// Stopping writes a final snapshot, so a clean shutdown loses nothing
+	stopSnapshots := snapshotter.Start(snapshotInterval)
+	defer stopSnapshots()
+
+	blobs, err := blobstore.New(attachmentDir)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment store: %w", err)
+	}
+	// Purged messages leave their blobs behind; remove the ones nothing
+	// refers to any more
+	stopSweeper := blobs.StartSweeper(blobSweepInterval, blobSweepGrace, store.AttachmentDigests)
+	defer stopSweeper()
+
+	handler := api.NewHandler(store)
+	handler.SetAttachmentStore(blobs, api.DefaultMaxAttachmentBytes)
+	handler.SetSnapshotter(snapshotter)
+	handler.SetStatusImages(httpcat.New(statusImageDir, httpcat.DefaultUpstream))
+	handler.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if err := handler.SetBaseURL(baseURL); err != nil {
+		return fmt.Errorf("BASE_URL: %w", err)
+	}
+	handler.SetLogger(logger)
+	router := handler.SetupRoutes()
+
+	server := &http.Server{
+		Addr:         ":8080",
+		Handler:      router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	}
+
+	// Event streams never finish on their own, so don't wait for them forever
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not complete: %v", err)
+	}
+	return nil
 }
@@ -1,57 +1,189 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 // Message represents a chat message
 type Message struct {
-	// TODO: Add ID field of type int with json tag "id"
-	// TODO: Add Username field of type string with json tag "username"
-	// TODO: Add Content field of type string with json tag "content"
-	// TODO: Add Timestamp field of type time.Time with json tag "timestamp"
+	ID        int        `json:"id"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	Username  string     `json:"username"`
+	Content   string     `json:"content"`
+	Timestamp time.Time  `json:"timestamp"`
+	Version   int        `json:"version"`
+	EditedBy  string     `json:"edited_by,omitempty"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Revision is a snapshot of one version of a message
+type Revision struct {
+	Version  int       `json:"version"`
+	Username string    `json:"username"`
+	Content  string    `json:"content"`
+	EditedBy string    `json:"edited_by"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// Thread is a message and every reply beneath it. Replies are ordered by
+// timestamp; each one's ParentID places it in the tree. Deleted messages
+// that still have replies appear with their author and content removed.
+type Thread struct {
+	Message *Message   `json:"message"`
+	Replies []*Message `json:"replies"`
+}
+
+// ReactionSummary aggregates one emoji's reactions to a message
+type ReactionSummary struct {
+	Emoji string   `json:"emoji"`
+	Count int      `json:"count"`
+	Users []string `json:"users"`
+}
+
+// Attachment is a file shared in a message. Its content is stored once per
+// SHA-256 digest and downloaded from URL.
+type Attachment struct {
+	ID          int       `json:"id"`
+	MessageID   int       `json:"message_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Flag is a message held for moderator review
+type Flag struct {
+	ID        int          `json:"id"`
+	MessageID int          `json:"message_id"`
+	Reasons   []FlagReason `json:"reasons"`
+	FlaggedAt time.Time    `json:"flagged_at"`
+	Message   *Message     `json:"message,omitempty"` // Current version of the message
+}
+
+// FlagReason is one moderation rule that flagged a message
+type FlagReason struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// ReviewRequest resolves a flag in the review queue
+type ReviewRequest struct {
+	Action string `json:"action" validate:"required"` // "approve" keeps the message, "remove" deletes it
 }
 
 // CreateMessageRequest represents the request to create a new message
 type CreateMessageRequest struct {
-	// TODO: Add Username field of type string with json tag "username" and validation tag "required"
-	// TODO: Add Content field of type string with json tag "content" and validation tag "required"
+	Username string `json:"username" validate:"required"`
+	Content  string `json:"content" validate:"required"`
+	ParentID *int   `json:"parent_id,omitempty"` // Message this replies to
 }
 
 // UpdateMessageRequest represents the request to update a message
 type UpdateMessageRequest struct {
-	// TODO: Add Content field of type string with json tag "content" and validation tag "required"
+	Content string `json:"content" validate:"required"`
 }
 
 // HTTPStatusResponse represents the response for HTTP status code endpoint
 type HTTPStatusResponse struct {
-	// TODO: Add StatusCode field of type int with json tag "status_code"
-	// TODO: Add ImageURL field of type string with json tag "image_url"
-	// TODO: Add Description field of type string with json tag "description"
+	StatusCode  int    `json:"status_code"`
+	ImageURL    string `json:"image_url"`
+	Description string `json:"description"`
 }
 
 // APIResponse represents a generic API response
 type APIResponse struct {
-	// TODO: Add Success field of type bool with json tag "success"
-	// TODO: Add Data field of type interface{} with json tag "data,omitempty"
-	// TODO: Add Error field of type string with json tag "error,omitempty"
+	Success   bool         `json:"success"`
+	Data      interface{}  `json:"data,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Meta      *PageMeta    `json:"meta,omitempty"`
+	RequestID string       `json:"request_id,omitempty"` // Set on errors to match them with server logs
 }
 
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"` // Extension member to match errors with server logs
+}
+
+// FieldError describes a problem with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// PageMeta describes one page of a paginated list response
+type PageMeta struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// Validation errors
+var (
+	ErrUsernameRequired = &FieldError{Field: "username", Message: "is required"}
+	ErrContentRequired  = &FieldError{Field: "content", Message: "is required"}
+	ErrInvalidReview    = &FieldError{Field: "action", Message: "must be approve or remove"}
+)
+
 // NewMessage creates a new message with the current timestamp
 func NewMessage(id int, username, content string) *Message {
-	// TODO: Return a new Message instance with provided parameters and current timestamp
-	return nil
+	return &Message{
+		ID:        id,
+		Username:  username,
+		Content:   content,
+		Timestamp: time.Now(),
+		Version:   1,
+	}
 }
 
 // Validate checks if the create message request is valid
 func (r *CreateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Username is not empty
-	// Check if Content is not empty
-	// Return appropriate error messages
+	if strings.TrimSpace(r.Username) == "" {
+		return ErrUsernameRequired
+	}
+	if strings.TrimSpace(r.Content) == "" {
+		return ErrContentRequired
+	}
+	return nil
+}
+
+// Review actions
+const (
+	ReviewApprove = "approve"
+	ReviewRemove  = "remove"
+)
+
+// Validate checks if the review request is valid
+func (r *ReviewRequest) Validate() error {
+	if r.Action != ReviewApprove && r.Action != ReviewRemove {
+		return ErrInvalidReview
+	}
 	return nil
 }
 
 // Validate checks if the update message request is valid
 func (r *UpdateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Content is not empty
-	// Return appropriate error messages
+	if strings.TrimSpace(r.Content) == "" {
+		return ErrContentRequired
+	}
 	return nil
 }
@@ -0,0 +1,98 @@
+// Package moderation screens message content with a chain of rules. Each
+// rule decides what happens when it matches: the message can be flagged for
+// review, have the offending parts masked, or be rejected outright.
+package moderation
+
+// Action is what a rule does with content it matches
+type Action string
+
+// Actions in increasing severity
+const (
+	Flag   Action = "flag"   // Store the content unchanged and queue it for review
+	Mask   Action = "mask"   // Store the content with the matched parts hidden
+	Reject Action = "reject" // Refuse to store the content
+)
+
+// Finding records one rule that matched
+type Finding struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Rule inspects content. When it matches, it returns a finding and the
+// content with the matched parts masked; the chain only uses the masked
+// content if the finding's action is Mask.
+type Rule interface {
+	Name() string
+	Check(content string) (masked string, finding *Finding)
+}
+
+// Result is the outcome of running content through a chain
+type Result struct {
+	Content  string    // Content to store, after masking
+	Findings []Finding // Every rule that matched, in chain order
+	Rejected bool      // A Reject rule matched; Content must not be stored
+}
+
+// Flagged reports whether a Flag rule matched
+func (r Result) Flagged() bool {
+	for _, finding := range r.Findings {
+		if finding.Action == Flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejection returns the finding that rejected the content, if any
+func (r Result) Rejection() *Finding {
+	for i := range r.Findings {
+		if r.Findings[i].Action == Reject {
+			return &r.Findings[i]
+		}
+	}
+	return nil
+}
+
+// Chain runs rules in order. Masking rules see the content as masked by the
+// rules before them; the first rejection stops the chain.
+type Chain struct {
+	rules []Rule
+}
+
+// NewChain creates a chain of rules
+func NewChain(rules ...Rule) *Chain {
+	return &Chain{rules: rules}
+}
+
+// Default is the chain the API uses unless configured otherwise: blocked
+// words are masked, messages with more than three links are rejected, and
+// long runs of one character are flagged as likely spam.
+func Default() *Chain {
+	return NewChain(
+		NewWordList(Mask, DefaultBlockedWords...),
+		NewLinkLimit(3, Reject),
+		NewRepeatedChars(10, Flag),
+	)
+}
+
+// Moderate runs content through every rule in the chain
+func (c *Chain) Moderate(content string) Result {
+	result := Result{Content: content}
+	for _, rule := range c.rules {
+		masked, finding := rule.Check(result.Content)
+		if finding == nil {
+			continue
+		}
+		result.Findings = append(result.Findings, *finding)
+		switch finding.Action {
+		case Mask:
+			result.Content = masked
+		case Reject:
+			result.Rejected = true
+			return result
+		}
+	}
+	return result
+}
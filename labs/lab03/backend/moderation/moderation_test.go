@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWordList(t *testing.T) {
+	rule := NewWordList(Mask, "shit", "bitch")
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"clean", "hello there", "hello there"},
+		{"plain", "oh shit", "oh ****"},
+		{"case", "Oh SHIT", "Oh ****"},
+		{"leetspeak", "oh sh1t and b!tch", "oh **** and *****"},
+		{"stretched", "oh shiiiiit", "oh ********"},
+		{"trailing punctuation", "shit!", "****!"},
+		{"inside another word", "shitake", "shitake"},
+		{"innocent collapse", "shitt", "shitt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, finding := rule.Check(tt.content)
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if (finding != nil) != (tt.want != tt.content) {
+				t.Errorf("Expected finding %v, got %+v", tt.want != tt.content, finding)
+			}
+		})
+	}
+}
+
+func TestLinkLimit(t *testing.T) {
+	rule := NewLinkLimit(1, Mask)
+
+	if _, finding := rule.Check("see https://example.com"); finding != nil {
+		t.Errorf("Expected one link to pass, got %+v", finding)
+	}
+	masked, finding := rule.Check("see https://a.example and www.b.example")
+	if finding == nil || masked != "see https://a.example and [link removed]" {
+		t.Errorf("Expected second link removed, got %q (%+v)", masked, finding)
+	}
+}
+
+func TestRepeatedChars(t *testing.T) {
+	rule := NewRepeatedChars(3, Flag)
+
+	if _, finding := rule.Check("cool!!!"); finding != nil {
+		t.Errorf("Expected short runs to pass, got %+v", finding)
+	}
+	masked, finding := rule.Check("nooooOOOO")
+	if finding == nil || masked != "nooo" {
+		t.Errorf("Expected run shortened to %q, got %q (%+v)", "nooo", masked, finding)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := NewChain(
+		NewWordList(Mask, "shit"),
+		NewRepeatedChars(3, Flag),
+		NewLinkLimit(0, Reject),
+	)
+
+	result := chain.Moderate("shit happensssss")
+	if result.Rejected || !result.Flagged() || result.Content != "**** happensssss" {
+		t.Errorf("Expected masked and flagged content, got %+v", result)
+	}
+	if len(result.Findings) != 2 {
+		t.Errorf("Expected 2 findings, got %d", len(result.Findings))
+	}
+
+	result = chain.Moderate("visit www.example.com")
+	if rejection := result.Rejection(); !result.Rejected || rejection == nil || rejection.Rule != "link-limit" {
+		t.Errorf("Expected link-limit rejection, got %+v", result)
+	}
+
+	if result := Default().Moderate("just a normal message"); len(result.Findings) != 0 {
+		t.Errorf("Expected no findings, got %+v", result.Findings)
+	}
+	if result := Default().Moderate(strings.Repeat("a", 20)); !result.Flagged() {
+		t.Error("Expected spam to be flagged by the default chain")
+	}
+}
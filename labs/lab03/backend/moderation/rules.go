@@ -0,0 +1,248 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultBlockedWords is a small starter list of English profanity
+var DefaultBlockedWords = []string{
+	"asshole", "bastard", "bitch", "bullshit", "cunt", "dickhead",
+	"fuck", "fucker", "fucking", "motherfucker", "shit", "twat", "wanker",
+}
+
+// leetspeak maps look-alike characters back to the letters they stand for
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '+': 't', '|': 'l',
+}
+
+// WordList matches blocked words, seeing through case, leetspeak
+// substitutions ("sh1t") and stretched letters ("shiiiit")
+type WordList struct {
+	action Action
+	words  map[string]bool // Normalised blocked words
+	runs   map[string]bool // Blocked words with repeated letters collapsed
+}
+
+// NewWordList creates a word list rule that applies action to any of words
+func NewWordList(action Action, words ...string) *WordList {
+	w := &WordList{
+		action: action,
+		words:  make(map[string]bool, len(words)),
+		runs:   make(map[string]bool, len(words)),
+	}
+	for _, word := range words {
+		normalised := normalise(word)
+		w.words[normalised] = true
+		w.runs[collapseRuns(normalised)] = true
+	}
+	return w
+}
+
+// Name identifies the rule in findings
+func (w *WordList) Name() string {
+	return "word-list"
+}
+
+// Check masks every blocked word with asterisks
+func (w *WordList) Check(content string) (string, *Finding) {
+	runes := []rune(content)
+	matches := 0
+
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		// Trailing punctuation such as "!" usually ends a sentence rather
+		// than standing in for a letter, so also try the word without it
+		for stop := end; stop > start; stop-- {
+			if w.blocked(string(runes[start:stop])) {
+				for i := start; i < stop; i++ {
+					runes[i] = '*'
+				}
+				matches++
+				break
+			}
+			if _, symbol := leetspeak[runes[stop-1]]; !symbol || unicode.IsDigit(runes[stop-1]) {
+				break
+			}
+		}
+		start = end
+	}
+
+	if matches == 0 {
+		return content, nil
+	}
+	return string(runes), &Finding{
+		Rule:   w.Name(),
+		Action: w.action,
+		Reason: plural(matches, "blocked word"),
+	}
+}
+
+func (w *WordList) blocked(token string) bool {
+	normalised := normalise(token)
+	if w.words[normalised] {
+		return true
+	}
+	// Only compare collapsed forms when letters were stretched, so that
+	// collapsing cannot turn an innocent word into a blocked one
+	return hasRun(normalised, 3) && w.runs[collapseRuns(normalised)]
+}
+
+// LinkLimit matches messages with more than a maximum number of links
+type LinkLimit struct {
+	action Action
+	limit  int
+}
+
+// linkPattern finds http(s) URLs and bare www. addresses
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>]+`)
+
+// NewLinkLimit creates a rule that applies action to messages with more
+// than limit links
+func NewLinkLimit(limit int, action Action) *LinkLimit {
+	return &LinkLimit{action: action, limit: limit}
+}
+
+// Name identifies the rule in findings
+func (l *LinkLimit) Name() string {
+	return "link-limit"
+}
+
+// Check masks the links beyond the limit
+func (l *LinkLimit) Check(content string) (string, *Finding) {
+	links := linkPattern.FindAllStringIndex(content, -1)
+	if len(links) <= l.limit {
+		return content, nil
+	}
+
+	seen := 0
+	masked := linkPattern.ReplaceAllStringFunc(content, func(link string) string {
+		seen++
+		if seen <= l.limit {
+			return link
+		}
+		return "[link removed]"
+	})
+	return masked, &Finding{
+		Rule:   l.Name(),
+		Action: l.action,
+		Reason: fmt.Sprintf("%s, at most %d allowed", plural(len(links), "link"), l.limit),
+	}
+}
+
+// RepeatedChars matches runs of one character longer than a maximum, a
+// common sign of keyboard-mashing spam
+type RepeatedChars struct {
+	action Action
+	maxRun int
+}
+
+// NewRepeatedChars creates a rule that applies action to content repeating
+// a character more than maxRun times in a row
+func NewRepeatedChars(maxRun int, action Action) *RepeatedChars {
+	return &RepeatedChars{action: action, maxRun: maxRun}
+}
+
+// Name identifies the rule in findings
+func (r *RepeatedChars) Name() string {
+	return "repeated-characters"
+}
+
+// Check shortens every overlong run to the maximum length
+func (r *RepeatedChars) Check(content string) (string, *Finding) {
+	var masked strings.Builder
+	longest := 0
+	run := 0
+	var previous rune
+
+	for i, current := range content {
+		if i > 0 && unicode.ToLower(current) == unicode.ToLower(previous) && !unicode.IsSpace(current) {
+			run++
+		} else {
+			run = 1
+		}
+		previous = current
+		longest = max(longest, run)
+		if run <= r.maxRun {
+			masked.WriteRune(current)
+		}
+	}
+
+	if longest <= r.maxRun {
+		return content, nil
+	}
+	return masked.String(), &Finding{
+		Rule:   r.Name(),
+		Action: r.action,
+		Reason: fmt.Sprintf("a character repeats %d times in a row", longest),
+	}
+}
+
+// isWordRune reports whether r can be part of a word, including the
+// symbols leetspeak uses for letters
+func isWordRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return true
+	}
+	_, ok := leetspeak[r]
+	return ok
+}
+
+// normalise lower-cases a word and undoes leetspeak substitutions
+func normalise(word string) string {
+	return strings.Map(func(r rune) rune {
+		if letter, ok := leetspeak[r]; ok {
+			return letter
+		}
+		return unicode.ToLower(r)
+	}, word)
+}
+
+// collapseRuns replaces every run of one letter with a single letter
+func collapseRuns(word string) string {
+	var b strings.Builder
+	var previous rune
+	for i, r := range word {
+		if i == 0 || r != previous {
+			b.WriteRune(r)
+		}
+		previous = r
+	}
+	return b.String()
+}
+
+// hasRun reports whether word repeats a letter at least n times in a row
+func hasRun(word string, n int) bool {
+	run := 0
+	var previous rune
+	for i, r := range word {
+		if i > 0 && r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		if run >= n {
+			return true
+		}
+		previous = r
+	}
+	return false
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,164 @@
+// Package openapi builds an OpenAPI 3 document from Go types and validates
+// decoded JSON values against the generated schemas.
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI specification version the documents conform to
+const Version = "3.0.3"
+
+// Document is the root of an OpenAPI 3 description
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info carries API metadata
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components holds reusable schemas referenced with $ref and the security
+// schemes operations may require
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// SecurityRequirement names the security schemes an operation requires,
+// each with its required scopes
+type SecurityRequirement map[string][]string
+
+// PathItem holds the operations available on one path, keyed by lower-case
+// HTTP method as the specification requires
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter describes a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the accepted request payloads by media type
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes one possible response
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType pairs a media type with its schema
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// New creates an empty document
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+	}
+}
+
+// AddOperation registers op for method on path. Path parameters written as
+// {name} are declared automatically as required integers unless op already
+// declares them.
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+
+	for _, name := range pathParams(path) {
+		declared := false
+		for _, param := range op.Parameters {
+			declared = declared || (param.In == "path" && param.Name == name)
+		}
+		if !declared {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "integer"},
+			})
+		}
+	}
+
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Resolve follows a $ref to its component schema
+func (d *Document) Resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// StatusKey formats an HTTP status code as a responses map key
+func StatusKey(status int) string {
+	if status == 0 {
+		return "default"
+	}
+	return strconv.Itoa(status)
+}
+
+// DescribeStatus returns the standard text for status, for use as a response description
+func DescribeStatus(status int) string {
+	if status == 0 {
+		return "Error"
+	}
+	return http.StatusText(status)
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"))
+		}
+	}
+	return names
+}
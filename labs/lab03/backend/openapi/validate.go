@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"lab03-backend/models"
+)
+
+var (
+	patternMutex sync.Mutex
+	patternCache = make(map[string]*regexp.Regexp)
+)
+
+// Validate checks a value decoded by encoding/json (maps, slices, float64,
+// string, bool, nil) against schema and returns every violation found. The
+// Field of each is a dotted path to the offending value, or empty for the
+// document root.
+func (d *Document) Validate(schema *Schema, value interface{}) []models.FieldError {
+	var errs []models.FieldError
+	d.validate(schema, value, "", &errs)
+	return errs
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, path string, errs *[]models.FieldError) {
+	schema = d.Resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, models.FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, models.FieldError{Field: join(path, name), Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				d.validate(property, object[name], join(path, name), errs)
+				continue
+			}
+			switch extra := schema.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					*errs = append(*errs, models.FieldError{Field: join(path, name), Message: "is not allowed"})
+				}
+			case *Schema:
+				d.validate(extra, object[name], join(path, name), errs)
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range array {
+			d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if schema.MinLength != nil && utf8.RuneCountInString(str) < *schema.MinLength {
+			if *schema.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", *schema.MinLength)
+			}
+			return
+		}
+		if schema.Pattern != "" && !compilePattern(schema.Pattern).MatchString(str) {
+			if schema.Pattern == requiredPattern {
+				fail("must not be blank")
+			} else {
+				fail("must match pattern %s", schema.Pattern)
+			}
+		}
+
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		if schema.Type == "integer" && number != float64(int64(number)) {
+			fail("must be an integer")
+			return
+		}
+		if schema.Minimum != nil && number < *schema.Minimum {
+			fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			fail("must be at most %v", *schema.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		for _, allowed := range schema.Enum {
+			if allowed == value {
+				return
+			}
+		}
+		fail("must be one of %v", schema.Enum)
+	}
+}
+
+func compilePattern(pattern string) *regexp.Regexp {
+	patternMutex.Lock()
+	defer patternMutex.Unlock()
+
+	re, ok := patternCache[pattern]
+	if !ok {
+		re = regexp.MustCompile(pattern)
+		patternCache[pattern] = re
+	}
+	return re
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return strings.Join([]string{path, name}, ".")
+}
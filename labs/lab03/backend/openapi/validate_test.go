@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"lab03-backend/models"
+)
+
+type testRequest struct {
+	Name  string            `json:"name" validate:"required"`
+	Tags  []string          `json:"tags,omitempty"`
+	Count *int              `json:"count,omitempty"`
+	When  time.Time         `json:"when"`
+	Extra map[string]string `json:"-"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	doc := New("test", "1")
+	ref := doc.SchemaFor(testRequest{})
+	if ref.Ref != "#/components/schemas/testRequest" {
+		t.Fatalf("Expected $ref to testRequest, got %+v", ref)
+	}
+
+	schema := doc.Resolve(ref)
+	if !reflect.DeepEqual(schema.Required, []string{"name"}) {
+		t.Errorf("Expected name to be required, got %v", schema.Required)
+	}
+	if _, ok := schema.Properties["Extra"]; ok {
+		t.Error(`Expected json:"-" field to be skipped`)
+	}
+	if got := schema.Properties["when"]; got.Type != "string" || got.Format != "date-time" {
+		t.Errorf("Expected time.Time as date-time string, got %+v", got)
+	}
+	if got := schema.Properties["count"]; got.Type != "integer" || !got.Nullable {
+		t.Errorf("Expected *int as nullable integer, got %+v", got)
+	}
+	if got := schema.Properties["tags"]; got.Type != "array" || got.Items.Type != "string" {
+		t.Errorf("Expected []string as array of strings, got %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc := New("test", "1")
+	schema := doc.SchemaFor(testRequest{})
+
+	tests := []struct {
+		name string
+		body string
+		want []models.FieldError
+	}{
+		{"valid", `{"name":"x","when":"2025-01-01T00:00:00Z","count":null}`, nil},
+		{"missing required", `{}`, []models.FieldError{{Field: "name", Message: "is required"}}},
+		{"empty string", `{"name":""}`, []models.FieldError{{Field: "name", Message: "must not be empty"}}},
+		{"blank string", `{"name":"   "}`, []models.FieldError{{Field: "name", Message: "must not be blank"}}},
+		{"wrong type", `{"name":1}`, []models.FieldError{{Field: "name", Message: "must be a string"}}},
+		{"unknown field", `{"name":"x","bogus":true}`, []models.FieldError{{Field: "bogus", Message: "is not allowed"}}},
+		{"array item", `{"name":"x","tags":["a",2]}`, []models.FieldError{{Field: "tags[1]", Message: "must be a string"}}},
+		{"fractional integer", `{"name":"x","count":1.5}`, []models.FieldError{{Field: "count", Message: "must be an integer"}}},
+		{"not an object", `[]`, []models.FieldError{{Field: "", Message: "must be an object"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.body), &value); err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.Validate(schema, value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
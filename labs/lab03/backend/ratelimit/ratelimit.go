@@ -0,0 +1,130 @@
+// Package ratelimit implements per-key token bucket rate limiting with
+// eviction of idle buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Config describes one token bucket policy
+type Config struct {
+	Rate    float64       // Tokens added per second
+	Burst   int           // Bucket capacity
+	IdleTTL time.Duration // Buckets unused this long are evicted (at least the refill time)
+}
+
+// Decision is the outcome of one Allow call
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Bucket capacity
+	Remaining  int           // Whole tokens left after this request
+	RetryAfter time.Duration // Wait before the next request can succeed (0 if allowed)
+	Reset      time.Duration // Time until the bucket is full again
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter tracks one token bucket per key
+type Limiter struct {
+	config Config
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New creates a limiter. Buckets are never evicted before they could have
+// refilled, so eviction cannot hand a client extra tokens.
+func New(config Config) *Limiter {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if refill := config.refillTime(); config.IdleTTL < refill {
+		config.IdleTTL = refill
+	}
+	return &Limiter{
+		config:  config,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *Limiter) Allow(key string) Decision {
+	return l.AllowAt(key, time.Now())
+}
+
+// AllowAt is Allow with an explicit clock, for tests
+func (l *Limiter) AllowAt(key string, now time.Time) Decision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.config.IdleTTL {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(l.config.Burst), b.tokens+elapsed*l.config.Rate)
+	}
+	b.last = now
+
+	decision := Decision{Limit: l.config.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.config.durationFor(1 - b.tokens)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = l.config.durationFor(float64(l.config.Burst) - b.tokens)
+	return decision
+}
+
+// Len returns the number of tracked buckets
+func (l *Limiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.buckets)
+}
+
+// Sweep evicts buckets idle for at least IdleTTL
+func (l *Limiter) Sweep(now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sweep(now)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.config.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// refillTime is how long an empty bucket takes to fill
+func (c Config) refillTime() time.Duration {
+	return c.durationFor(float64(c.Burst))
+}
+
+// durationFor is how long it takes to earn tokens
+func (c Config) durationFor(tokens float64) time.Duration {
+	if c.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / c.Rate * float64(time.Second)))
+}
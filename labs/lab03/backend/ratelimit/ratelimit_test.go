@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	limiter := New(Config{Rate: 1, Burst: 3})
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 3; i++ {
+		d := limiter.AllowAt("a", now)
+		if !d.Allowed {
+			t.Fatalf("request %d rejected within burst", i+1)
+		}
+		if d.Remaining != 2-i {
+			t.Errorf("request %d: expected %d remaining, got %d", i+1, 2-i, d.Remaining)
+		}
+	}
+
+	d := limiter.AllowAt("a", now)
+	if d.Allowed {
+		t.Fatal("expected request beyond burst to be rejected")
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", d.RetryAfter)
+	}
+	if d.Reset != 3*time.Second {
+		t.Errorf("expected reset in 3s, got %v", d.Reset)
+	}
+
+	if !limiter.AllowAt("b", now).Allowed {
+		t.Error("expected other keys to have their own bucket")
+	}
+	if !limiter.AllowAt("a", now.Add(time.Second)).Allowed {
+		t.Error("expected a token after one second")
+	}
+	if limiter.AllowAt("a", now.Add(time.Second)).Allowed {
+		t.Error("expected only one refilled token")
+	}
+}
+
+func TestLimiterEvictsIdleBuckets(t *testing.T) {
+	limiter := New(Config{Rate: 1, Burst: 2, IdleTTL: time.Millisecond})
+	now := time.Unix(1000, 0)
+
+	limiter.AllowAt("a", now)
+	limiter.AllowAt("b", now.Add(time.Second))
+	if limiter.Len() != 2 {
+		t.Fatalf("expected 2 buckets, got %d", limiter.Len())
+	}
+
+	// IdleTTL is raised to the 2s refill time, so "a" goes first
+	limiter.Sweep(now.Add(2 * time.Second))
+	if limiter.Len() != 1 {
+		t.Fatalf("expected 1 bucket after sweep, got %d", limiter.Len())
+	}
+
+	limiter.AllowAt("c", now.Add(10*time.Second))
+	if limiter.Len() != 1 {
+		t.Errorf("expected idle buckets to be swept lazily, got %d", limiter.Len())
+	}
+}
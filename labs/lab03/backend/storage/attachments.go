@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"sort"
+	"time"
+)
+
+// AddAttachment links an uploaded file to a live message and assigns it an
+// ID. The blob itself is stored elsewhere under attachment.SHA256.
+func (ms *MemoryStorage) AddAttachment(messageID int, attachment models.Attachment) (*models.Attachment, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, ok := ms.live(messageID); !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	attachment.ID = ms.nextAttachmentID
+	attachment.MessageID = messageID
+	attachment.CreatedAt = time.Now()
+	ms.nextAttachmentID++
+	ms.attachments[attachment.ID] = &attachment
+
+	clone := attachment
+	return &clone, nil
+}
+
+// Attachments returns the attachments of a live message in upload order
+func (ms *MemoryStorage) Attachments(messageID int) ([]models.Attachment, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if _, ok := ms.live(messageID); !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	attachments := make([]models.Attachment, 0)
+	for _, attachment := range ms.attachments {
+		if attachment.MessageID == messageID {
+			attachments = append(attachments, *attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].ID < attachments[j].ID
+	})
+	return attachments, nil
+}
+
+// Attachment returns one attachment. Attachments of deleted messages are
+// hidden along with their message.
+func (ms *MemoryStorage) Attachment(id int) (*models.Attachment, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	attachment, ok := ms.attachments[id]
+	if !ok {
+		return nil, ErrAttachmentNotFound
+	}
+	if _, ok := ms.live(attachment.MessageID); !ok {
+		return nil, ErrAttachmentNotFound
+	}
+	clone := *attachment
+	return &clone, nil
+}
+
+// AttachmentDigests returns the digest of every blob an attachment still
+// refers to. Attachments of deleted messages count until they are purged,
+// since the message can be restored.
+func (ms *MemoryStorage) AttachmentDigests() map[string]bool {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	digests := make(map[string]bool, len(ms.attachments))
+	for _, attachment := range ms.attachments {
+		digests[attachment.SHA256] = true
+	}
+	return digests
+}
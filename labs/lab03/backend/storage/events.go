@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"sync"
+	"time"
+)
+
+// Event types published for message mutations
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+	EventReacted  = "reacted"
+)
+
+// Event bus sizing
+const (
+	defaultReplaySize       = 256
+	subscriberChannelBuffer = 64
+)
+
+// Event describes one change to a message
+type Event struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Message *models.Message `json:"message"`
+	Time    time.Time       `json:"time"`
+}
+
+// EventBus fans message events out to subscribers and keeps a bounded replay
+// buffer so clients can resume after a disconnect
+type EventBus struct {
+	mutex       sync.Mutex
+	nextID      int64
+	replaySize  int
+	buffer      []Event
+	subscribers map[chan Event]struct{}
+}
+
+// NewEventBus creates an event bus that remembers the last replaySize events
+func NewEventBus(replaySize int) *EventBus {
+	if replaySize <= 0 {
+		replaySize = defaultReplaySize
+	}
+	return &EventBus{
+		nextID:      1,
+		replaySize:  replaySize,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish records an event and delivers it to every subscriber. Subscribers
+// that cannot keep up are disconnected rather than blocking the publisher.
+func (b *EventBus) Publish(eventType string, message *models.Message) Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	event := Event{
+		ID:      b.nextID,
+		Type:    eventType,
+		Message: cloneMessage(message),
+		Time:    time.Now(),
+	}
+	b.nextID++
+
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.replaySize {
+		b.buffer = b.buffer[len(b.buffer)-b.replaySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a new subscriber. Events after lastID that are still in
+// the replay buffer are returned first; live events follow on the channel,
+// which is closed when cancel is called or the subscriber falls behind.
+// complete is false when events after lastID have already been evicted, in
+// which case the caller should tell the client to reload.
+func (b *EventBus) Subscribe(lastID int64) (replay []Event, events <-chan Event, cancel func(), complete bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		oldest := b.nextID
+		if len(b.buffer) > 0 {
+			oldest = b.buffer[0].ID
+		}
+		// An ID from the future means the server restarted since the client
+		// last connected, so its position no longer means anything
+		complete = lastID+1 >= oldest && lastID < b.nextID
+		for _, event := range b.buffer {
+			if event.ID > lastID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberChannelBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	cancel = func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, cancel, complete
+}
+
+// SubscriberCount returns the number of connected subscribers
+func (b *EventBus) SubscriberCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.subscribers)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"testing"
+)
+
+func TestEventBusReplay(t *testing.T) {
+	bus := NewEventBus(3)
+	message := models.NewMessage(1, "testuser", "hello")
+	for i := 0; i < 5; i++ {
+		bus.Publish(EventUpdated, message)
+	}
+
+	replay, _, cancel, complete := bus.Subscribe(3)
+	cancel()
+	if !complete {
+		t.Error("Expected resume from buffered ID to be complete")
+	}
+	if len(replay) != 2 || replay[0].ID != 4 || replay[1].ID != 5 {
+		t.Errorf("Expected replay of events 4 and 5, got %v", replay)
+	}
+
+	if _, _, cancel, complete := bus.Subscribe(1); complete {
+		t.Error("Expected resume from evicted ID to be incomplete")
+	} else {
+		cancel()
+	}
+
+	if _, _, cancel, complete := bus.Subscribe(42); complete {
+		t.Error("Expected resume from unknown future ID to be incomplete")
+	} else {
+		cancel()
+	}
+}
+
+func TestEventBusDelivery(t *testing.T) {
+	bus := NewEventBus(10)
+	_, events, cancel, _ := bus.Subscribe(0)
+
+	bus.Publish(EventCreated, models.NewMessage(1, "testuser", "hello"))
+	event := <-events
+	if event.Type != EventCreated || event.Message.ID != 1 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	if bus.SubscriberCount() != 0 {
+		t.Errorf("Expected no subscribers after cancel, got %d", bus.SubscriberCount())
+	}
+}
+
+func TestEventBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewEventBus(10)
+	_, events, cancel, _ := bus.Subscribe(0)
+	defer cancel()
+
+	message := models.NewMessage(1, "testuser", "hello")
+	for i := 0; i <= subscriberChannelBuffer; i++ {
+		bus.Publish(EventUpdated, message)
+	}
+
+	if bus.SubscriberCount() != 0 {
+		t.Fatal("Expected slow subscriber to be dropped")
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberChannelBuffer {
+		t.Errorf("Expected %d buffered events before close, got %d", subscriberChannelBuffer, received)
+	}
+}
+
+func TestMemoryStoragePublishesEvents(t *testing.T) {
+	storage := NewMemoryStorage()
+	_, events, cancel, _ := storage.Events().Subscribe(0)
+	defer cancel()
+
+	storage.Create("testuser", "hello")
+	storage.Update(1, "edited")
+	storage.Delete(1)
+
+	for _, want := range []string{EventCreated, EventUpdated, EventDeleted} {
+		if event := <-events; event.Type != want {
+			t.Errorf("Expected %s event, got %s", want, event.Type)
+		}
+	}
+}
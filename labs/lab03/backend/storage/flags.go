@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"sort"
+	"time"
+)
+
+// Flag queues a live message for moderator review. Flagging a message that
+// is already queued adds any new reasons to its pending flag instead of
+// queueing it twice.
+func (ms *MemoryStorage) Flag(messageID int, reasons []models.FlagReason) (*models.Flag, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.live(messageID)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	flag := ms.flagOf(messageID)
+	if flag == nil {
+		flag = &models.Flag{ID: ms.nextFlagID, MessageID: messageID}
+		ms.nextFlagID++
+		ms.flags[flag.ID] = flag
+	}
+	for _, reason := range reasons {
+		if !hasReason(flag.Reasons, reason) {
+			flag.Reasons = append(flag.Reasons, reason)
+		}
+	}
+	flag.FlaggedAt = time.Now()
+	return cloneFlag(flag, message), nil
+}
+
+// ReviewQueue returns the pending flags of live messages, oldest first, each
+// with the current version of its message. Flags of deleted messages are
+// hidden until the message is restored.
+func (ms *MemoryStorage) ReviewQueue() []models.Flag {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	queue := make([]models.Flag, 0, len(ms.flags))
+	for _, flag := range ms.flags {
+		if message, ok := ms.live(flag.MessageID); ok {
+			queue = append(queue, *cloneFlag(flag, message))
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].FlaggedAt.Equal(queue[j].FlaggedAt) {
+			return queue[i].FlaggedAt.Before(queue[j].FlaggedAt)
+		}
+		return queue[i].ID < queue[j].ID
+	})
+	return queue
+}
+
+// ResolveFlag takes a flag off the review queue. When remove is true the
+// flagged message is deleted as well; otherwise it stays as it is.
+func (ms *MemoryStorage) ResolveFlag(id int, remove bool) (*models.Flag, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	flag, ok := ms.flags[id]
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+	message, ok := ms.live(flag.MessageID)
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+
+	delete(ms.flags, id)
+	if remove {
+		now := time.Now()
+		message.DeletedAt = &now
+		ms.events.Publish(EventDeleted, message)
+	}
+	return cloneFlag(flag, message), nil
+}
+
+// flagOf returns the pending flag of a message, if any. Callers must hold
+// the lock.
+func (ms *MemoryStorage) flagOf(messageID int) *models.Flag {
+	for _, flag := range ms.flags {
+		if flag.MessageID == messageID {
+			return flag
+		}
+	}
+	return nil
+}
+
+func hasReason(reasons []models.FlagReason, reason models.FlagReason) bool {
+	for _, existing := range reasons {
+		if existing == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneFlag returns a copy of flag carrying a copy of its message
+func cloneFlag(flag *models.Flag, message *models.Message) *models.Flag {
+	clone := *flag
+	clone.Reasons = append([]models.FlagReason(nil), flag.Reasons...)
+	clone.Message = cloneMessage(message)
+	return &clone
+}
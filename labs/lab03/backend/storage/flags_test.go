@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"lab03-backend/models"
+	"testing"
+	"time"
+)
+
+func TestReviewQueue(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "aaaaaaaaaaaa")
+	storage.Create("bob", "fine")
+
+	spam := models.FlagReason{Rule: "repeated-characters", Reason: "a character repeats 12 times in a row"}
+	first, err := storage.Flag(1, []models.FlagReason{spam})
+	if err != nil {
+		t.Fatalf("Flag failed: %v", err)
+	}
+	again, _ := storage.Flag(1, []models.FlagReason{spam, {Rule: "other", Reason: "why"}})
+	if again.ID != first.ID || len(again.Reasons) != 2 {
+		t.Errorf("Expected reasons merged into flag %d, got flag %d with %d reasons", first.ID, again.ID, len(again.Reasons))
+	}
+	if _, err := storage.Flag(42, []models.FlagReason{spam}); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	queue := storage.ReviewQueue()
+	if len(queue) != 1 || queue[0].Message == nil || queue[0].Message.Content != "aaaaaaaaaaaa" {
+		t.Fatalf("Expected 1 queued flag with its message, got %+v", queue)
+	}
+
+	// Deleted messages drop out of the queue until restored
+	storage.Delete(1)
+	if queue := storage.ReviewQueue(); len(queue) != 0 {
+		t.Errorf("Expected deleted message hidden from queue, got %d", len(queue))
+	}
+	storage.Restore(1)
+
+	if _, err := storage.ResolveFlag(first.ID, true); err != nil {
+		t.Fatalf("ResolveFlag failed: %v", err)
+	}
+	if _, err := storage.GetByID(1); err != ErrMessageNotFound {
+		t.Errorf("Expected removed message to be deleted, got %v", err)
+	}
+	if _, err := storage.ResolveFlag(first.ID, false); err != ErrFlagNotFound {
+		t.Errorf("Expected ErrFlagNotFound for a resolved flag, got %v", err)
+	}
+
+	flag, _ := storage.Flag(2, []models.FlagReason{spam})
+	if _, err := storage.ResolveFlag(flag.ID, false); err != nil {
+		t.Fatalf("ResolveFlag failed: %v", err)
+	}
+	if _, err := storage.GetByID(2); err != nil || len(storage.ReviewQueue()) != 0 {
+		t.Errorf("Expected approved message kept and queue empty, got %v", err)
+	}
+}
+
+func TestFlagsSurviveSnapshotAndPurge(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "spam")
+	storage.Flag(1, []models.FlagReason{{Rule: "test", Reason: "spam"}})
+
+	var buf bytes.Buffer
+	if _, err := storage.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+	restored := NewMemoryStorage()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if queue := restored.ReviewQueue(); len(queue) != 1 || queue[0].MessageID != 1 {
+		t.Fatalf("Expected restored queue with message 1, got %+v", queue)
+	}
+	if flag, _ := restored.Flag(1, nil); flag.ID != 1 {
+		t.Errorf("Expected pending flag 1 to be reused, got %d", flag.ID)
+	}
+
+	restored.SetDeleteGracePeriod(time.Minute)
+	restored.Delete(1)
+	restored.PurgeExpired(time.Now().Add(time.Hour))
+	if len(restored.flags) != 0 {
+		t.Errorf("Expected purge to drop the flag, got %d", len(restored.flags))
+	}
+}
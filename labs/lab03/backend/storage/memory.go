@@ -3,83 +3,514 @@ package storage
 import (
 	"errors"
 	"lab03-backend/models"
+	"log"
+	"sort"
+	"sync"
+	"time"
 )
 
 // MemoryStorage implements in-memory storage for messages
 type MemoryStorage struct {
-	// TODO: Add mutex field for thread safety (sync.RWMutex)
-	// TODO: Add messages field as map[int]*models.Message
-	// TODO: Add nextID field of type int for auto-incrementing IDs
+	mutex    sync.RWMutex
+	messages map[int]*models.Message
+	nextID   int
+	events   *EventBus
+
+	revisions   map[int][]models.Revision
+	gracePeriod time.Duration
+
+	reactions map[int]map[string]map[string]struct{} // Message ID -> emoji -> users
+
+	attachments      map[int]*models.Attachment
+	nextAttachmentID int
+
+	flags      map[int]*models.Flag
+	nextFlagID int
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
 func NewMemoryStorage() *MemoryStorage {
-	// TODO: Return a new MemoryStorage instance with initialized fields
-	// Initialize messages as empty map
-	// Set nextID to 1
-	return nil
+	return &MemoryStorage{
+		messages: make(map[int]*models.Message),
+		nextID:   1,
+		events:   NewEventBus(defaultReplaySize),
+
+		revisions:   make(map[int][]models.Revision),
+		gracePeriod: DefaultDeleteGracePeriod,
+
+		reactions: make(map[int]map[string]map[string]struct{}),
+
+		attachments:      make(map[int]*models.Attachment),
+		nextAttachmentID: 1,
+
+		flags:      make(map[int]*models.Flag),
+		nextFlagID: 1,
+	}
 }
 
-// GetAll returns all messages
+// SetDeleteGracePeriod changes how long deleted messages can be restored
+func (ms *MemoryStorage) SetDeleteGracePeriod(d time.Duration) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.gracePeriod = d
+}
+
+// live returns a message that exists and has not been deleted. Callers must
+// hold the lock.
+func (ms *MemoryStorage) live(id int) (*models.Message, bool) {
+	message, ok := ms.messages[id]
+	if !ok || message.DeletedAt != nil {
+		return nil, false
+	}
+	return message, true
+}
+
+// GetAll returns all messages ordered by timestamp and ID
 func (ms *MemoryStorage) GetAll() []*models.Message {
-	// TODO: Implement GetAll method
-	// Use read lock for thread safety
-	// Convert map values to slice
-	// Return slice of all messages
-	return nil
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	messages := make([]*models.Message, 0, len(ms.messages))
+	for _, message := range ms.messages {
+		if message.DeletedAt == nil {
+			messages = append(messages, cloneMessage(message))
+		}
+	}
+	sortMessages(messages, false)
+	return messages
 }
 
 // GetByID returns a message by its ID
 func (ms *MemoryStorage) GetByID(id int) (*models.Message, error) {
-	// TODO: Implement GetByID method
-	// Use read lock for thread safety
-	// Check if message exists in map
-	// Return message or error if not found
-	return nil, nil
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	message, ok := ms.live(id)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	return cloneMessage(message), nil
 }
 
 // Create adds a new message to storage
 func (ms *MemoryStorage) Create(username, content string) (*models.Message, error) {
-	// TODO: Implement Create method
-	// Use write lock for thread safety
-	// Get next available ID
-	// Create new message using models.NewMessage
-	// Add message to map
-	// Increment nextID
-	// Return created message
-	return nil, nil
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	return ms.create(username, content, nil), nil
+}
+
+// Reply adds a new message that replies to parentID, which must exist and
+// not be deleted
+func (ms *MemoryStorage) Reply(parentID int, username, content string) (*models.Message, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, ok := ms.live(parentID); !ok {
+		return nil, ErrParentNotFound
+	}
+	return ms.create(username, content, &parentID), nil
+}
+
+// create stores a new message. Callers must hold the lock.
+func (ms *MemoryStorage) create(username, content string, parentID *int) *models.Message {
+	message := models.NewMessage(ms.nextID, username, content)
+	message.ParentID = parentID
+	ms.messages[message.ID] = message
+	ms.revisions[message.ID] = []models.Revision{revisionOf(message)}
+	ms.nextID++
+	ms.events.Publish(EventCreated, message)
+	return cloneMessage(message)
+}
+
+// Thread returns a message and all replies beneath it. Deleted messages are
+// kept in the thread as placeholders while they still have live replies, so
+// deleting a parent never orphans its replies.
+func (ms *MemoryStorage) Thread(id int) (*models.Thread, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	root, ok := ms.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	children := make(map[int][]*models.Message)
+	for _, message := range ms.messages {
+		if message.ParentID != nil {
+			children[*message.ParentID] = append(children[*message.ParentID], message)
+		}
+	}
+
+	replies := make([]*models.Message, 0)
+	// collect appends the visible replies beneath id and reports whether
+	// there were any
+	var collect func(id int) bool
+	collect = func(id int) bool {
+		found := false
+		for _, child := range children[id] {
+			hasReplies := collect(child.ID)
+			if child.DeletedAt == nil {
+				replies = append(replies, cloneMessage(child))
+			} else if hasReplies {
+				replies = append(replies, placeholderOf(child))
+			} else {
+				continue
+			}
+			found = true
+		}
+		return found
+	}
+	hasReplies := collect(id)
+
+	thread := &models.Thread{Replies: replies}
+	switch {
+	case root.DeletedAt == nil:
+		thread.Message = cloneMessage(root)
+	case hasReplies:
+		thread.Message = placeholderOf(root)
+	default:
+		return nil, ErrMessageNotFound
+	}
+	sortMessages(thread.Replies, false)
+	return thread, nil
 }
 
 // Update modifies an existing message
 func (ms *MemoryStorage) Update(id int, content string) (*models.Message, error) {
-	// TODO: Implement Update method
-	// Use write lock for thread safety
-	// Check if message exists
-	// Update the content field
-	// Return updated message or error if not found
-	return nil, nil
+	return ms.UpdateIfVersion(id, content, AnyVersion)
 }
 
-// Delete removes a message from storage
+// UpdateIfVersion modifies an existing message only if its current version
+// equals expected. Pass AnyVersion to skip the check.
+func (ms *MemoryStorage) UpdateIfVersion(id int, content string, expected int) (*models.Message, error) {
+	return ms.Edit(id, MessageEdit{Content: &content}, expected)
+}
+
+// MessageEdit describes a change to an existing message. Nil fields are left
+// unchanged.
+type MessageEdit struct {
+	Username *string
+	Content  *string
+	EditedBy string // Who made the change (defaults to the message author)
+}
+
+// Edit applies edit to a message if its current version equals expected,
+// bumps the version and records who edited it and when. Pass AnyVersion to
+// skip the check.
+func (ms *MemoryStorage) Edit(id int, edit MessageEdit, expected int) (*models.Message, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.live(id)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	if expected != AnyVersion && message.Version != expected {
+		return nil, ErrVersionMismatch
+	}
+
+	if edit.Username != nil {
+		message.Username = *edit.Username
+	}
+	if edit.Content != nil {
+		message.Content = *edit.Content
+	}
+	message.EditedBy = edit.EditedBy
+	if message.EditedBy == "" {
+		message.EditedBy = message.Username
+	}
+	now := time.Now()
+	message.EditedAt = &now
+	message.Version++
+	ms.revisions[id] = append(ms.revisions[id], revisionOf(message))
+	ms.events.Publish(EventUpdated, message)
+	return cloneMessage(message), nil
+}
+
+// Delete removes a message from storage. The message becomes a tombstone
+// that can be restored until the delete grace period expires.
 func (ms *MemoryStorage) Delete(id int) error {
-	// TODO: Implement Delete method
-	// Use write lock for thread safety
-	// Check if message exists
-	// Delete from map
-	// Return error if message not found
+	return ms.DeleteIfVersion(id, AnyVersion)
+}
+
+// DeleteIfVersion deletes a message only if its current version equals
+// expected. Pass AnyVersion to skip the check.
+func (ms *MemoryStorage) DeleteIfVersion(id int, expected int) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.live(id)
+	if !ok {
+		return ErrMessageNotFound
+	}
+	if expected != AnyVersion && message.Version != expected {
+		return ErrVersionMismatch
+	}
+	now := time.Now()
+	message.DeletedAt = &now
+	ms.events.Publish(EventDeleted, message)
 	return nil
 }
 
+// Restore brings back a deleted message whose grace period has not expired
+func (ms *MemoryStorage) Restore(id int) (*models.Message, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	if message.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	if time.Since(*message.DeletedAt) > ms.gracePeriod {
+		return nil, ErrRestoreExpired
+	}
+	message.DeletedAt = nil
+	ms.events.Publish(EventRestored, message)
+	return cloneMessage(message), nil
+}
+
+// Revisions returns every version of a message, oldest first. History stays
+// available while the message is a tombstone.
+func (ms *MemoryStorage) Revisions(id int) ([]models.Revision, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	revisions, ok := ms.revisions[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	return append([]models.Revision(nil), revisions...), nil
+}
+
+// PurgeExpired permanently removes tombstones deleted more than the grace
+// period before now and returns how many were removed. Tombstones that still
+// have replies are kept so the replies stay attached to their thread.
+func (ms *MemoryStorage) PurgeExpired(now time.Time) int {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	replyCounts := make(map[int]int)
+	for _, message := range ms.messages {
+		if message.ParentID != nil {
+			replyCounts[*message.ParentID]++
+		}
+	}
+
+	purged := 0
+	// Purging a reply can free its parent, so repeat until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for id, message := range ms.messages {
+			if message.DeletedAt == nil || now.Sub(*message.DeletedAt) <= ms.gracePeriod || replyCounts[id] > 0 {
+				continue
+			}
+			delete(ms.messages, id)
+			delete(ms.revisions, id)
+			delete(ms.reactions, id)
+			for attachmentID, attachment := range ms.attachments {
+				if attachment.MessageID == id {
+					delete(ms.attachments, attachmentID)
+				}
+			}
+			if flag := ms.flagOf(id); flag != nil {
+				delete(ms.flags, flag.ID)
+			}
+			if message.ParentID != nil {
+				replyCounts[*message.ParentID]--
+			}
+			purged++
+			changed = true
+		}
+	}
+	return purged
+}
+
+// StartPurger runs PurgeExpired every interval in the background until the
+// returned stop function is called
+func (ms *MemoryStorage) StartPurger(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if purged := ms.PurgeExpired(now); purged > 0 {
+					log.Printf("purged %d expired message tombstones", purged)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Events returns the bus that publishes every message mutation
+func (ms *MemoryStorage) Events() *EventBus {
+	return ms.events
+}
+
 // Count returns the total number of messages
 func (ms *MemoryStorage) Count() int {
-	// TODO: Implement Count method
-	// Use read lock for thread safety
-	// Return length of messages map
-	return 0
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	count := 0
+	for _, message := range ms.messages {
+		if message.DeletedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Cursor marks a position in the (timestamp, ID) ordering of messages
+type Cursor struct {
+	Timestamp time.Time
+	ID        int
+}
+
+// ListOptions controls filtering, ordering and pagination for List
+type ListOptions struct {
+	Username string    // Only messages from this user
+	Since    time.Time // Only messages at or after this time
+	Desc     bool      // Newest first instead of oldest first
+	After    *Cursor   // Start strictly after this position (keyset pagination)
+	Offset   int       // Skip this many matching messages (ignored when After is set)
+	Limit    int       // Maximum number of messages to return (0 means no limit)
+}
+
+// Page is one slice of a filtered, ordered message list
+type Page struct {
+	Messages []*models.Message
+	Total    int  // Number of messages matching the filters, across all pages
+	HasMore  bool // Whether messages remain after this page
 }
 
+// List returns messages matching opts in a stable (timestamp, ID) order
+func (ms *MemoryStorage) List(opts ListOptions) Page {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	matched := make([]*models.Message, 0, len(ms.messages))
+	for _, message := range ms.messages {
+		if message.DeletedAt != nil {
+			continue
+		}
+		if opts.Username != "" && message.Username != opts.Username {
+			continue
+		}
+		if !opts.Since.IsZero() && message.Timestamp.Before(opts.Since) {
+			continue
+		}
+		matched = append(matched, message)
+	}
+	sortMessages(matched, opts.Desc)
+
+	page := Page{Total: len(matched)}
+
+	start := 0
+	if opts.After != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return isAfter(matched[i], *opts.After, opts.Desc)
+		})
+	} else if opts.Offset > 0 {
+		start = min(opts.Offset, len(matched))
+	}
+
+	end := len(matched)
+	if opts.Limit > 0 && start+opts.Limit < end {
+		end = start + opts.Limit
+		page.HasMore = true
+	}
+	page.Messages = make([]*models.Message, 0, end-start)
+	for _, message := range matched[start:end] {
+		page.Messages = append(page.Messages, cloneMessage(message))
+	}
+	return page
+}
+
+// revisionOf snapshots the current version of a message
+func revisionOf(message *models.Message) models.Revision {
+	revision := models.Revision{
+		Version:  message.Version,
+		Username: message.Username,
+		Content:  message.Content,
+		EditedBy: message.Username,
+		EditedAt: message.Timestamp,
+	}
+	if message.EditedAt != nil {
+		revision.EditedBy = message.EditedBy
+		revision.EditedAt = *message.EditedAt
+	}
+	return revision
+}
+
+// cloneMessage returns a copy callers can read without holding the lock
+func cloneMessage(message *models.Message) *models.Message {
+	clone := *message
+	return &clone
+}
+
+// placeholderOf stands in for a deleted message that still has replies
+func placeholderOf(message *models.Message) *models.Message {
+	placeholder := cloneMessage(message)
+	placeholder.Username = ""
+	placeholder.Content = ""
+	placeholder.EditedBy = ""
+	return placeholder
+}
+
+// sortMessages orders messages by timestamp, breaking ties by ID
+func sortMessages(messages []*models.Message, desc bool) {
+	sort.Slice(messages, func(i, j int) bool {
+		if desc {
+			return less(messages[j], messages[i])
+		}
+		return less(messages[i], messages[j])
+	})
+}
+
+func less(a, b *models.Message) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.ID < b.ID
+}
+
+// isAfter reports whether message comes strictly after cursor in the given order
+func isAfter(message *models.Message, cursor Cursor, desc bool) bool {
+	at := &models.Message{ID: cursor.ID, Timestamp: cursor.Timestamp}
+	if desc {
+		return less(message, at)
+	}
+	return less(at, message)
+}
+
+// DefaultDeleteGracePeriod is how long deleted messages can be restored
+const DefaultDeleteGracePeriod = 24 * time.Hour
+
+// AnyVersion disables the version check in UpdateIfVersion and DeleteIfVersion
+const AnyVersion = 0
+
 // Common errors
 var (
 	ErrMessageNotFound = errors.New("message not found")
 	ErrInvalidID       = errors.New("invalid message ID")
+	ErrVersionMismatch = errors.New("message version does not match")
+	ErrNotDeleted      = errors.New("message is not deleted")
+	ErrRestoreExpired  = errors.New("message can no longer be restored")
+	ErrParentNotFound  = errors.New("parent message not found")
+
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrFlagNotFound       = errors.New("flag not found")
 )
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"lab03-backend/models"
 	"testing"
+	"time"
 )
 
 func TestNewMemoryStorage(t *testing.T) {
@@ -116,3 +118,273 @@ func TestMemoryStorageConcurrency(t *testing.T) {
 		t.Errorf("Expected 10 messages after concurrent writes, got %d", count)
 	}
 }
+
+func TestMemoryStorageList(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, username := range []string{"alice", "bob", "alice", "carol", "alice"} {
+		if _, err := storage.Create(username, "hello"); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	page := storage.List(ListOptions{Limit: 2})
+	if page.Total != 5 || len(page.Messages) != 2 || !page.HasMore {
+		t.Fatalf("Expected 2 of 5 messages with more, got %d of %d (more=%v)", len(page.Messages), page.Total, page.HasMore)
+	}
+	if page.Messages[0].ID != 1 || page.Messages[1].ID != 2 {
+		t.Errorf("Expected IDs 1, 2, got %d, %d", page.Messages[0].ID, page.Messages[1].ID)
+	}
+
+	last := page.Messages[1]
+	page = storage.List(ListOptions{Limit: 2, After: &Cursor{Timestamp: last.Timestamp, ID: last.ID}})
+	if len(page.Messages) != 2 || page.Messages[0].ID != 3 {
+		t.Errorf("Expected cursor page to start at ID 3, got %v", page.Messages)
+	}
+
+	page = storage.List(ListOptions{Username: "alice", Desc: true})
+	if page.Total != 3 || page.HasMore {
+		t.Fatalf("Expected 3 messages from alice, got %d (more=%v)", page.Total, page.HasMore)
+	}
+	if page.Messages[0].ID != 5 || page.Messages[2].ID != 1 {
+		t.Errorf("Expected newest first, got IDs %d..%d", page.Messages[0].ID, page.Messages[2].ID)
+	}
+
+	page = storage.List(ListOptions{Offset: 4, Limit: 10})
+	if len(page.Messages) != 1 || page.Messages[0].ID != 5 {
+		t.Errorf("Expected only ID 5 after offset 4, got %v", page.Messages)
+	}
+}
+
+func TestMemoryStorageVersioning(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	message, _ := storage.Create("testuser", "original")
+	if message.Version != 1 {
+		t.Fatalf("Expected new message at version 1, got %d", message.Version)
+	}
+
+	updated, err := storage.UpdateIfVersion(message.ID, "edited", 1)
+	if err != nil {
+		t.Fatalf("UpdateIfVersion failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", updated.Version)
+	}
+
+	if _, err := storage.UpdateIfVersion(message.ID, "stale", 1); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch for stale update, got %v", err)
+	}
+	if err := storage.DeleteIfVersion(message.ID, 1); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch for stale delete, got %v", err)
+	}
+	if err := storage.DeleteIfVersion(message.ID, 2); err != nil {
+		t.Errorf("DeleteIfVersion failed: %v", err)
+	}
+}
+
+func TestMemoryStorageRevisions(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("testuser", "first")
+	storage.Update(1, "second")
+	storage.Edit(1, MessageEdit{Content: strPtr("third"), EditedBy: "moderator"}, AnyVersion)
+
+	revisions, err := storage.Revisions(1)
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if revisions[i].Version != i+1 || revisions[i].Content != want {
+			t.Errorf("Revision %d: expected version %d %q, got %d %q", i, i+1, want, revisions[i].Version, revisions[i].Content)
+		}
+	}
+	if revisions[2].EditedBy != "moderator" {
+		t.Errorf("Expected last revision by moderator, got %q", revisions[2].EditedBy)
+	}
+
+	if _, err := storage.Revisions(999); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStorageSoftDelete(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("testuser", "hello")
+
+	if _, err := storage.Restore(1); err != ErrNotDeleted {
+		t.Errorf("Expected ErrNotDeleted, got %v", err)
+	}
+
+	storage.Delete(1)
+	if _, err := storage.GetByID(1); err != ErrMessageNotFound {
+		t.Errorf("Expected deleted message to be hidden, got %v", err)
+	}
+	if _, err := storage.Update(1, "edited"); err != ErrMessageNotFound {
+		t.Errorf("Expected deleted message to be read-only, got %v", err)
+	}
+	if _, err := storage.Revisions(1); err != nil {
+		t.Errorf("Expected history to survive deletion, got %v", err)
+	}
+
+	restored, err := storage.Restore(1)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.DeletedAt != nil || storage.Count() != 1 {
+		t.Error("Expected message to be live again after restore")
+	}
+
+	storage.SetDeleteGracePeriod(time.Minute)
+	storage.Delete(1)
+	if purged := storage.PurgeExpired(time.Now()); purged != 0 {
+		t.Errorf("Expected nothing purged within grace period, got %d", purged)
+	}
+	if purged := storage.PurgeExpired(time.Now().Add(2 * time.Minute)); purged != 1 {
+		t.Errorf("Expected 1 tombstone purged, got %d", purged)
+	}
+	if _, err := storage.Restore(1); err != ErrMessageNotFound {
+		t.Errorf("Expected purged message to be gone, got %v", err)
+	}
+}
+
+func TestMemoryStorageRestoreExpired(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.SetDeleteGracePeriod(0)
+	storage.Create("testuser", "hello")
+	storage.Delete(1)
+
+	time.Sleep(time.Millisecond)
+	if _, err := storage.Restore(1); err != ErrRestoreExpired {
+		t.Errorf("Expected ErrRestoreExpired, got %v", err)
+	}
+}
+
+func TestMemoryStorageThread(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "root")
+	storage.Reply(1, "bob", "reply")
+	storage.Reply(2, "carol", "nested")
+	storage.Reply(1, "dave", "second reply")
+
+	if _, err := storage.Reply(99, "bob", "lost"); err != ErrParentNotFound {
+		t.Errorf("Expected ErrParentNotFound, got %v", err)
+	}
+
+	thread, err := storage.Thread(1)
+	if err != nil {
+		t.Fatalf("Thread failed: %v", err)
+	}
+	if thread.Message.ID != 1 || len(thread.Replies) != 3 {
+		t.Fatalf("Expected message 1 with 3 replies, got %d with %d", thread.Message.ID, len(thread.Replies))
+	}
+	if parent := thread.Replies[1].ParentID; parent == nil || *parent != 2 {
+		t.Errorf("Expected nested reply under message 2, got %v", parent)
+	}
+
+	// Deleting a parent keeps it as a placeholder while replies remain
+	storage.SetDeleteGracePeriod(time.Minute)
+	storage.Delete(2)
+	thread, _ = storage.Thread(1)
+	if len(thread.Replies) != 3 || thread.Replies[0].Content != "" || thread.Replies[0].DeletedAt == nil {
+		t.Errorf("Expected deleted reply as a placeholder, got %+v", thread.Replies[0])
+	}
+	if _, err := storage.Reply(2, "erin", "too late"); err != ErrParentNotFound {
+		t.Errorf("Expected replies to deleted messages to fail, got %v", err)
+	}
+
+	later := time.Now().Add(2 * time.Minute)
+	if purged := storage.PurgeExpired(later); purged != 0 {
+		t.Errorf("Expected tombstones with replies to be kept, got %d purged", purged)
+	}
+	storage.Delete(3)
+	if purged := storage.PurgeExpired(later); purged != 2 {
+		t.Errorf("Expected reply and its parent to be purged together, got %d", purged)
+	}
+	thread, _ = storage.Thread(1)
+	if len(thread.Replies) != 1 {
+		t.Errorf("Expected 1 reply left, got %d", len(thread.Replies))
+	}
+
+	storage.Delete(4)
+	storage.Delete(1)
+	if _, err := storage.Thread(1); err != ErrMessageNotFound {
+		t.Errorf("Expected empty deleted thread to be gone, got %v", err)
+	}
+}
+
+func TestMemoryStorageReactions(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "hello")
+
+	storage.React(1, "bob", "👍")
+	storage.React(1, "bob", "👍")
+	storage.React(1, "carol", "👍")
+	reactions, err := storage.React(1, "carol", "🎉")
+	if err != nil {
+		t.Fatalf("React failed: %v", err)
+	}
+	if len(reactions) != 2 || reactions[0].Emoji != "👍" || reactions[0].Count != 2 {
+		t.Fatalf("Expected 👍 x2 first, got %+v", reactions)
+	}
+
+	reactions, _ = storage.Unreact(1, "carol", "🎉")
+	if len(reactions) != 1 {
+		t.Errorf("Expected emoji with no users to disappear, got %+v", reactions)
+	}
+	if _, err := storage.Unreact(1, "nobody", "🎉"); err != nil {
+		t.Errorf("Expected removing a missing reaction to succeed, got %v", err)
+	}
+
+	storage.Delete(1)
+	if _, err := storage.React(1, "bob", "👍"); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStorageAttachments(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "hello")
+
+	if _, err := storage.AddAttachment(9, models.Attachment{}); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+	attachment, err := storage.AddAttachment(1, models.Attachment{Filename: "a.txt", SHA256: "aaaa"})
+	if err != nil || attachment.ID != 1 || attachment.MessageID != 1 {
+		t.Fatalf("Expected attachment 1 on message 1, got %+v, %v", attachment, err)
+	}
+	storage.AddAttachment(1, models.Attachment{Filename: "b.txt", SHA256: "bbbb"})
+
+	attachments, _ := storage.Attachments(1)
+	if len(attachments) != 2 || attachments[1].Filename != "b.txt" {
+		t.Errorf("Expected 2 attachments in upload order, got %+v", attachments)
+	}
+
+	storage.SetDeleteGracePeriod(time.Minute)
+	storage.Delete(1)
+	if _, err := storage.Attachment(1); err != ErrAttachmentNotFound {
+		t.Errorf("Expected attachment of deleted message to be hidden, got %v", err)
+	}
+	storage.Restore(1)
+	if _, err := storage.Attachment(1); err != nil {
+		t.Errorf("Expected restore to bring back attachments, got %v", err)
+	}
+
+	storage.Delete(1)
+	// Deleted messages can still be restored, so their blobs are kept
+	if digests := storage.AttachmentDigests(); len(digests) != 2 || !digests["aaaa"] || !digests["bbbb"] {
+		t.Errorf("Expected both digests to be referenced, got %v", digests)
+	}
+	storage.PurgeExpired(time.Now().Add(2 * time.Minute))
+	if len(storage.attachments) != 0 {
+		t.Errorf("Expected purge to drop attachments, %d left", len(storage.attachments))
+	}
+	if digests := storage.AttachmentDigests(); len(digests) != 0 {
+		t.Errorf("Expected purged attachments to release their blobs, got %v", digests)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"sort"
+)
+
+// React records that user reacted to a message with emoji and returns the
+// message's reactions. Reacting twice with the same emoji has no effect.
+func (ms *MemoryStorage) React(id int, user, emoji string) ([]models.ReactionSummary, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.live(id)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	byEmoji, ok := ms.reactions[id]
+	if !ok {
+		byEmoji = make(map[string]map[string]struct{})
+		ms.reactions[id] = byEmoji
+	}
+	users, ok := byEmoji[emoji]
+	if !ok {
+		users = make(map[string]struct{})
+		byEmoji[emoji] = users
+	}
+	if _, ok := users[user]; !ok {
+		users[user] = struct{}{}
+		ms.events.Publish(EventReacted, message)
+	}
+	return summarize(byEmoji), nil
+}
+
+// Unreact removes user's emoji reaction from a message and returns the
+// message's remaining reactions. Removing a missing reaction has no effect.
+func (ms *MemoryStorage) Unreact(id int, user, emoji string) ([]models.ReactionSummary, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	message, ok := ms.live(id)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	byEmoji := ms.reactions[id]
+	if _, ok := byEmoji[emoji][user]; ok {
+		delete(byEmoji[emoji], user)
+		if len(byEmoji[emoji]) == 0 {
+			delete(byEmoji, emoji)
+		}
+		ms.events.Publish(EventReacted, message)
+	}
+	return summarize(byEmoji), nil
+}
+
+// Reactions returns the aggregated reactions to a message
+func (ms *MemoryStorage) Reactions(id int) ([]models.ReactionSummary, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if _, ok := ms.live(id); !ok {
+		return nil, ErrMessageNotFound
+	}
+	return summarize(ms.reactions[id]), nil
+}
+
+// summarize counts reactions per emoji, most popular first
+func summarize(byEmoji map[string]map[string]struct{}) []models.ReactionSummary {
+	summaries := make([]models.ReactionSummary, 0, len(byEmoji))
+	for emoji, users := range byEmoji {
+		summary := models.ReactionSummary{Emoji: emoji, Count: len(users), Users: make([]string, 0, len(users))}
+		for user := range users {
+			summary.Users = append(summary.Users, user)
+		}
+		sort.Strings(summary.Users)
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Emoji < summaries[j].Emoji
+	})
+	return summaries
+}
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lab03-backend/models"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SnapshotFormat is the version written into every snapshot. LoadSnapshot
+// refuses other versions rather than guess at their layout.
+const SnapshotFormat = 1
+
+// ErrSnapshotFormat is returned for snapshots written in an unknown format
+var ErrSnapshotFormat = errors.New("unsupported snapshot format")
+
+// snapshot is the on-disk form of MemoryStorage. Tombstones, revisions,
+// reactions, attachment metadata and the review queue are kept so a restored server behaves
+// exactly like the one that wrote it. Attachment content lives in the blob
+// store and is not part of the snapshot.
+type snapshot struct {
+	Format           int                         `json:"format"`
+	TakenAt          time.Time                   `json:"taken_at"`
+	NextID           int                         `json:"next_id"`
+	Messages         []*models.Message           `json:"messages"`
+	Revisions        map[int][]models.Revision   `json:"revisions"`
+	Reactions        map[int]map[string][]string `json:"reactions"`
+	Attachments      []*models.Attachment        `json:"attachments"`
+	NextAttachmentID int                         `json:"next_attachment_id"`
+	Flags            []*models.Flag              `json:"flags,omitempty"`
+	NextFlagID       int                         `json:"next_flag_id,omitempty"`
+}
+
+// SnapshotInfo describes a snapshot that was written
+type SnapshotInfo struct {
+	Path     string    `json:"path,omitempty"`
+	TakenAt  time.Time `json:"taken_at"`
+	Messages int       `json:"messages"`
+	Bytes    int64     `json:"bytes"`
+}
+
+// capture copies the current state so it can be encoded without holding the
+// lock
+func (ms *MemoryStorage) capture() *snapshot {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	snap := &snapshot{
+		Format:           SnapshotFormat,
+		TakenAt:          time.Now(),
+		NextID:           ms.nextID,
+		Messages:         make([]*models.Message, 0, len(ms.messages)),
+		Revisions:        make(map[int][]models.Revision, len(ms.revisions)),
+		Reactions:        make(map[int]map[string][]string, len(ms.reactions)),
+		Attachments:      make([]*models.Attachment, 0, len(ms.attachments)),
+		NextAttachmentID: ms.nextAttachmentID,
+		Flags:            make([]*models.Flag, 0, len(ms.flags)),
+		NextFlagID:       ms.nextFlagID,
+	}
+	for _, message := range ms.messages {
+		snap.Messages = append(snap.Messages, cloneMessage(message))
+	}
+	sort.Slice(snap.Messages, func(i, j int) bool {
+		return snap.Messages[i].ID < snap.Messages[j].ID
+	})
+	for id, revisions := range ms.revisions {
+		snap.Revisions[id] = append([]models.Revision(nil), revisions...)
+	}
+	for id, byEmoji := range ms.reactions {
+		for _, summary := range summarize(byEmoji) {
+			if snap.Reactions[id] == nil {
+				snap.Reactions[id] = make(map[string][]string)
+			}
+			snap.Reactions[id][summary.Emoji] = summary.Users
+		}
+	}
+	for _, attachment := range ms.attachments {
+		clone := *attachment
+		snap.Attachments = append(snap.Attachments, &clone)
+	}
+	sort.Slice(snap.Attachments, func(i, j int) bool {
+		return snap.Attachments[i].ID < snap.Attachments[j].ID
+	})
+	for _, flag := range ms.flags {
+		clone := *flag
+		clone.Reasons = append([]models.FlagReason(nil), flag.Reasons...)
+		snap.Flags = append(snap.Flags, &clone)
+	}
+	sort.Slice(snap.Flags, func(i, j int) bool {
+		return snap.Flags[i].ID < snap.Flags[j].ID
+	})
+	return snap
+}
+
+// WriteSnapshot writes the current state to w as JSON
+func (ms *MemoryStorage) WriteSnapshot(w io.Writer) (SnapshotInfo, error) {
+	snap := ms.capture()
+	counter := &countingWriter{w: w}
+	if err := json.NewEncoder(counter).Encode(snap); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return SnapshotInfo{TakenAt: snap.TakenAt, Messages: len(snap.Messages), Bytes: counter.n}, nil
+}
+
+// LoadSnapshot replaces the current state with a snapshot read from r.
+// Subscribers are not told about the change; load before serving requests.
+func (ms *MemoryStorage) LoadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Format != SnapshotFormat {
+		return fmt.Errorf("%w %d", ErrSnapshotFormat, snap.Format)
+	}
+
+	messages := make(map[int]*models.Message, len(snap.Messages))
+	nextID := max(snap.NextID, 1)
+	for _, message := range snap.Messages {
+		messages[message.ID] = message
+		nextID = max(nextID, message.ID+1)
+	}
+	revisions := make(map[int][]models.Revision, len(snap.Revisions))
+	for id, history := range snap.Revisions {
+		if _, ok := messages[id]; ok {
+			revisions[id] = history
+		}
+	}
+	reactions := make(map[int]map[string]map[string]struct{}, len(snap.Reactions))
+	for id, byEmoji := range snap.Reactions {
+		if _, ok := messages[id]; !ok {
+			continue
+		}
+		reactions[id] = make(map[string]map[string]struct{}, len(byEmoji))
+		for emoji, users := range byEmoji {
+			reactions[id][emoji] = make(map[string]struct{}, len(users))
+			for _, user := range users {
+				reactions[id][emoji][user] = struct{}{}
+			}
+		}
+	}
+	attachments := make(map[int]*models.Attachment, len(snap.Attachments))
+	nextAttachmentID := max(snap.NextAttachmentID, 1)
+	for _, attachment := range snap.Attachments {
+		if _, ok := messages[attachment.MessageID]; !ok {
+			continue
+		}
+		attachments[attachment.ID] = attachment
+		nextAttachmentID = max(nextAttachmentID, attachment.ID+1)
+	}
+	flags := make(map[int]*models.Flag, len(snap.Flags))
+	nextFlagID := max(snap.NextFlagID, 1)
+	for _, flag := range snap.Flags {
+		if _, ok := messages[flag.MessageID]; !ok {
+			continue
+		}
+		flag.Message = nil
+		flags[flag.ID] = flag
+		nextFlagID = max(nextFlagID, flag.ID+1)
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.messages = messages
+	ms.nextID = nextID
+	ms.revisions = revisions
+	ms.reactions = reactions
+	ms.attachments = attachments
+	ms.nextAttachmentID = nextAttachmentID
+	ms.flags = flags
+	ms.nextFlagID = nextFlagID
+	return nil
+}
+
+// LoadSnapshotFile loads the snapshot at path. A missing file is reported
+// with an error satisfying errors.Is(err, os.ErrNotExist).
+func (ms *MemoryStorage) LoadSnapshotFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return ms.LoadSnapshot(file)
+}
+
+// Snapshotter saves MemoryStorage to one file, replacing it atomically so a
+// crash mid-write never leaves a truncated snapshot behind
+type Snapshotter struct {
+	storage *MemoryStorage
+	path    string
+
+	mutex sync.Mutex // Serialises saves so an older state never overwrites a newer one
+}
+
+// NewSnapshotter creates a snapshotter that writes storage to path
+func NewSnapshotter(storage *MemoryStorage, path string) *Snapshotter {
+	return &Snapshotter{storage: storage, path: path}
+}
+
+// Path returns where snapshots are written
+func (s *Snapshotter) Path() string {
+	return s.path
+}
+
+// Save writes a snapshot to a temporary file next to the target, flushes it
+// to disk and renames it into place
+func (s *Snapshotter) Save() (SnapshotInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return SnapshotInfo{}, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+"-*")
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	// Removing fails harmlessly once the file has been renamed into place
+	defer os.Remove(tmp.Name())
+
+	info, err := s.storage.WriteSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return SnapshotInfo{}, err
+	}
+	syncDir(dir)
+
+	info.Path = s.path
+	return info, nil
+}
+
+// Start saves a snapshot every interval in the background until the
+// returned stop function is called. Stopping saves one final snapshot.
+func (s *Snapshotter) Start(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer close(finished)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				s.saveAndLog()
+				return
+			case <-ticker.C:
+				s.saveAndLog()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+func (s *Snapshotter) saveAndLog() {
+	if _, err := s.Save(); err != nil {
+		log.Printf("failed to save snapshot to %s: %v", s.path, err)
+	}
+}
+
+// syncDir flushes a directory entry so a rename survives power loss. Not
+// every platform supports it, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"lab03-backend/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	original := NewMemoryStorage()
+	original.Create("alice", "hello")
+	original.Reply(1, "bob", "hi alice")
+	original.Update(1, "hello, edited")
+	original.Create("carol", "deleted later")
+	original.Delete(3)
+	original.React(1, "bob", "👍")
+	original.AddAttachment(1, models.Attachment{Filename: "a.png", SHA256: "abc"})
+
+	var buf bytes.Buffer
+	info, err := original.WriteSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+	if info.Messages != 3 || info.Bytes != int64(buf.Len()) {
+		t.Errorf("Unexpected snapshot info %+v", info)
+	}
+
+	restored := NewMemoryStorage()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+
+	message, err := restored.GetByID(1)
+	if err != nil || message.Content != "hello, edited" || message.Version != 2 {
+		t.Errorf("Expected edited message 1, got %+v, %v", message, err)
+	}
+	if revisions, _ := restored.Revisions(1); len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions, got %d", len(revisions))
+	}
+	if _, err := restored.Restore(3); err != nil {
+		t.Errorf("Expected tombstone to survive the snapshot, got %v", err)
+	}
+	if reactions, _ := restored.Reactions(1); len(reactions) != 1 || reactions[0].Users[0] != "bob" {
+		t.Errorf("Expected bob's reaction, got %+v", reactions)
+	}
+	if attachment, err := restored.Attachment(1); err != nil || attachment.SHA256 != "abc" {
+		t.Errorf("Expected attachment metadata, got %+v, %v", attachment, err)
+	}
+	if thread, _ := restored.Thread(1); len(thread.Replies) != 1 {
+		t.Errorf("Expected reply to stay in the thread")
+	}
+
+	created, _ := restored.Create("dave", "new")
+	if created.ID != 4 {
+		t.Errorf("Expected IDs to continue after the snapshot, got %d", created.ID)
+	}
+	attachment, _ := restored.AddAttachment(4, models.Attachment{})
+	if attachment.ID != 2 {
+		t.Errorf("Expected attachment IDs to continue after the snapshot, got %d", attachment.ID)
+	}
+}
+
+func TestLoadSnapshotRejectsUnknownFormat(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Create("alice", "keep me")
+
+	err := storage.LoadSnapshot(strings.NewReader(`{"format": 99}`))
+	if !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat, got %v", err)
+	}
+	if err := storage.LoadSnapshot(strings.NewReader(`{"format": 1`)); err == nil {
+		t.Error("Expected truncated snapshot to fail")
+	}
+	if storage.Count() != 1 {
+		t.Error("Expected a failed load to leave the current state alone")
+	}
+}
+
+func TestSnapshotterSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "snapshot.json")
+	storage := NewMemoryStorage()
+	storage.Create("alice", "hello")
+
+	snapshotter := NewSnapshotter(storage, path)
+	info, err := snapshotter.Save()
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if info.Path != path || info.Messages != 1 {
+		t.Errorf("Unexpected snapshot info %+v", info)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot file, found %d entries", len(entries))
+	}
+
+	storage.Create("bob", "written on stop")
+	stop := snapshotter.Start(time.Hour)
+	stop()
+
+	restored := NewMemoryStorage()
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("LoadSnapshotFile failed: %v", err)
+	}
+	if restored.Count() != 2 {
+		t.Errorf("Expected final snapshot on stop with 2 messages, got %d", restored.Count())
+	}
+
+	if err := restored.LoadSnapshotFile(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lab04-backend/models"
+	"lab04-backend/repository"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	source := openTestDB(t)
+	users := repository.NewUserRepository(source)
+	posts := repository.NewPostRepository(source)
+
+	alice, _ := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	bob, _ := users.Create(&models.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+	if alice == nil || bob == nil {
+		t.Fatal("Failed to create users")
+	}
+	posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Commas, \"quotes\"", Content: "Line one\nline two", Published: true})
+	posts.Create(&models.CreatePostRequest{UserID: bob.ID, Title: "Bob's draft"})
+	removed, _ := posts.Create(&models.CreatePostRequest{UserID: bob.ID, Title: "Removed post"})
+	if err := posts.Delete(removed.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	for _, format := range []Format{CSV, NDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			exporter := NewExporter(source)
+			var usersOut, postsOut bytes.Buffer
+			if count, err := exporter.ExportUsers(context.Background(), &usersOut, format); err != nil || count != 2 {
+				t.Fatalf("ExportUsers() = %d, %v; expected 2 users", count, err)
+			}
+			if count, err := exporter.ExportPosts(context.Background(), &postsOut, format); err != nil || count != 2 {
+				t.Fatalf("ExportPosts() = %d, %v; expected 2 posts without the deleted one", count, err)
+			}
+
+			// An export loads into an empty database unchanged
+			target := openTestDB(t)
+			importer := NewImporter(target)
+			if result, err := importer.ImportUsers(context.Background(), &usersOut, format, nil); err != nil || result.Rejected != 0 {
+				t.Fatalf("ImportUsers() = %+v, %v", result, err)
+			}
+			if result, err := importer.ImportPosts(context.Background(), &postsOut, format, nil); err != nil || result.Rejected != 0 {
+				t.Fatalf("ImportPosts() = %+v, %v", result, err)
+			}
+
+			imported, err := repository.NewPostRepository(target).GetAll()
+			if err != nil || len(imported) != 2 {
+				t.Fatalf("Expected 2 imported posts, got %d, %v", len(imported), err)
+			}
+			byTitle := map[string]models.Post{}
+			for _, post := range imported {
+				byTitle[post.Title] = post
+			}
+			first, ok := byTitle[`Commas, "quotes"`]
+			if !ok || first.Content != "Line one\nline two" || !first.Published {
+				t.Errorf("Expected the published post to survive intact, got %+v", imported)
+			}
+			if _, ok := byTitle["Bob's draft"]; !ok {
+				t.Errorf("Expected Bob's draft, got %+v", imported)
+			}
+		})
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	db := openTestDB(t)
+	alice, err := repository.NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	exporter := NewExporter(db)
+
+	var out bytes.Buffer
+	if _, err := exporter.ExportUsers(context.Background(), &out, CSV); err != nil {
+		t.Fatalf("ExportUsers() failed: %v", err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected a header and one row, got %q, %v", records, err)
+	}
+	if got := strings.Join(records[0], ","); got != "id,name,email,created_at,updated_at" {
+		t.Errorf("Unexpected CSV header %q", got)
+	}
+
+	out.Reset()
+	if _, err := exporter.ExportUsers(context.Background(), &out, NDJSON); err != nil {
+		t.Fatalf("ExportUsers() failed: %v", err)
+	}
+	var user models.User
+	if err := json.Unmarshal(out.Bytes(), &user); err != nil || user.ID != alice.ID || user.Email != alice.Email {
+		t.Errorf("Expected Alice as one JSON line, got %q, %v", out.String(), err)
+	}
+	if !user.CreatedAt.Equal(alice.CreatedAt) {
+		t.Errorf("Expected created_at %v, got %v", alice.CreatedAt, user.CreatedAt)
+	}
+
+	if _, err := exporter.ExportUsers(context.Background(), &out, Format("xml")); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{"users.csv": CSV, "posts.ndjson": NDJSON, "posts.JSONL": NDJSON}
+	for path, want := range tests {
+		if got, err := FormatOf(path); err != nil || got != want {
+			t.Errorf("FormatOf(%q) = %q, %v; expected %q", path, got, err, want)
+		}
+	}
+	if _, err := FormatOf("users.xml"); err == nil {
+		t.Error("Expected an error for an unknown extension")
+	}
+}
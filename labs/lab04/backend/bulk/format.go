@@ -0,0 +1,216 @@
+// Package bulk imports users and posts from CSV or NDJSON files and exports
+// them in the same formats. Both directions stream, so files of any size
+// are handled with memory bounded by the import batch size.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Format is a file format for import and export
+type Format string
+
+// Supported formats
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+// maxLineBytes bounds one NDJSON line, and so the size of one record
+const maxLineBytes = 1 << 20
+
+// ParseFormat accepts the format names used on the command line
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return CSV, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q", name)
+}
+
+// FormatOf infers a file's format from its extension
+func FormatOf(path string) (Format, error) {
+	format, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return "", fmt.Errorf("cannot tell the format of %s from its extension", path)
+	}
+	return format, nil
+}
+
+// record is one row of an import file as read, before it is decoded
+type record struct {
+	line   int
+	fields map[string]string // CSV column values by header name
+	values []string          // CSV values in file order, for the reject file
+	raw    []byte            // NDJSON line
+	err    error             // Why the row could not be read, if it could not
+}
+
+// unmarshal decodes an NDJSON record into v
+func (r record) unmarshal(v interface{}) error {
+	if err := json.Unmarshal(r.raw, v); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return nil
+}
+
+// source reads records from an import file one at a time
+type source interface {
+	// next returns the next record, or io.EOF after the last one
+	next() (record, error)
+}
+
+// newSource reads records in format from r. A CSV file must start with a
+// header row naming at least the required columns.
+func newSource(r io.Reader, format Format, required ...string) (source, error) {
+	switch format {
+	case CSV:
+		reader := csv.NewReader(r)
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil, errors.New("CSV file has no header row")
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+		for _, column := range required {
+			if !slices.Contains(header, column) {
+				return nil, fmt.Errorf("CSV header is missing the %q column", column)
+			}
+		}
+		return &csvSource{reader: reader, header: header}, nil
+	case NDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+		return &ndjsonSource{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type csvSource struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (s *csvSource) next() (record, error) {
+	values, err := s.reader.Read()
+	if err == io.EOF {
+		return record{}, io.EOF
+	}
+	// A row with the wrong number of fields is still a row; anything else
+	// leaves the reader unsure where the next row starts
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return record{}, err
+	}
+	line, _ := s.reader.FieldPos(0)
+	rec := record{line: line, values: values}
+	if err != nil {
+		rec.err = fmt.Errorf("expected %d fields, got %d", len(s.header), len(values))
+		return rec, nil
+	}
+
+	rec.fields = make(map[string]string, len(values))
+	for i, value := range values {
+		rec.fields[s.header[i]] = value
+	}
+	return rec, nil
+}
+
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *ndjsonSource) next() (record, error) {
+	for s.scanner.Scan() {
+		s.line++
+		raw := bytes.TrimSpace(s.scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		// The scanner reuses its buffer, and the record outlives the scan
+		return record{line: s.line, raw: bytes.Clone(raw)}, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return record{}, fmt.Errorf("line %d: %v", s.line+1, err)
+	}
+	return record{}, io.EOF
+}
+
+// rejectWriter writes rows that could not be imported, with the reason,
+// in the format of the import file
+type rejectWriter interface {
+	write(rec record, reason string) error
+	flush() error
+}
+
+func newRejectWriter(w io.Writer, format Format, src source) rejectWriter {
+	if w == nil {
+		return discardRejects{}
+	}
+	if format == CSV {
+		return &csvRejects{writer: csv.NewWriter(w), header: src.(*csvSource).header}
+	}
+	return &ndjsonRejects{encoder: json.NewEncoder(w)}
+}
+
+type discardRejects struct{}
+
+func (discardRejects) write(record, string) error { return nil }
+func (discardRejects) flush() error               { return nil }
+
+// csvRejects writes the original row after line and reason columns
+type csvRejects struct {
+	writer      *csv.Writer
+	header      []string
+	wroteHeader bool
+}
+
+func (w *csvRejects) write(rec record, reason string) error {
+	if !w.wroteHeader {
+		if err := w.writer.Write(append([]string{"line", "reason"}, w.header...)); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	return w.writer.Write(append([]string{fmt.Sprint(rec.line), reason}, rec.values...))
+}
+
+func (w *csvRejects) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonRejects writes {"line", "reason", "record"} objects, where record
+// is the original line: as JSON if it parses, otherwise as a string
+type ndjsonRejects struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonRejects) write(rec record, reason string) error {
+	var original interface{} = string(rec.raw)
+	if json.Valid(rec.raw) {
+		original = json.RawMessage(rec.raw)
+	}
+	return w.encoder.Encode(struct {
+		Line   int         `json:"line"`
+		Reason string      `json:"reason"`
+		Record interface{} `json:"record"`
+	}{rec.line, reason, original})
+}
+
+func (w *ndjsonRejects) flush() error { return nil }
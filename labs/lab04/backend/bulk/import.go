@@ -0,0 +1,241 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+	"lab04-backend/repository"
+)
+
+// DefaultBatchSize is how many rows an import commits per transaction
+const DefaultBatchSize = 500
+
+// ImportResult counts the rows of one import
+type ImportResult struct {
+	Imported int
+	Rejected int
+}
+
+// Importer loads users and posts from CSV or NDJSON files. Rows are
+// inserted in batches, one transaction per batch. A row that fails
+// validation or a database constraint is written to the reject file with
+// the reason and the rest of its batch is kept.
+type Importer struct {
+	uow *repository.UnitOfWork
+
+	// BatchSize is how many rows are committed per transaction
+	BatchSize int
+}
+
+// NewImporter creates an Importer with the default batch size
+func NewImporter(db *sql.DB) *Importer {
+	return &Importer{uow: repository.NewUnitOfWork(db), BatchSize: DefaultBatchSize}
+}
+
+// userRow is a user in an import file
+type userRow struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// postRow is a post in an import file. Its author is given by email so
+// files can be prepared without knowing database ids.
+type postRow struct {
+	AuthorEmail string `json:"author_email"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	Published   bool   `json:"published"`
+}
+
+// rejection is a row-level import failure. Any other error stops the
+// import.
+type rejection struct {
+	reason string
+}
+
+func (r *rejection) Error() string { return r.reason }
+
+func reject(format string, args ...interface{}) error {
+	return &rejection{reason: fmt.Sprintf(format, args...)}
+}
+
+// ImportUsers reads users from r, one per CSV row with name and email
+// columns or per NDJSON line with name and email fields, and writes the
+// rows it rejects to rejects, which may be nil. Users whose email is
+// already taken are rejected.
+func (im *Importer) ImportUsers(ctx context.Context, r io.Reader, format Format, rejects io.Writer) (*ImportResult, error) {
+	src, err := newSource(r, format, "name", "email")
+	if err != nil {
+		return nil, err
+	}
+	return im.run(ctx, src, newRejectWriter(rejects, format, src), func(tx *repository.Tx, rec record) error {
+		row, err := decodeUser(rec)
+		if err != nil {
+			return reject("%v", err)
+		}
+		req := &models.CreateUserRequest{Name: strings.TrimSpace(row.Name), Email: strings.TrimSpace(row.Email)}
+		if err := req.Validate(); err != nil {
+			return reject("%v", err)
+		}
+		if _, err := tx.Users.Create(req); err != nil {
+			if database.IsConstraintViolation(err) {
+				return reject("email %s already exists", req.Email)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// ImportPosts reads posts from r, with author_email, title, content and
+// published columns or fields, and writes the rows it rejects to rejects,
+// which may be nil. Authors are looked up by email and must already exist.
+func (im *Importer) ImportPosts(ctx context.Context, r io.Reader, format Format, rejects io.Writer) (*ImportResult, error) {
+	src, err := newSource(r, format, "author_email", "title")
+	if err != nil {
+		return nil, err
+	}
+
+	// Imports usually hold many posts per author, so ids found are kept
+	// for the rest of the import
+	authors := make(map[string]int)
+	return im.run(ctx, src, newRejectWriter(rejects, format, src), func(tx *repository.Tx, rec record) error {
+		row, err := decodePost(rec)
+		if err != nil {
+			return reject("%v", err)
+		}
+		email := strings.TrimSpace(row.AuthorEmail)
+		if email == "" {
+			return reject("author_email is required")
+		}
+		authorID, ok := authors[email]
+		if !ok {
+			author, err := tx.Users.GetByEmail(email)
+			if errors.Is(err, sql.ErrNoRows) {
+				return reject("unknown author %s", email)
+			}
+			if err != nil {
+				return err
+			}
+			authorID = author.ID
+			authors[email] = authorID
+		}
+
+		req := &models.CreatePostRequest{UserID: authorID, Title: row.Title, Content: row.Content, Published: row.Published}
+		if err := req.Validate(); err != nil {
+			return reject("%v", err)
+		}
+		if _, err := tx.Posts.Create(req); err != nil {
+			if database.IsConstraintViolation(err) {
+				return reject("%v", err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// run inserts the records of src in batches. Each row gets a savepoint so
+// a rejected row leaves the rest of its batch intact; Postgres would
+// otherwise abort the whole transaction on a constraint violation.
+func (im *Importer) run(ctx context.Context, src source, rejects rejectWriter, insert func(tx *repository.Tx, rec record) error) (*ImportResult, error) {
+	batchSize := im.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	result := &ImportResult{}
+	batch := make([]record, 0, batchSize)
+	for done := false; !done; {
+		batch = batch[:0]
+		for len(batch) < batchSize {
+			rec, err := src.next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				return result, err
+			}
+			batch = append(batch, rec)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		// The unit of work may retry the batch, so its outcome is only
+		// recorded once it commits
+		type rejected struct {
+			rec    record
+			reason string
+		}
+		var batchRejects []rejected
+		err := im.uow.Do(ctx, func(tx *repository.Tx) error {
+			batchRejects = batchRejects[:0]
+			for _, rec := range batch {
+				if rec.err != nil {
+					batchRejects = append(batchRejects, rejected{rec, rec.err.Error()})
+					continue
+				}
+				err := tx.Savepoint(func(tx *repository.Tx) error { return insert(tx, rec) })
+				var rej *rejection
+				if errors.As(err, &rej) {
+					batchRejects = append(batchRejects, rejected{rec, rej.reason})
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("line %d: %w", rec.line, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, r := range batchRejects {
+			if err := rejects.write(r.rec, r.reason); err != nil {
+				return result, fmt.Errorf("failed to write reject file: %v", err)
+			}
+		}
+		result.Imported += len(batch) - len(batchRejects)
+		result.Rejected += len(batchRejects)
+	}
+
+	if err := rejects.flush(); err != nil {
+		return result, fmt.Errorf("failed to write reject file: %v", err)
+	}
+	return result, nil
+}
+
+func decodeUser(rec record) (userRow, error) {
+	var row userRow
+	if rec.raw != nil {
+		return row, rec.unmarshal(&row)
+	}
+	row.Name, row.Email = rec.fields["name"], rec.fields["email"]
+	return row, nil
+}
+
+func decodePost(rec record) (postRow, error) {
+	var row postRow
+	if rec.raw != nil {
+		return row, rec.unmarshal(&row)
+	}
+	row.AuthorEmail, row.Title, row.Content = rec.fields["author_email"], rec.fields["title"], rec.fields["content"]
+	if published := strings.TrimSpace(rec.fields["published"]); published != "" {
+		value, err := strconv.ParseBool(published)
+		if err != nil {
+			return row, fmt.Errorf("published must be true or false, got %q", published)
+		}
+		row.Published = value
+	}
+	return row, nil
+}
@@ -0,0 +1,193 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/repository"
+)
+
+// openTestDB returns a migrated SQLite database that is removed when the
+// test ends
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	config := database.DefaultConfig()
+	config.DatabasePath = filepath.Join(t.TempDir(), "test_bulk.db")
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+	t.Cleanup(func() { database.CloseDB(db) })
+
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+func TestImportUsersCSV(t *testing.T) {
+	db := openTestDB(t)
+	importer := NewImporter(db)
+	importer.BatchSize = 2
+
+	input := "name,email\n" +
+		"Alice,alice@example.com\n" +
+		"Bob,bob@example.com\n" +
+		"X,x@example.com\n" + // Name too short
+		"Carol,not-an-email\n" +
+		"Alice Again,alice@example.com\n" + // Duplicate
+		"Dave,dave@example.com,extra\n" + // Wrong field count
+		" Erin , erin@example.com \n"
+	var rejects bytes.Buffer
+	result, err := importer.ImportUsers(context.Background(), strings.NewReader(input), CSV, &rejects)
+	if err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+	if result.Imported != 3 || result.Rejected != 4 {
+		t.Errorf("Expected 3 imported and 4 rejected, got %+v", result)
+	}
+
+	users := repository.NewUserRepository(db)
+	if count, _ := users.Count(); count != 3 {
+		t.Errorf("Expected 3 users in the database, got %d", count)
+	}
+	if erin, err := users.GetByEmail("erin@example.com"); err != nil || erin.Name != "Erin" {
+		t.Errorf("Expected Erin with spaces trimmed, got %+v, %v", erin, err)
+	}
+
+	// Rows rejected for their field count keep all their fields
+	reader := csv.NewReader(&rejects)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read reject file: %v", err)
+	}
+	want := [][]string{
+		{"line", "reason", "name", "email"},
+		{"4", "name must be at least 2 characters", "X", "x@example.com"},
+		{"5", "email is not a valid address", "Carol", "not-an-email"},
+		{"6", "email alice@example.com already exists", "Alice Again", "alice@example.com"},
+		{"7", "expected 2 fields, got 3", "Dave", "dave@example.com", "extra"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d reject rows, got %q", len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("Reject row %d: expected %q, got %q", i, want[i], records[i])
+		}
+	}
+}
+
+func TestImportPostsNDJSON(t *testing.T) {
+	db := openTestDB(t)
+	importer := NewImporter(db)
+
+	users := `{"name": "Alice", "email": "alice@example.com"}` + "\n"
+	if _, err := importer.ImportUsers(context.Background(), strings.NewReader(users), NDJSON, nil); err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+
+	input := `{"author_email": "alice@example.com", "title": "First post", "content": "Hello", "published": true}
+{"author_email": "alice@example.com", "title": "A draft"}
+
+{"author_email": "nobody@example.com", "title": "Orphan post"}
+{"author_email": "alice@example.com", "title": "Empty", "published": true}
+{"author_email": "alice@example.com", "title":
+`
+	var rejects bytes.Buffer
+	result, err := importer.ImportPosts(context.Background(), strings.NewReader(input), NDJSON, &rejects)
+	if err != nil {
+		t.Fatalf("ImportPosts() failed: %v", err)
+	}
+	if result.Imported != 2 || result.Rejected != 3 {
+		t.Errorf("Expected 2 imported and 3 rejected, got %+v", result)
+	}
+
+	posts, err := repository.NewPostRepository(db).GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() failed: %v", err)
+	}
+	if len(posts) != 2 || !posts[0].Published && !posts[1].Published {
+		t.Errorf("Expected the two valid posts, one published, got %+v", posts)
+	}
+
+	type rejectLine struct {
+		Line   int             `json:"line"`
+		Reason string          `json:"reason"`
+		Record json.RawMessage `json:"record"`
+	}
+	var lines []rejectLine
+	decoder := json.NewDecoder(&rejects)
+	for decoder.More() {
+		var line rejectLine
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("Failed to read reject file: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 rejects, got %+v", lines)
+	}
+	if lines[0].Line != 4 || lines[0].Reason != "unknown author nobody@example.com" {
+		t.Errorf("Unexpected first reject: %+v", lines[0])
+	}
+	if lines[1].Line != 5 || lines[1].Reason != "content is required for published posts" {
+		t.Errorf("Unexpected second reject: %+v", lines[1])
+	}
+	// Lines that are not JSON are kept as strings
+	var original string
+	if lines[2].Line != 6 || !strings.HasPrefix(lines[2].Reason, "invalid JSON") || json.Unmarshal(lines[2].Record, &original) != nil {
+		t.Errorf("Unexpected third reject: %+v", lines[2])
+	}
+}
+
+func TestImportPostsCSVPublished(t *testing.T) {
+	db := openTestDB(t)
+	importer := NewImporter(db)
+
+	users := "name,email\nAlice,alice@example.com\n"
+	if _, err := importer.ImportUsers(context.Background(), strings.NewReader(users), CSV, nil); err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+
+	input := "author_email,title,content,published\n" +
+		"alice@example.com,Published post,Hello,true\n" +
+		"alice@example.com,Draft post,,\n" +
+		"alice@example.com,Maybe post,Hello,maybe\n"
+	result, err := importer.ImportPosts(context.Background(), strings.NewReader(input), CSV, nil)
+	if err != nil {
+		t.Fatalf("ImportPosts() failed: %v", err)
+	}
+	if result.Imported != 2 || result.Rejected != 1 {
+		t.Errorf("Expected 2 imported and 1 rejected, got %+v", result)
+	}
+
+	published, err := repository.NewPostRepository(db).GetPublished()
+	if err != nil || len(published) != 1 || published[0].Title != "Published post" {
+		t.Errorf("Expected only the published post to be published, got %+v, %v", published, err)
+	}
+}
+
+func TestImportHeaderErrors(t *testing.T) {
+	db := openTestDB(t)
+	importer := NewImporter(db)
+
+	if _, err := importer.ImportUsers(context.Background(), strings.NewReader(""), CSV, nil); err == nil {
+		t.Error("Expected an error for a file without a header")
+	}
+	if _, err := importer.ImportUsers(context.Background(), strings.NewReader("name\nAlice\n"), CSV, nil); err == nil {
+		t.Error("Expected an error for a header without the email column")
+	}
+	if _, err := importer.ImportPosts(context.Background(), strings.NewReader(""), Format("xml"), nil); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,185 @@
+// Command bulk imports users and posts into the lab04 database from CSV or
+// NDJSON files, and exports them in the same formats.
+//
+// Usage:
+//
+//	bulk [flags] import users|posts FILE   load rows from FILE, writing rejected rows to FILE.rejects.EXT
+//	bulk [flags] export users|posts [FILE] write all rows to FILE, or to stdout
+//
+// The format comes from the file extension (.csv, .ndjson or .jsonl) unless
+// -format is given. Import users before the posts that refer to them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lab04-backend/bulk"
+	"lab04-backend/database"
+)
+
+func main() {
+	dialect := flag.String("dialect", string(database.SQLite), "database dialect: sqlite3 or postgres")
+	dbPath := flag.String("db", database.DefaultConfig().DatabasePath, "SQLite database file, or a Postgres connection URL")
+	format := flag.String("format", "", "csv or ndjson (default: from the file extension, csv for stdout)")
+	rejects := flag.String("rejects", "", "file for rejected rows (import only; default: FILE.rejects.EXT)")
+	batchSize := flag.Int("batch", bulk.DefaultBatchSize, "rows per transaction (import only)")
+	flag.Usage = usage
+	flag.Parse()
+
+	opts := options{dialect: *dialect, dbPath: *dbPath, format: *format, rejects: *rejects, batchSize: *batchSize}
+	if err := run(opts, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "bulk:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), `Usage: bulk [flags] <command> users|posts [FILE]
+
+Commands:
+  import users|posts FILE     load rows from FILE, writing rejected rows to FILE.rejects.EXT
+  export users|posts [FILE]   write all rows to FILE, or to stdout
+
+Flags:`)
+	flag.PrintDefaults()
+}
+
+type options struct {
+	dialect   string
+	dbPath    string
+	format    string
+	rejects   string
+	batchSize int
+}
+
+func run(opts options, args []string) error {
+	if len(args) < 2 {
+		flag.Usage()
+		return fmt.Errorf("no command given")
+	}
+	command, table, args := args[0], args[1], args[2:]
+	if table != "users" && table != "posts" {
+		return fmt.Errorf("unknown table %q: expected users or posts", table)
+	}
+
+	dialect, err := database.ParseDialect(opts.dialect)
+	if err != nil {
+		return err
+	}
+	config := database.DefaultConfig()
+	config.Dialect = dialect
+	config.DatabasePath = opts.dbPath
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB(db)
+
+	ctx := context.Background()
+	switch command {
+	case "import":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: bulk import %s FILE", table)
+		}
+		format, err := formatFor(opts.format, args[0])
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		rejectsPath := opts.rejects
+		if rejectsPath == "" {
+			rejectsPath = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".rejects" + filepath.Ext(args[0])
+		}
+		rejectsFile, err := os.Create(rejectsPath)
+		if err != nil {
+			return err
+		}
+		defer rejectsFile.Close()
+
+		importer := bulk.NewImporter(db)
+		importer.BatchSize = opts.batchSize
+		importFn := importer.ImportUsers
+		if table == "posts" {
+			importFn = importer.ImportPosts
+		}
+		result, err := importFn(ctx, in, format, rejectsFile)
+		if result != nil {
+			fmt.Fprintf(os.Stderr, "Imported %d %s, rejected %d\n", result.Imported, table, result.Rejected)
+		}
+		if err != nil {
+			return err
+		}
+		if err := rejectsFile.Close(); err != nil {
+			return err
+		}
+		if result.Rejected == 0 {
+			return os.Remove(rejectsPath)
+		}
+		fmt.Fprintln(os.Stderr, "Rejected rows written to", rejectsPath)
+		return nil
+	case "export":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: bulk export %s [FILE]", table)
+		}
+		var out io.Writer = os.Stdout
+		path := ""
+		if len(args) == 1 {
+			path = args[0]
+		}
+		format, err := formatFor(opts.format, path)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			file, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		exporter := bulk.NewExporter(db)
+		exportFn := exporter.ExportUsers
+		if table == "posts" {
+			exportFn = exporter.ExportPosts
+		}
+		count, err := exportFn(ctx, out, format)
+		if err != nil {
+			return err
+		}
+		if file, ok := out.(*os.File); ok && path != "" {
+			if err := file.Close(); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d %s\n", count, table)
+		return nil
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// formatFor picks the format named by flag, or else the one of path's
+// extension. Stdout defaults to CSV.
+func formatFor(flag, path string) (bulk.Format, error) {
+	if flag != "" {
+		return bulk.ParseFormat(flag)
+	}
+	if path == "" {
+		return bulk.CSV, nil
+	}
+	return bulk.FormatOf(path)
+}
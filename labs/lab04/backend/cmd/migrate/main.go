@@ -0,0 +1,128 @@
+// Command migrate manages the lab04 database schema using the migrations
+// embedded in the binary.
+//
+// Usage:
+//
+//	migrate [-dialect d] [-db path] up               apply all pending migrations
+//	migrate [-dialect d] [-db path] down [version]   roll back the last migration, or all migrations newer than version
+//	migrate [-dialect d] [-db path] status           list applied and pending migrations
+//	migrate [-dir path] create NAME     write a new timestamped migration for every dialect
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"lab04-backend/database"
+)
+
+func main() {
+	dialect := flag.String("dialect", string(database.SQLite), "database dialect: sqlite3 or postgres")
+	dbPath := flag.String("db", database.DefaultConfig().DatabasePath, "SQLite database file, or a Postgres connection URL")
+	dir := flag.String("dir", database.MigrationsDir, "directory for new migrations (create only)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if err := run(*dialect, *dbPath, *dir, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), `Usage: migrate [flags] <command> [args]
+
+Commands:
+  up               apply all pending migrations
+  down [version]   roll back the last migration, or all migrations newer than version
+  status           list applied and pending migrations
+  create NAME      write a new timestamped migration for every dialect
+
+Flags:`)
+	flag.PrintDefaults()
+}
+
+func run(dialectName, dbPath, dir string, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("no command given")
+	}
+
+	command, args := args[0], args[1:]
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: migrate create NAME")
+		}
+		path, err := database.CreateMigration(dir, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created", path)
+		return nil
+	}
+
+	dialect, err := database.ParseDialect(dialectName)
+	if err != nil {
+		return err
+	}
+	config := database.DefaultConfig()
+	config.Dialect = dialect
+	config.DatabasePath = dbPath
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB(db)
+
+	switch command {
+	case "up":
+		if err := database.RunMigrations(db); err != nil {
+			return err
+		}
+		return printStatus(db)
+	case "down":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: migrate down [version]")
+		}
+		if len(args) == 1 {
+			version, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[0])
+			}
+			if err := database.RollbackToVersion(db, version); err != nil {
+				return err
+			}
+		} else if err := database.RollbackMigration(db); err != nil {
+			return err
+		}
+		return printStatus(db)
+	case "status":
+		return printStatus(db)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func printStatus(db *sql.DB) error {
+	statuses, err := database.GetMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tAPPLIED AT\tMIGRATION")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", state, appliedAt, status.Name)
+	}
+	return w.Flush()
+}
@@ -1,16 +1,24 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // Config holds database configuration
 type Config struct {
-	DatabasePath    string
+	Dialect         Dialect // Defaults to SQLite
+	DatabasePath    string  // SQLite file, or a connection URL for Postgres
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
@@ -20,6 +28,7 @@ type Config struct {
 // DefaultConfig returns a default database configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Dialect:         SQLite,
 		DatabasePath:    "./lab04.db",
 		MaxOpenConns:    25,
 		MaxIdleConns:    5,
@@ -28,31 +37,78 @@ func DefaultConfig() *Config {
 	}
 }
 
-// TODO: Implement InitDB function
+// InitDB opens the database described by DefaultConfig
 func InitDB() (*sql.DB, error) {
-	// TODO: Initialize database connection with SQLite
-	// - Open database connection using sqlite3 driver
-	// - Apply connection pool configuration from DefaultConfig()
-	// - Test connection with Ping()
-	// - Return the database connection or error
-	return nil, fmt.Errorf("TODO: implement InitDB function")
+	return InitDBWithConfig(DefaultConfig())
 }
 
-// TODO: Implement InitDBWithConfig function
+// InitDBWithConfig opens the configured database, applies the connection
+// pool settings and checks that it is reachable. A migrated SQLite database
+// also has its full-text index fitted to this binary; see SyncFullTextIndex.
 func InitDBWithConfig(config *Config) (*sql.DB, error) {
-	// TODO: Initialize database connection with custom configuration
-	// - Open database connection using the provided config
-	// - Apply all connection pool settings
-	// - Test connection with Ping()
-	// - Return the database connection or error
-	return nil, fmt.Errorf("TODO: implement InitDBWithConfig function")
+	if config == nil {
+		return nil, fmt.Errorf("database config cannot be nil")
+	}
+	dialect := config.Dialect
+	if dialect == "" {
+		dialect = SQLite
+	}
+
+	dsn := config.DatabasePath
+	if dialect == SQLite {
+		// SQLite leaves foreign keys off unless asked, which would silently
+		// skip the ON DELETE CASCADE clauses in the migrations
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_foreign_keys=on"
+	}
+
+	db, err := sql.Open(dialect.driverName(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	if err := SyncFullTextIndex(context.Background(), db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set up full-text index: %v", err)
+	}
+	return db, nil
 }
 
-// TODO: Implement CloseDB function
+// CloseDB closes a database connection
 func CloseDB(db *sql.DB) error {
-	// TODO: Properly close database connection
-	// - Check if db is not nil
-	// - Close the database connection
-	// - Return any error that occurs
-	return fmt.Errorf("TODO: implement CloseDB function")
+	if db == nil {
+		return fmt.Errorf("database connection cannot be nil")
+	}
+	return db.Close()
+}
+
+// OpenGORM wraps db in a GORM handle for the GORM repositories. GORM uses
+// db's connection pool, so closing db closes both.
+func OpenGORM(db *sql.DB) (*gorm.DB, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+	var dialector gorm.Dialector
+	if DialectOf(db) == Postgres {
+		dialector = postgres.New(postgres.Config{Conn: db})
+	} else {
+		dialector = sqlite.New(sqlite.Config{Conn: db})
+	}
+	gormDB, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GORM: %v", err)
+	}
+	return gormDB, nil
 }
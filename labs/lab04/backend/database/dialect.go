@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose/v3"
+)
+
+// Dialect identifies the SQL database behind a connection
+type Dialect string
+
+// Supported dialects
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// ParseDialect accepts the dialect names used in configuration
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "postgresql", "pgx":
+		return Postgres, nil
+	}
+	return "", fmt.Errorf("unsupported database dialect %q", name)
+}
+
+// DialectOf reports which dialect db speaks, from its driver. Repositories
+// use it so they can keep accepting a plain *sql.DB.
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*stdlib.Driver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
+// driverName is the database/sql driver registered for the dialect
+func (d Dialect) driverName() string {
+	if d == Postgres {
+		return "pgx"
+	}
+	return "sqlite3"
+}
+
+// gooseDialect is the dialect goose records migrations with
+func (d Dialect) gooseDialect() goose.Dialect {
+	if d == Postgres {
+		return goose.DialectPostgres
+	}
+	return goose.DialectSQLite3
+}
+
+// columnTypes fill in the type fields of the migration templates; see
+// package migrations
+type columnTypes struct {
+	Identity    string
+	BigIdentity string
+	BigInt      string
+	Timestamp   string
+	JSON        string
+}
+
+// columnTypes returns the dialect's types for the migration templates
+func (d Dialect) columnTypes() columnTypes {
+	if d == Postgres {
+		return columnTypes{
+			Identity:    "INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY",
+			BigIdentity: "BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY",
+			BigInt:      "BIGINT",
+			Timestamp:   "TIMESTAMPTZ",
+			JSON:        "JSONB",
+		}
+	}
+	// SQLite integers are 64-bit already
+	return columnTypes{
+		Identity:    "INTEGER PRIMARY KEY AUTOINCREMENT",
+		BigIdentity: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		BigInt:      "INTEGER",
+		Timestamp:   "DATETIME",
+		JSON:        "TEXT",
+	}
+}
+
+// Placeholders is the squirrel placeholder format for the dialect
+func (d Dialect) Placeholders() squirrel.PlaceholderFormat {
+	if d == Postgres {
+		return squirrel.Dollar
+	}
+	return squirrel.Question
+}
+
+// Rebind rewrites a query written with ? placeholders for the dialect.
+// Question marks inside quoted strings and identifiers are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// CaseInsensitiveLike is the operator that matches a LIKE pattern ignoring
+// case. SQLite's LIKE already ignores ASCII case and has no ILIKE.
+func (d Dialect) CaseInsensitiveLike() string {
+	if d == Postgres {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// IsConstraintViolation reports whether err is the database rejecting a
+// row for breaking a constraint, such as a duplicate unique value or a
+// missing foreign key, rather than failing for some other reason
+func IsConstraintViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrConstraint
+	}
+	// Postgres puts integrity constraint violations in SQLSTATE class 23
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "23")
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"lab04-backend/migrations"
+)
+
+func TestParseDialect(t *testing.T) {
+	for name, want := range map[string]Dialect{"": SQLite, "sqlite": SQLite, "SQLite3": SQLite, "postgres": Postgres, "pgx": Postgres} {
+		if got, err := ParseDialect(name); err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDialect("mysql"); err == nil {
+		t.Error("Expected an error for an unsupported dialect")
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	// Opening does not connect, so no server is needed
+	pg, err := sql.Open("pgx", "postgres://localhost/lab04")
+	if err != nil {
+		t.Fatalf("sql.Open() failed: %v", err)
+	}
+	defer pg.Close()
+	if got := DialectOf(pg); got != Postgres {
+		t.Errorf("Expected Postgres for the pgx driver, got %q", got)
+	}
+
+	lite, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() failed: %v", err)
+	}
+	defer lite.Close()
+	if got := DialectOf(lite); got != SQLite {
+		t.Errorf("Expected SQLite for the sqlite3 driver, got %q", got)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT * FROM posts WHERE title = ? AND content <> 'why?' AND "odd?col" = ? LIMIT ?`
+	if got := SQLite.Rebind(query); got != query {
+		t.Errorf("Expected SQLite to keep ? placeholders, got %s", got)
+	}
+	want := `SELECT * FROM posts WHERE title = $1 AND content <> 'why?' AND "odd?col" = $2 LIMIT $3`
+	if got := Postgres.Rebind(query); got != want {
+		t.Errorf("Postgres.Rebind() = %s; want %s", got, want)
+	}
+}
+
+func TestRenderMigrations(t *testing.T) {
+	want := map[Dialect]string{SQLite: "AUTOINCREMENT", Postgres: "TIMESTAMPTZ"}
+	for dialect, column := range want {
+		rendered, err := renderMigrations(migrations.FS, dialect)
+		if err != nil {
+			t.Fatalf("renderMigrations(%s) failed: %v", dialect, err)
+		}
+		users, err := fs.ReadFile(rendered, "20250708090008_create_users_table.sql")
+		if err != nil {
+			t.Fatalf("Expected the users migration for %s: %v", dialect, err)
+		}
+		if !strings.Contains(string(users), column) {
+			t.Errorf("Expected %s in the %s users table, got:\n%s", column, dialect, users)
+		}
+		paths, _ := fs.Glob(rendered, "*.sql")
+		for _, path := range paths {
+			if sql, _ := fs.ReadFile(rendered, path); strings.Contains(string(sql), "{{") {
+				t.Errorf("Expected every field filled in for %s, got:\n%s", path, sql)
+			}
+		}
+	}
+
+	// A misspelt field fails instead of leaving an empty column type
+	typo := fstest.MapFS{"1_typo.sql": {Data: []byte("CREATE TABLE t (id {{.Identiy}});")}}
+	if _, err := renderMigrations(typo, SQLite); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
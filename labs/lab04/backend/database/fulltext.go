@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// fullTextVersion is the migration that adds the posts_fts index. It is a
+// Go migration because whether SQLite can build the index depends on the
+// binary: go-sqlite3 only includes FTS5 when built with -tags sqlite_fts5.
+const fullTextVersion int64 = 20250801090000
+
+// fullTextStatements create posts_fts, an external content FTS5 index over
+// the title and content of posts, and the triggers that keep it in sync.
+// Each skips what already exists, so they also repair a partial index.
+var fullTextStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+		title, content,
+		content='posts', content_rowid='id',
+		tokenize='porter unicode61'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	// Index the posts written while the index or its triggers were missing
+	`INSERT INTO posts_fts(posts_fts) VALUES ('rebuild')`,
+}
+
+// fullTextTriggers keep posts_fts in sync with posts
+var fullTextTriggers = []string{"posts_fts_insert", "posts_fts_delete", "posts_fts_update"}
+
+// fullTextState reports whether this binary includes FTS5 and whether the
+// index and all of its triggers exist
+const fullTextState = `SELECT sqlite_compileoption_used('ENABLE_FTS5'),
+	EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts'),
+	(SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ('posts_fts_insert', 'posts_fts_delete', 'posts_fts_update')) = 3`
+
+// upFullText creates the full-text index where SQLite supports it. Other
+// databases record the version without changes and search with LIKE
+// instead, as do SQLite builds without FTS5 until an FTS5 build opens the
+// database; see SyncFullTextIndex.
+func upFullText(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	if dialect != SQLite {
+		return nil
+	}
+	return syncFullText(ctx, tx)
+}
+
+// syncFullText fits posts_fts to this binary. With FTS5 it creates whatever
+// part of the index is missing and rebuilds it. Without FTS5 the triggers
+// would fail every write to posts with "no such module: fts5", so they are
+// dropped; posts_fts itself cannot be dropped without the module and is
+// left for an FTS5 build to rebuild.
+func syncFullText(ctx context.Context, tx *sql.Tx) error {
+	var fts5, exists, triggers bool
+	if err := tx.QueryRowContext(ctx, fullTextState).Scan(&fts5, &exists, &triggers); err != nil {
+		return err
+	}
+
+	if !fts5 {
+		for _, trigger := range fullTextTriggers {
+			if _, err := tx.ExecContext(ctx, `DROP TRIGGER IF EXISTS `+trigger); err != nil {
+				return fmt.Errorf("failed to drop full-text trigger: %v", err)
+			}
+		}
+		return nil
+	}
+	if exists && triggers {
+		return nil
+	}
+	for _, statement := range fullTextStatements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create full-text index: %v", err)
+		}
+	}
+	return nil
+}
+
+// SyncFullTextIndex fits the posts_fts index to this binary once the
+// full-text migration has been applied, since the migration only ran with
+// whichever build applied it. InitDBWithConfig calls it on every open, so
+// a database migrated by a build without FTS5 gets its index from the first
+// FTS5 build to open it, and one migrated by an FTS5 build stays writable
+// from builds without it.
+func SyncFullTextIndex(ctx context.Context, db *sql.DB) error {
+	if DialectOf(db) != SQLite {
+		return nil
+	}
+	var migrated bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, goose.DefaultTablename,
+	).Scan(&migrated)
+	if err != nil || !migrated {
+		return err
+	}
+	var applied bool
+	err = db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM `+goose.DefaultTablename+` WHERE version_id = ?)`, fullTextVersion,
+	).Scan(&applied)
+	if err != nil || !applied {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := syncFullText(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func downFullText(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	if dialect != SQLite {
+		return nil
+	}
+	for _, statement := range []string{
+		`DROP TRIGGER IF EXISTS posts_fts_update`,
+		`DROP TRIGGER IF EXISTS posts_fts_delete`,
+		`DROP TRIGGER IF EXISTS posts_fts_insert`,
+		`DROP TABLE IF EXISTS posts_fts`,
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasFullTextIndex reports whether posts can be searched through posts_fts.
+// That needs the index and its triggers to exist and this binary to include
+// FTS5, since a database migrated by an FTS5 build can be opened by one
+// without it.
+func HasFullTextIndex(ctx context.Context, db *sql.DB) (bool, error) {
+	if DialectOf(db) != SQLite {
+		return false, nil
+	}
+	var fts5, exists, triggers bool
+	if err := db.QueryRowContext(ctx, fullTextState).Scan(&fts5, &exists, &triggers); err != nil {
+		return false, err
+	}
+	return fts5 && exists && triggers, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFullTextMigration(t *testing.T) {
+	config := DefaultConfig()
+	config.DatabasePath = filepath.Join(t.TempDir(), "test_fts.db")
+	db, err := InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer CloseDB(db)
+
+	// Posts from before the migration must be indexed too
+	if err := RollbackToVersion(db, 0); err != nil {
+		t.Fatalf("RollbackToVersion() failed: %v", err)
+	}
+	provider, err := newProvider(db)
+	if err != nil {
+		t.Fatalf("newProvider() failed: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := provider.UpTo(ctx, fullTextVersion-1); err != nil {
+		t.Fatalf("UpTo() failed: %v", err)
+	}
+	db.Exec(`INSERT INTO users (id, name, email) VALUES (1, 'Alice', 'alice@example.com')`)
+	db.Exec(`INSERT INTO posts (id, user_id, title, content) VALUES (1, 1, 'Existing post', 'written before the index')`)
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() failed: %v", err)
+	}
+
+	var fts5 bool
+	db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	available, err := HasFullTextIndex(ctx, db)
+	if err != nil {
+		t.Fatalf("HasFullTextIndex() failed: %v", err)
+	}
+	if available != fts5 {
+		t.Fatalf("Expected the index only when FTS5 is compiled in (fts5=%v), got %v", fts5, available)
+	}
+	if !fts5 {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5 to test the index")
+	}
+
+	match := func(query string) []int {
+		t.Helper()
+		rows, err := db.Query(`SELECT rowid FROM posts_fts WHERE posts_fts MATCH ? ORDER BY rowid`, query)
+		if err != nil {
+			t.Fatalf("MATCH %q failed: %v", query, err)
+		}
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	if ids := match("index"); len(ids) != 1 {
+		t.Errorf("Expected the existing post to be indexed, got %v", ids)
+	}
+	db.Exec(`INSERT INTO posts (id, user_id, title, content) VALUES (2, 1, 'Fresh post', 'added after the index')`)
+	if ids := match("index"); len(ids) != 2 {
+		t.Errorf("Expected the insert trigger to index the new post, got %v", ids)
+	}
+	db.Exec(`UPDATE posts SET content = 'rewritten' WHERE id = 1`)
+	if ids := match("index"); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("Expected the update trigger to reindex the post, got %v", ids)
+	}
+	db.Exec(`DELETE FROM posts WHERE id = 2`)
+	if ids := match("index"); len(ids) != 0 {
+		t.Errorf("Expected the delete trigger to drop the post, got %v", ids)
+	}
+}
+
+func TestSyncFullTextIndex(t *testing.T) {
+	config := DefaultConfig()
+	config.DatabasePath = filepath.Join(t.TempDir(), "test_fts_sync.db")
+	db, err := InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() failed: %v", err)
+	}
+	db.Exec(`INSERT INTO users (id, name, email) VALUES (1, 'Alice', 'alice@example.com')`)
+
+	var fts5 bool
+	db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	if fts5 {
+		// As left by a build without FTS5: the version is recorded but
+		// nothing indexes the posts written since
+		for _, statement := range []string{
+			`DROP TRIGGER posts_fts_insert`, `DROP TRIGGER posts_fts_delete`, `DROP TRIGGER posts_fts_update`, `DROP TABLE posts_fts`,
+		} {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+	} else {
+		// As left by an FTS5 build. Without the module the virtual table
+		// can only be recorded in the schema directly.
+		for _, statement := range append([]string{
+			`PRAGMA writable_schema = ON`,
+			`INSERT INTO sqlite_master (type, name, tbl_name, rootpage, sql)
+				VALUES ('table', 'posts_fts', 'posts_fts', 0, 'CREATE VIRTUAL TABLE posts_fts USING fts5(title, content)')`,
+			`PRAGMA writable_schema = OFF`,
+		}, fullTextStatements[1:4]...) {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	CloseDB(db)
+
+	// Opening the database again fits the index to this build
+	db, err = InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer CloseDB(db)
+	if _, err := db.Exec(`INSERT INTO posts (id, user_id, title, content) VALUES (1, 1, 'Written later', 'still searchable')`); err != nil {
+		t.Fatalf("Expected posts to stay writable, got %v", err)
+	}
+	available, err := HasFullTextIndex(context.Background(), db)
+	if err != nil {
+		t.Fatalf("HasFullTextIndex() failed: %v", err)
+	}
+	if available != fts5 {
+		t.Fatalf("Expected the index only when FTS5 is compiled in (fts5=%v), got %v", fts5, available)
+	}
+	if fts5 {
+		var id int
+		if err := db.QueryRow(`SELECT rowid FROM posts_fts WHERE posts_fts MATCH 'searchable'`).Scan(&id); err != nil || id != 1 {
+			t.Errorf("Expected the index to be recreated with the post, got %d, %v", id, err)
+		}
+	}
+}
@@ -1,48 +1,241 @@
 package database
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing/fstest"
+	"text/template"
+	"time"
+
+	"lab04-backend/migrations"
 
 	"github.com/pressly/goose/v3"
 )
 
-// RunMigrations runs database migrations using goose
-func RunMigrations(db *sql.DB) error {
+// MigrationsDir is where CreateMigration writes new files, relative to the
+// backend directory. Migrations are embedded at build time, so rebuild after
+// adding one.
+const MigrationsDir = "migrations"
+
+// migrationTimeFormat prefixes new migration files, matching goose's
+// timestamped versions
+const migrationTimeFormat = "20060102150405"
+
+// MigrationStatus describes one migration known to the binary
+type MigrationStatus struct {
+	Version   int64
+	Name      string // File name, such as 20250708090008_create_users_table.sql, or a .go name for Go migrations
+	Applied   bool
+	AppliedAt *time.Time // When the migration was applied; nil while pending
+}
+
+// newProvider creates a goose provider over the embedded migrations,
+// rendered for db's dialect
+func newProvider(db *sql.DB) (*goose.Provider, error) {
 	if db == nil {
-		return fmt.Errorf("database connection cannot be nil")
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+	dialect := DialectOf(db)
+	fsys, err := renderMigrations(migrations.FS, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %v", err)
 	}
+	provider, err := goose.NewProvider(dialect.gooseDialect(), db, fsys,
+		goose.WithGoMigrations(dialectGoMigrations(dialect)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %v", err)
+	}
+	return provider, nil
+}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+// renderMigrations fills in the column types of every migration template in
+// fsys for dialect
+func renderMigrations(fsys fs.FS, dialect Dialect) (fs.FS, error) {
+	paths, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	rendered := make(fstest.MapFS, len(paths))
+	for _, path := range paths {
+		source, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(path).Parse(string(source))
+		if err != nil {
+			return nil, err
+		}
+		var sql bytes.Buffer
+		if err := tmpl.Execute(&sql, dialect.columnTypes()); err != nil {
+			return nil, err
+		}
+		rendered[path] = &fstest.MapFile{Data: sql.Bytes(), Mode: 0o444}
 	}
+	return rendered, nil
+}
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
+// goMigration is a migration written in Go, for changes that depend on
+// more than the dialect
+type goMigration struct {
+	version  int64
+	name     string
+	up, down func(ctx context.Context, tx *sql.Tx, dialect Dialect) error
+}
 
-	// Run migrations from the migrations directory
-	if err := goose.Up(db, migrationsDir); err != nil {
-		return fmt.Errorf("failed to run migrations: %v", err)
+// goMigrations run alongside the embedded SQL migrations, ordered by
+// version with them
+var goMigrations = []goMigration{
+	{version: fullTextVersion, name: "create_posts_fts", up: upFullText, down: downFullText},
+}
+
+// dialectGoMigrations binds the Go migrations to a dialect for goose
+func dialectGoMigrations(dialect Dialect) []*goose.Migration {
+	bound := make([]*goose.Migration, 0, len(goMigrations))
+	for _, m := range goMigrations {
+		up, down := m.up, m.down
+		bound = append(bound, goose.NewGoMigration(m.version,
+			&goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error { return up(ctx, tx, dialect) }},
+			&goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error { return down(ctx, tx, dialect) }},
+		))
+	}
+	return bound
+}
+
+// migrationName names a migration in status reports. Go migrations have no
+// file, so they are named like one.
+func migrationName(source *goose.Source) string {
+	for _, m := range goMigrations {
+		if source.Type == goose.TypeGo && m.version == source.Version {
+			return fmt.Sprintf("%d_%s.go", m.version, m.name)
+		}
+	}
+	return filepath.Base(source.Path)
+}
+
+// RunMigrations applies every pending migration
+func RunMigrations(db *sql.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
 	}
 
+	if _, err := provider.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to run migrations: %v", err)
+	}
 	return nil
 }
 
-// TODO: Implement this function
-// RollbackMigration rolls back the last migration using goose
+// RollbackMigration rolls back the most recently applied migration
 func RollbackMigration(db *sql.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := provider.Down(context.Background()); err != nil {
+		return fmt.Errorf("failed to roll back migration: %v", err)
+	}
 	return nil
 }
 
-// TODO: Implement this function
-// GetMigrationStatus checks migration status using goose
-func GetMigrationStatus(db *sql.DB) error {
+// RollbackToVersion rolls back every applied migration newer than version.
+// Version 0 rolls back everything.
+func RollbackToVersion(db *sql.DB, version int64) error {
+	if version < 0 {
+		return fmt.Errorf("target version cannot be negative")
+	}
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := provider.DownTo(context.Background(), version); err != nil {
+		return fmt.Errorf("failed to roll back to version %d: %v", version, err)
+	}
 	return nil
 }
 
-// TODO: Implement this function
-// CreateMigration creates a new migration file
-func CreateMigration(name string) error {
+// GetMigrationStatus reports every embedded migration, oldest first, with
+// whether and when it was applied
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := provider.Status(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %v", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(results))
+	for _, result := range results {
+		status := MigrationStatus{
+			Version: result.Source.Version,
+			Name:    migrationName(result.Source),
+			Applied: result.State == goose.StateApplied,
+		}
+		if status.Applied {
+			appliedAt := result.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// migrationNamePattern finds runs of characters that cannot appear in a
+// migration file name
+var migrationNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// migrationTemplate is the body of a new migration
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+-- TODO: describe the change
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- TODO: undo the change
+-- +goose StatementEnd
+`
+
+// CreateMigration writes an empty timestamped migration named after name
+// into dir and returns its path. The one file serves every dialect; see
+// package migrations for the column types that differ.
+func CreateMigration(dir, name string) (string, error) {
+	slug := strings.Trim(migrationNamePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	path := filepath.Join(dir, time.Now().UTC().Format(migrationTimeFormat)+"_"+slug+".sql")
+	if err := writeMigration(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func writeMigration(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %v", err)
+	}
+	// O_EXCL keeps two migrations created in the same second from clobbering each other
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(migrationTemplate); err != nil {
+		return fmt.Errorf("failed to write migration: %v", err)
+	}
 	return nil
 }
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationRollbackAndStatus(t *testing.T) {
+	config := DefaultConfig()
+	config.DatabasePath = filepath.Join(t.TempDir(), "test_rollback.db")
+	db, err := InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer CloseDB(db)
+
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		t.Fatalf("GetMigrationStatus() failed: %v", err)
+	}
+	if len(statuses) != 6 {
+		t.Fatalf("Expected 5 SQL migrations and 1 Go migration, got %d", len(statuses))
+	}
+	if name := statuses[3].Name; name != "20250801090000_create_posts_fts.go" {
+		t.Errorf("Expected the Go migration to be named like a file, got %q", name)
+	}
+	for _, status := range statuses {
+		if status.Applied || status.AppliedAt != nil {
+			t.Errorf("Expected %s to be pending before migrating", status.Name)
+		}
+	}
+
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() failed: %v", err)
+	}
+	statuses, _ = GetMigrationStatus(db)
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt == nil || time.Since(*status.AppliedAt) > time.Minute {
+			t.Errorf("Expected %s to be applied just now, got %+v", status.Name, status)
+		}
+	}
+
+	// The audit log goes first, then the category hierarchy, the full-text
+	// index and the categories tables
+	if err := RollbackMigration(db); err != nil {
+		t.Fatalf("RollbackMigration() failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM audit_log"); err == nil {
+		t.Error("Expected audit_log to be dropped by rollback")
+	}
+	if err := RollbackMigration(db); err != nil {
+		t.Fatalf("RollbackMigration() failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM category_closure"); err == nil {
+		t.Error("Expected category_closure to be dropped by rollback")
+	}
+	if _, err := db.Exec("SELECT parent_id FROM categories"); err == nil {
+		t.Error("Expected categories.parent_id to be dropped by rollback")
+	}
+	if err := RollbackMigration(db); err != nil {
+		t.Fatalf("RollbackMigration() failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM posts_fts"); err == nil {
+		t.Error("Expected posts_fts to be dropped by rollback")
+	}
+	if err := RollbackMigration(db); err != nil {
+		t.Fatalf("RollbackMigration() failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM categories"); err == nil {
+		t.Error("Expected categories table to be dropped by rollback")
+	}
+
+	first := statuses[0].Version
+	if err := RollbackToVersion(db, first); err != nil {
+		t.Fatalf("RollbackToVersion() failed: %v", err)
+	}
+	statuses, _ = GetMigrationStatus(db)
+	if !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied || statuses[3].Applied || statuses[4].Applied || statuses[5].Applied {
+		t.Errorf("Expected only version %d applied, got %+v", first, statuses)
+	}
+
+	if err := RollbackToVersion(db, 0); err != nil {
+		t.Fatalf("RollbackToVersion(0) failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM users"); err == nil {
+		t.Error("Expected users table to be dropped by full rollback")
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	path, err := CreateMigration(dir, "Add Tags Table!")
+	if err != nil {
+		t.Fatalf("CreateMigration() failed: %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasSuffix(path, "_add_tags_table.sql") {
+		t.Errorf("Expected a sanitised file name in %s, got %s", dir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read migration: %v", err)
+	}
+	if !strings.Contains(string(content), "-- +goose Up") || !strings.Contains(string(content), "-- +goose Down") {
+		t.Errorf("Expected Up and Down sections, got:\n%s", content)
+	}
+
+	if _, err := CreateMigration(dir, "!!!"); err == nil {
+		t.Error("Expected an error for a name without letters or digits")
+	}
+}
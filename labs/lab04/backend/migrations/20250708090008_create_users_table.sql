@@ -2,13 +2,13 @@
 -- +goose StatementBegin
 -- Create users table with proper schema
 CREATE TABLE users (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id {{.Identity}},
     name VARCHAR(100) NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash VARCHAR(255),
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    deleted_at DATETIME NULL
+    created_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    updated_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    deleted_at {{.Timestamp}} NULL
 );
 
 -- Create index for efficient email lookups
@@ -2,14 +2,14 @@
 -- +goose StatementBegin
 -- Create posts table with foreign key relationship to users
 CREATE TABLE posts (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id {{.Identity}},
     user_id INTEGER NOT NULL,
     title VARCHAR(200) NOT NULL,
     content TEXT,
     published BOOLEAN DEFAULT FALSE,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    deleted_at DATETIME NULL,
+    created_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    updated_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    deleted_at {{.Timestamp}} NULL,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
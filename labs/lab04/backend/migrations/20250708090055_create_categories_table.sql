@@ -2,21 +2,21 @@
 -- +goose StatementBegin
 -- Create categories table for GORM example
 CREATE TABLE categories (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id {{.Identity}},
     name VARCHAR(100) NOT NULL UNIQUE,
     description VARCHAR(500),
     color VARCHAR(7), -- Hex color code
     active BOOLEAN DEFAULT TRUE,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    deleted_at DATETIME NULL -- For GORM soft delete
+    created_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    updated_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
+    deleted_at {{.Timestamp}} NULL -- For GORM soft delete
 );
 
 -- Create many-to-many junction table for posts and categories
 CREATE TABLE post_categories (
     post_id INTEGER NOT NULL,
     category_id INTEGER NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_at {{.Timestamp}} DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (post_id, category_id),
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
     FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Let categories nest. parent_id is a category's direct parent, and
+-- category_closure holds every ancestor-descendant pair, so a subtree or
+-- a category's ancestors can be read with a single query.
+ALTER TABLE categories ADD COLUMN parent_id INTEGER NULL REFERENCES categories(id);
+
+CREATE TABLE category_closure (
+    ancestor_id INTEGER NOT NULL,
+    descendant_id INTEGER NOT NULL,
+    depth INTEGER NOT NULL, -- 0 for the category itself, 1 for its children, and so on
+    PRIMARY KEY (ancestor_id, descendant_id),
+    FOREIGN KEY (ancestor_id) REFERENCES categories(id) ON DELETE CASCADE,
+    FOREIGN KEY (descendant_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+-- Existing categories become roots
+INSERT INTO category_closure (ancestor_id, descendant_id, depth)
+SELECT id, id, 0 FROM categories;
+
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX idx_category_closure_descendant_id ON category_closure(descendant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Drop the closure table and the parent column
+DROP INDEX IF EXISTS idx_category_closure_descendant_id;
+DROP INDEX IF EXISTS idx_categories_parent_id;
+DROP TABLE category_closure;
+ALTER TABLE categories DROP COLUMN parent_id;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Record every change to users, posts and categories: who made it, what
+-- kind of change it was, which row it touched and, as a JSON object of
+-- {"field": {"before": ..., "after": ...}}, the values it changed
+CREATE TABLE audit_log (
+    id {{.BigIdentity}},
+    actor VARCHAR(255) NOT NULL,
+    operation VARCHAR(20) NOT NULL, -- create, update, delete, restore or purge
+    table_name VARCHAR(64) NOT NULL,
+    row_id {{.BigInt}} NOT NULL,
+    changes {{.JSON}} NOT NULL,
+    created_at {{.Timestamp}} NOT NULL
+);
+
+-- Create index for fetching the history of a row
+CREATE INDEX idx_audit_log_row ON audit_log(table_name, row_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Drop the audit log and its index
+DROP INDEX IF EXISTS idx_audit_log_row;
+DROP TABLE audit_log;
+-- +goose StatementEnd
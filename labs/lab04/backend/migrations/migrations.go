@@ -0,0 +1,19 @@
+// Package migrations embeds the goose SQL migrations so the binary can
+// migrate a database from any working directory. One set of migrations
+// serves every dialect: the column types SQLite and PostgreSQL disagree on,
+// such as auto-increment keys and timestamps, are written as text/template
+// fields that the database package fills in for the dialect. The fields are
+//
+//	{{.Identity}}     an auto-increment INTEGER primary key
+//	{{.BigIdentity}}  an auto-increment 64-bit primary key
+//	{{.BigInt}}       a 64-bit integer
+//	{{.Timestamp}}    a date and time
+//	{{.JSON}}         a JSON document
+package migrations
+
+import "embed"
+
+// FS holds the migration templates
+//
+//go:embed *.sql
+var FS embed.FS
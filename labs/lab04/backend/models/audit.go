@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// AuditOperation names the kind of change an audit entry records
+type AuditOperation string
+
+// Audited operations
+const (
+	AuditCreate  AuditOperation = "create"
+	AuditUpdate  AuditOperation = "update"
+	AuditDelete  AuditOperation = "delete"  // Soft delete
+	AuditRestore AuditOperation = "restore" // Undoing a soft delete
+	AuditPurge   AuditOperation = "purge"   // Permanent removal
+)
+
+// SystemActor is recorded for changes made without an actor
+const SystemActor = "system"
+
+// AuditEntry records one change to one row
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Operation AuditOperation  `json:"operation"`
+	Table     string          `json:"table"`
+	RowID     int64           `json:"row_id"`
+	Changes   json.RawMessage `json:"changes"` // Field names mapped to AuditChange
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditChange is a field's value before and after a change. A side is nil
+// when the field had no value there, such as before a row was created.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditEntryColumns lists the columns ScanAuditEntries expects, in order
+const AuditEntryColumns = "id, actor, operation, table_name, row_id, changes, created_at"
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor to record in the audit
+// log for changes made with it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or SystemActor
+func ActorFrom(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return SystemActor
+}
+
+// NewAuditEntry describes a change to a row from its values before and
+// after, either of which is nil when the row did not exist. Values are
+// compared in their JSON form, so only fields that appear in it are
+// audited.
+func NewAuditEntry(actor string, op AuditOperation, table string, rowID int64, before, after interface{}) (*AuditEntry, error) {
+	changes, err := AuditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if actor == "" {
+		actor = SystemActor
+	}
+	return &AuditEntry{
+		Actor:     actor,
+		Operation: op,
+		Table:     table,
+		RowID:     rowID,
+		Changes:   changes,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// AuditDiff returns the fields whose JSON values differ between before and
+// after as an object of AuditChange values
+func AuditDiff(before, after interface{}) (json.RawMessage, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]AuditChange)
+	for name, value := range beforeFields {
+		if !bytes.Equal(value, afterFields[name]) {
+			changes[name] = AuditChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = AuditChange{After: value}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+// Fields decodes the entry's changes
+func (e *AuditEntry) Fields() (map[string]AuditChange, error) {
+	var fields map[string]AuditChange
+	err := json.Unmarshal(e.Changes, &fields)
+	return fields, err
+}
+
+// InsertStatement returns the statement that records the entry, with ?
+// placeholders, and its arguments. The changes are passed as text so
+// SQLite stores them as JSON text rather than a blob.
+func (e *AuditEntry) InsertStatement() (string, []interface{}) {
+	return `INSERT INTO audit_log (actor, operation, table_name, row_id, changes, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		[]interface{}{e.Actor, string(e.Operation), e.Table, e.RowID, string(e.Changes), e.CreatedAt}
+}
+
+// ScanAuditEntries scans rows selected with AuditEntryColumns and closes
+// them
+func ScanAuditEntries(rows *sql.Rows) ([]AuditEntry, error) {
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		var changes []byte // Postgres returns jsonb as a string, which RawMessage cannot scan
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Operation, &e.Table, &e.RowID, &changes, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Changes = changes
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
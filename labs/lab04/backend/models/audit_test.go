@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := &User{ID: 1, Name: "Alice", Email: "alice@example.com"}
+	after := &User{ID: 1, Name: "Alicia", Email: "alice@example.com"}
+
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          string
+	}{
+		{
+			name:   "changed field only",
+			before: before,
+			after:  after,
+			want:   `{"name":{"before":"Alice","after":"Alicia"}}`,
+		},
+		{
+			name:   "no changes",
+			before: before,
+			after:  before,
+			want:   `{}`,
+		},
+		{
+			name:   "field set to null",
+			before: map[string]interface{}{"deleted_at": "2025-08-01T09:00:00Z"},
+			after:  map[string]interface{}{"deleted_at": nil},
+			want:   `{"deleted_at":{"before":"2025-08-01T09:00:00Z","after":null}}`,
+		},
+		{
+			name:   "created row has no before",
+			before: nil,
+			after:  map[string]interface{}{"id": 1},
+			want:   `{"id":{"after":1}}`,
+		},
+		{
+			name:   "removed row has no after",
+			before: map[string]interface{}{"id": 1},
+			after:  nil,
+			want:   `{"id":{"before":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AuditDiff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("AuditDiff() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("AuditDiff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActorFrom(t *testing.T) {
+	if got := ActorFrom(context.Background()); got != SystemActor {
+		t.Errorf("ActorFrom() without an actor = %q, want %q", got, SystemActor)
+	}
+	if got := ActorFrom(WithActor(context.Background(), "alice")); got != "alice" {
+		t.Errorf("ActorFrom() = %q, want alice", got)
+	}
+	if got := ActorFrom(WithActor(context.Background(), "")); got != SystemActor {
+		t.Errorf("ActorFrom() with an empty actor = %q, want %q", got, SystemActor)
+	}
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"errors"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
 )
@@ -10,6 +14,7 @@ import (
 // This model demonstrates GORM ORM patterns and relationships
 type Category struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
+	ParentID    *uint          `json:"parent_id,omitempty" gorm:"index"` // Nil for top-level categories; changed through CategoryRepository.Move
 	Name        string         `json:"name" gorm:"size:100;not null;uniqueIndex"`
 	Description string         `json:"description" gorm:"size:500"`
 	Color       string         `json:"color" gorm:"size:7"` // Hex color code
@@ -20,10 +25,13 @@ type Category struct {
 
 	// GORM Associations (demonstrates ORM relationships)
 	Posts []Post `json:"posts,omitempty" gorm:"many2many:post_categories;"`
+
+	auditBefore *Category // Stored values, kept by BeforeUpdate for AfterUpdate to audit
 }
 
 // CreateCategoryRequest represents the payload for creating a category
 type CreateCategoryRequest struct {
+	ParentID    *uint  `json:"parent_id,omitempty"`
 	Name        string `json:"name" validate:"required,min=2,max=100"`
 	Description string `json:"description" validate:"max=500"`
 	Color       string `json:"color" validate:"omitempty,hexcolor"`
@@ -37,91 +45,192 @@ type UpdateCategoryRequest struct {
 	Active      *bool   `json:"active,omitempty"`
 }
 
-// TODO: Implement GORM model methods and hooks
+// CategoryClosure is one ancestor-descendant pair of the category tree.
+// Every category is also paired with itself at depth 0.
+type CategoryClosure struct {
+	AncestorID   uint `gorm:"primaryKey"`
+	DescendantID uint `gorm:"primaryKey"`
+	Depth        int  `gorm:"not null"`
+}
+
+// TableName specifies the closure table's name, which GORM would pluralise
+func (CategoryClosure) TableName() string {
+	return "category_closure"
+}
+
+// CategoryNode is a category in a subtree listing
+type CategoryNode struct {
+	Category
+	Depth int `json:"depth"` // Levels below the root of the subtree
+}
+
+// CategoryPostCount counts the posts filed under a category
+type CategoryPostCount struct {
+	CategoryID       uint   `json:"category_id"`
+	Name             string `json:"name"`
+	PostCount        int64  `json:"post_count"`         // Posts assigned to the category itself
+	SubtreePostCount int64  `json:"subtree_post_count"` // Posts assigned to the category or any descendant, each counted once
+}
+
+// DefaultCategoryColor is given to categories created without a color
+const DefaultCategoryColor = "#007bff"
+
+// Validation errors
+var (
+	ErrCategoryNameRequired = errors.New("category name is required")
+	ErrCategoryNameLength   = errors.New("category name must be 2 to 100 characters")
+	ErrDescriptionTooLong   = errors.New("description must be at most 500 characters")
+	ErrInvalidColor         = errors.New("color must be a hex code such as #007bff")
+)
+
+// colorPattern accepts #rgb and #rrggbb hex colors
+var colorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
 
 // TableName specifies the table name for GORM (optional - GORM auto-infers)
 func (Category) TableName() string {
 	return "categories"
 }
 
-// TODO: Implement BeforeCreate hook
+// BeforeCreate fills in the default color and rejects invalid categories
 func (c *Category) BeforeCreate(tx *gorm.DB) error {
-	// TODO: GORM BeforeCreate hook
-	// - Validate data before creation
-	// - Set default values
-	// - Perform any pre-creation logic
-	// Example: if c.Color == "" { c.Color = "#007bff" }
-	return nil
+	if c.Color == "" {
+		c.Color = DefaultCategoryColor
+	}
+	return c.Validate()
 }
 
-// TODO: Implement AfterCreate hook
+// AfterCreate records the new category in the audit log, as part of the
+// same transaction
 func (c *Category) AfterCreate(tx *gorm.DB) error {
-	// TODO: GORM AfterCreate hook
-	// - Log creation
-	// - Send notifications
-	// - Update cache
-	// Example: log.Printf("Category created: %s", c.Name)
-	return nil
+	return recordCategoryChange(tx, AuditCreate, c.ID, nil, c)
 }
 
-// TODO: Implement BeforeUpdate hook
+// BeforeUpdate keeps the stored category so AfterUpdate can audit what the
+// update changed. Updates without a category ID, such as batch updates
+// through Where, are not audited.
 func (c *Category) BeforeUpdate(tx *gorm.DB) error {
-	// TODO: GORM BeforeUpdate hook
-	// - Validate changes
-	// - Prevent certain updates
-	// - Clean up related data
+	c.auditBefore = nil
+	if c.ID == 0 {
+		return nil
+	}
+	var before Category
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().First(&before, c.ID).Error; err != nil {
+		return err
+	}
+	c.auditBefore = &before
 	return nil
 }
 
-// TODO: Implement Validate method for CreateCategoryRequest
+// AfterUpdate records the changes of an update BeforeUpdate saw, read back
+// from the database since an update may name only some columns. Updates
+// that matched no row, such as of a deleted category, record nothing.
+func (c *Category) AfterUpdate(tx *gorm.DB) error {
+	before := c.auditBefore
+	c.auditBefore = nil
+	if before == nil || tx.Statement.RowsAffected == 0 {
+		return nil
+	}
+	var after Category
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().First(&after, c.ID).Error; err != nil {
+		return err
+	}
+	return recordCategoryChange(tx, AuditUpdate, c.ID, before, &after)
+}
+
+// AfterDelete records a soft delete, with the deleted_at GORM wrote read
+// back from the database, or the last values of a category deleted
+// permanently with Unscoped. Soft deletes that matched no row, such as of
+// a deleted category, record nothing.
+func (c *Category) AfterDelete(tx *gorm.DB) error {
+	if c.ID == 0 {
+		return nil
+	}
+	if tx.Statement.Unscoped {
+		return recordCategoryChange(tx, AuditPurge, c.ID, c, nil)
+	}
+	if tx.Statement.RowsAffected == 0 {
+		return nil
+	}
+	var deleted Category
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Select("deleted_at").First(&deleted, c.ID).Error; err != nil {
+		return err
+	}
+	return recordCategoryChange(tx, AuditDelete, c.ID, map[string]interface{}{"deleted_at": nil}, map[string]interface{}{"deleted_at": deleted.DeletedAt})
+}
+
+// recordCategoryChange writes an audit entry in the hook's transaction,
+// crediting the actor on its context
+func recordCategoryChange(tx *gorm.DB, op AuditOperation, id uint, before, after interface{}) error {
+	entry, err := NewAuditEntry(ActorFrom(tx.Statement.Context), op, "categories", int64(id), before, after)
+	if err != nil {
+		return err
+	}
+	query, args := entry.InsertStatement()
+	return tx.Session(&gorm.Session{NewDB: true}).Exec(query, args...).Error
+}
+
+// Validate checks the category's name, description and color
+func (c *Category) Validate() error {
+	return validateCategory(c.Name, c.Description, c.Color)
+}
+
+// Validate checks the requested name, description and color
 func (req *CreateCategoryRequest) Validate() error {
-	// TODO: Add validation logic for GORM model
-	// - Name should be unique (checked at database level via GORM)
-	// - Color should be valid hex color
-	// - Description should not exceed limits
-	// Example using validator package:
-	// return validator.New().Struct(req)
+	return validateCategory(req.Name, req.Description, req.Color)
+}
+
+func validateCategory(name, description, color string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrCategoryNameRequired
+	}
+	if n := utf8.RuneCountInString(name); n < 2 || n > 100 {
+		return ErrCategoryNameLength
+	}
+	if utf8.RuneCountInString(description) > 500 {
+		return ErrDescriptionTooLong
+	}
+	if color != "" && !colorPattern.MatchString(color) {
+		return ErrInvalidColor
+	}
 	return nil
 }
 
-// TODO: Implement ToCategory method
+// ToCategory converts the request into a new, active category
 func (req *CreateCategoryRequest) ToCategory() *Category {
-	// TODO: Convert request to GORM model
-	// - Map fields from request to model
-	// - Set default values
-	// Example:
-	// return &Category{
-	//     Name:        req.Name,
-	//     Description: req.Description,
-	//     Color:       req.Color,
-	//     Active:      true,
-	// }
-	return nil
+	return &Category{
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Description: req.Description,
+		Color:       req.Color,
+		Active:      true,
+	}
 }
 
-// TODO: Implement GORM scopes (reusable query logic)
+// ActiveCategories is a GORM scope for categories that are switched on
 func ActiveCategories(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for active categories
-	// return db.Where("active = ?", true)
-	return db
+	return db.Where("active = ?", true)
 }
 
+// CategoriesWithPosts is a GORM scope for categories with at least one
+// post that has not been deleted
 func CategoriesWithPosts(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for categories with posts
-	// return db.Joins("Posts").Where("posts.id IS NOT NULL")
-	return db
+	return db.Where(`EXISTS (SELECT 1 FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id AND p.deleted_at IS NULL
+		WHERE pc.category_id = categories.id)`)
 }
 
-// TODO: Implement model validation methods
+// IsActive reports whether the category is switched on
 func (c *Category) IsActive() bool {
-	// TODO: Check if category is active
 	return c.Active
 }
 
+// PostCount counts the category's posts, leaving out deleted ones
 func (c *Category) PostCount(db *gorm.DB) (int64, error) {
-	// TODO: Get post count for this category using GORM association
-	// var count int64
-	// err := db.Model(c).Association("Posts").Count(&count)
-	// return count, err
-	return 0, nil
+	var count int64
+	err := db.Table("post_categories").
+		Joins("JOIN posts ON posts.id = post_categories.post_id AND posts.deleted_at IS NULL").
+		Where("post_categories.category_id = ?", c.ID).
+		Count(&count).Error
+	return count, err
 }
@@ -2,18 +2,27 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Post represents a blog post in the system
 type Post struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	Title     string    `json:"title" db:"title"`
-	Content   string    `json:"content" db:"content"`
-	Published bool      `json:"published" db:"published"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Title     string     `json:"title" db:"title"`
+	Content   string     `json:"content" db:"content"`
+	Published bool       `json:"published" db:"published"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // Set while the post is soft deleted
+
+	// Categories are only filled in by the PostRepository methods that
+	// load them, such as LoadCategories
+	Categories []Category `json:"categories,omitempty" db:"-" gorm:"many2many:post_categories;"`
 }
 
 // CreatePostRequest represents the payload for creating a post
@@ -31,43 +40,101 @@ type UpdatePostRequest struct {
 	Published *bool   `json:"published,omitempty"`
 }
 
-// TODO: Implement Validate method for Post
+// Validation errors
+var (
+	ErrTitleRequired   = errors.New("title is required")
+	ErrTitleTooShort   = errors.New("title must be at least 5 characters")
+	ErrContentRequired = errors.New("content is required for published posts")
+	ErrInvalidUserID   = errors.New("user_id must be greater than 0")
+)
+
+// PostColumns lists the columns ScanRow and ScanPosts expect, in order
+const PostColumns = "id, user_id, title, content, published, created_at, updated_at, deleted_at"
+
+// Validate checks the post's title, content and author
 func (p *Post) Validate() error {
-	// TODO: Add validation logic
-	// - Title should not be empty and should be at least 5 characters
-	// - Content should not be empty if published is true
-	// - UserID should be greater than 0
-	// Return appropriate errors if validation fails
-	return nil
+	return validatePost(p.UserID, p.Title, p.Content, p.Published)
 }
 
-// TODO: Implement Validate method for CreatePostRequest
+// Validate checks the requested title, content and author
 func (req *CreatePostRequest) Validate() error {
-	// TODO: Add validation logic
-	// - Title should not be empty and should be at least 5 characters
-	// - UserID should be greater than 0
-	// - Content should not be empty if published is true
-	// Return appropriate errors if validation fails
+	return validatePost(req.UserID, req.Title, req.Content, req.Published)
+}
+
+func validatePost(userID int, title, content string, published bool) error {
+	if err := validateTitle(title); err != nil {
+		return err
+	}
+	if published && strings.TrimSpace(content) == "" {
+		return ErrContentRequired
+	}
+	if userID <= 0 {
+		return ErrInvalidUserID
+	}
 	return nil
 }
 
-// TODO: Implement ToPost method for CreatePostRequest
-func (req *CreatePostRequest) ToPost() *Post {
-	// TODO: Convert CreatePostRequest to Post
-	// Set timestamps to current time
+func validateTitle(title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return ErrTitleRequired
+	}
+	if utf8.RuneCountInString(title) < 5 {
+		return ErrTitleTooShort
+	}
 	return nil
 }
 
-// TODO: Implement ScanRow method for Post
+// Validate checks the fields being changed. Whether published content is
+// empty depends on the stored post, so PostRepository.Update validates the
+// post with the changes applied.
+func (req *UpdatePostRequest) Validate() error {
+	if req.Title != nil {
+		return validateTitle(*req.Title)
+	}
+	return nil
+}
+
+// ToPost converts the request into a new post stamped with the current time
+// in UTC
+func (req *CreatePostRequest) ToPost() *Post {
+	now := time.Now().UTC()
+	return &Post{
+		UserID:    req.UserID,
+		Title:     req.Title,
+		Content:   req.Content,
+		Published: req.Published,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ScanRow scans a row selected with PostColumns into the post
 func (p *Post) ScanRow(row *sql.Row) error {
-	// TODO: Scan database row into Post struct
-	// Handle the case where row might be nil
+	if row == nil {
+		return fmt.Errorf("row cannot be nil")
+	}
+	var content sql.NullString
+	if err := row.Scan(&p.ID, &p.UserID, &p.Title, &content, &p.Published, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt); err != nil {
+		return err
+	}
+	p.Content = content.String
 	return nil
 }
 
-// TODO: Implement ScanRows method for Post slice
+// ScanPosts scans rows selected with PostColumns and closes them
 func ScanPosts(rows *sql.Rows) ([]Post, error) {
-	// TODO: Scan multiple database rows into Post slice
-	// Make sure to close rows and handle errors properly
-	return nil, nil
+	defer rows.Close()
+
+	posts := make([]Post, 0)
+	for rows.Next() {
+		var p Post
+		var content sql.NullString
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &content, &p.Published, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt); err != nil {
+			return nil, err
+		}
+		p.Content = content.String
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
 }
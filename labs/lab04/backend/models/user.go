@@ -2,16 +2,22 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // User represents a user in the system
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Email     string    `json:"email" db:"email"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Email     string     `json:"email" db:"email"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // Set while the user is soft deleted
 }
 
 // CreateUserRequest represents the payload for creating a user
@@ -26,41 +32,100 @@ type UpdateUserRequest struct {
 	Email *string `json:"email,omitempty"`
 }
 
-// TODO: Implement Validate method for User
+// Validation errors
+var (
+	ErrNameRequired  = errors.New("name is required")
+	ErrNameTooShort  = errors.New("name must be at least 2 characters")
+	ErrEmailRequired = errors.New("email is required")
+	ErrInvalidEmail  = errors.New("email is not a valid address")
+)
+
+// emailPattern accepts local@domain.tld without trying to cover all of RFC 5322
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// UserColumns lists the columns ScanRow and ScanUsers expect, in order
+const UserColumns = "id, name, email, created_at, updated_at, deleted_at"
+
+// Validate checks the user's name and email
 func (u *User) Validate() error {
-	// TODO: Add validation logic
-	// - Name should not be empty and should be at least 2 characters
-	// - Email should be valid format
-	// Return appropriate errors if validation fails
-	return nil
+	return validateUser(u.Name, u.Email)
 }
 
-// TODO: Implement Validate method for CreateUserRequest
+// Validate checks the requested name and email
 func (req *CreateUserRequest) Validate() error {
-	// TODO: Add validation logic
-	// - Name should not be empty and should be at least 2 characters
-	// - Email should be valid format and not empty
-	// Return appropriate errors if validation fails
+	return validateUser(req.Name, req.Email)
+}
+
+func validateUser(name, email string) error {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return ErrNameRequired
+	case utf8.RuneCountInString(name) < 2:
+		return ErrNameTooShort
+	}
+	return validateEmail(email)
+}
+
+func validateEmail(email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return ErrEmailRequired
+	}
+	if !emailPattern.MatchString(email) {
+		return ErrInvalidEmail
+	}
 	return nil
 }
 
-// TODO: Implement ToUser method for CreateUserRequest
-func (req *CreateUserRequest) ToUser() *User {
-	// TODO: Convert CreateUserRequest to User
-	// Set timestamps to current time
+// Validate checks the fields being changed
+func (req *UpdateUserRequest) Validate() error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return ErrNameRequired
+		}
+		if utf8.RuneCountInString(name) < 2 {
+			return ErrNameTooShort
+		}
+	}
+	if req.Email != nil {
+		return validateEmail(*req.Email)
+	}
 	return nil
 }
 
-// TODO: Implement ScanRow method for User
+// ToUser converts the request into a new user stamped with the current time
+// in UTC
+func (req *CreateUserRequest) ToUser() *User {
+	now := time.Now().UTC()
+	return &User{
+		Name:      req.Name,
+		Email:     req.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ScanRow scans a row selected with UserColumns into the user
 func (u *User) ScanRow(row *sql.Row) error {
-	// TODO: Scan database row into User struct
-	// Handle the case where row might be nil
-	return nil
+	if row == nil {
+		return fmt.Errorf("row cannot be nil")
+	}
+	return row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
 }
 
-// TODO: Implement ScanRows method for User slice
+// ScanUsers scans rows selected with UserColumns and closes them
 func ScanUsers(rows *sql.Rows) ([]User, error) {
-	// TODO: Scan multiple database rows into User slice
-	// Make sure to close rows and handle errors properly
-	return nil, nil
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
 }
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+)
+
+// AuditRepository reads the audit log written by the other repositories
+type AuditRepository struct {
+	db      DBTX
+	dialect database.Dialect
+	ctx     context.Context // Governs every query; see WithContext
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db, dialect: database.DialectOf(db), ctx: context.Background()}
+}
+
+// WithContext returns a copy of the repository whose queries stop when ctx
+// is cancelled or times out
+func (r *AuditRepository) WithContext(ctx context.Context) *AuditRepository {
+	repo := *r
+	repo.ctx = ctx
+	return &repo
+}
+
+// History returns the changes recorded for one row of table, such as
+// "posts", oldest first. It is empty for rows that were never changed.
+func (r *AuditRepository) History(table string, rowID int64) ([]models.AuditEntry, error) {
+	rows, err := r.db.QueryContext(r.ctx, r.dialect.Rebind(
+		`SELECT `+models.AuditEntryColumns+` FROM audit_log WHERE table_name = ? AND row_id = ? ORDER BY id`),
+		table, rowID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return models.ScanAuditEntries(rows)
+}
+
+// ByActor returns the most recent changes made by actor, newest first
+func (r *AuditRepository) ByActor(actor string, limit int) ([]models.AuditEntry, error) {
+	rows, err := r.db.QueryContext(r.ctx, r.dialect.Rebind(
+		`SELECT `+models.AuditEntryColumns+` FROM audit_log WHERE actor = ? ORDER BY id DESC LIMIT ?`),
+		actor, limitOrDefault(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return models.ScanAuditEntries(rows)
+}
+
+// recordChange writes an audit entry for a change to one row through db,
+// normally the transaction that made the change
+func recordChange(ctx context.Context, db DBTX, dialect database.Dialect, actor string, op models.AuditOperation, table string, rowID int, before, after interface{}) error {
+	entry, err := models.NewAuditEntry(actor, op, table, int64(rowID), before, after)
+	if err != nil {
+		return err
+	}
+	query, args := entry.InsertStatement()
+	_, err = db.ExecContext(ctx, dialect.Rebind(query), args...)
+	return err
+}
+
+// deletedAt is the part of a row that soft deletes and restores change
+type deletedAt struct {
+	DeletedAt interface{} `json:"deleted_at"`
+}
+
+// forUpdate locks the rows a Postgres SELECT reads until the transaction
+// ends, so the values audited as "before" cannot change underneath it.
+// SQLite has one writer at a time and no such clause.
+func forUpdate(dialect database.Dialect) string {
+	if dialect == database.Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
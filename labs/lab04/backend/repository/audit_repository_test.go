@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"lab04-backend/models"
+)
+
+// auditOperations lists the operations of entries in order
+func auditOperations(entries []models.AuditEntry) string {
+	ops := make([]string, len(entries))
+	for i, entry := range entries {
+		ops[i] = string(entry.Operation)
+	}
+	return strings.Join(ops, ",")
+}
+
+// auditChange returns one field's change from an entry
+func auditChange(t *testing.T, entry models.AuditEntry, field string) (before, after string) {
+	t.Helper()
+	fields, err := entry.Fields()
+	if err != nil {
+		t.Fatalf("Fields() failed: %v", err)
+	}
+	change, ok := fields[field]
+	if !ok {
+		t.Fatalf("Expected %s in the changes, got %s", field, entry.Changes)
+	}
+	return string(change.Before), string(change.After)
+}
+
+func TestAuditUserAndPostHistory(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db).WithActor("admin")
+	posts := NewPostRepository(db).WithActor("alice")
+	audit := NewAuditRepository(db)
+
+	alice, err := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	post, err := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "First draft"})
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	title := "Final title"
+	if _, err := posts.Update(post.ID, &models.UpdatePostRequest{Title: &title}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	name := "Alicia"
+	if _, err := users.Update(alice.ID, &models.UpdateUserRequest{Name: &name}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := users.Delete(alice.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := users.Restore(alice.ID); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+
+	t.Run("Post history", func(t *testing.T) {
+		history, err := audit.History("posts", int64(post.ID))
+		if err != nil {
+			t.Fatalf("History() failed: %v", err)
+		}
+		// The user's delete and restore cascade to the post
+		if got := auditOperations(history); got != "create,update,delete,restore" {
+			t.Fatalf("Unexpected operations %s", got)
+		}
+		if history[1].Actor != "alice" || history[2].Actor != "admin" {
+			t.Errorf("Expected the post's author and then the admin as actors, got %q and %q", history[1].Actor, history[2].Actor)
+		}
+
+		// Who changed the title and what was it before?
+		before, after := auditChange(t, history[1], "title")
+		if before != `"First draft"` || after != `"Final title"` {
+			t.Errorf("Expected the title change, got %s -> %s", before, after)
+		}
+		if fields, _ := history[1].Fields(); len(fields) != 2 {
+			t.Errorf("Expected only title and updated_at to change, got %s", history[1].Changes)
+		}
+		if before, after := auditChange(t, history[3], "deleted_at"); before == "null" || after != "null" {
+			t.Errorf("Expected the restore to clear deleted_at, got %s -> %s", before, after)
+		}
+	})
+
+	t.Run("User history", func(t *testing.T) {
+		history, err := audit.History("users", int64(alice.ID))
+		if err != nil {
+			t.Fatalf("History() failed: %v", err)
+		}
+		if got := auditOperations(history); got != "create,update,delete,restore" {
+			t.Fatalf("Unexpected operations %s", got)
+		}
+		for _, entry := range history {
+			if entry.Actor != "admin" || entry.Table != "users" || entry.RowID != int64(alice.ID) || entry.CreatedAt.IsZero() {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+		}
+		if _, after := auditChange(t, history[0], "email"); after != `"alice@example.com"` {
+			t.Errorf("Expected the created user's email, got %s", after)
+		}
+	})
+
+	t.Run("Purge keeps the last values", func(t *testing.T) {
+		if err := NewUserRepository(db).Purge(alice.ID); err != nil {
+			t.Fatalf("Purge() failed: %v", err)
+		}
+		history, _ := audit.History("posts", int64(post.ID))
+		last := history[len(history)-1]
+		if last.Operation != models.AuditPurge || last.Actor != models.SystemActor {
+			t.Fatalf("Expected a purge by %s, got %+v", models.SystemActor, last)
+		}
+		if before, after := auditChange(t, last, "title"); before != `"Final title"` || after != "" {
+			t.Errorf("Expected the purged title, got %s -> %s", before, after)
+		}
+	})
+
+	t.Run("By actor", func(t *testing.T) {
+		entries, err := audit.ByActor("alice", 10)
+		if err != nil || auditOperations(entries) != "update,create" {
+			t.Errorf("Expected alice's update and create, newest first, got %s, %v", auditOperations(entries), err)
+		}
+	})
+}
+
+func TestAuditUnitOfWork(t *testing.T) {
+	db := openTestDB(t)
+	uow := NewUnitOfWork(db)
+	audit := NewAuditRepository(db)
+	ctx := models.WithActor(context.Background(), "importer")
+
+	var userID int
+	err := uow.Do(ctx, func(tx *Tx) error {
+		user, err := tx.Users.Create(&models.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+		if err != nil {
+			return err
+		}
+		userID = user.ID
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+	history, _ := audit.History("users", int64(userID))
+	if len(history) != 1 || history[0].Actor != "importer" {
+		t.Errorf("Expected one entry by the context's actor, got %+v", history)
+	}
+
+	// Entries are part of the transaction and roll back with it
+	failure := errors.New("abort")
+	err = uow.Do(ctx, func(tx *Tx) error {
+		if _, err := tx.Posts.Create(&models.CreatePostRequest{UserID: userID, Title: "Never saved"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the function's error, got %v", err)
+	}
+	if entries, _ := audit.ByActor("importer", 10); len(entries) != 1 {
+		t.Errorf("Expected the rolled back post to leave no entry, got %+v", entries)
+	}
+}
+
+func TestAuditCategoryHooks(t *testing.T) {
+	categoryRepo, db := setupCategoryRepo(t)
+	editor := categoryRepo.WithActor("editor")
+	audit := NewAuditRepository(db)
+
+	science := &models.Category{Name: "Science"}
+	physics := &models.Category{Name: "Physics"}
+	for _, category := range []*models.Category{science, physics} {
+		if err := editor.Create(category); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	physics.Description = "Matter and energy"
+	if err := editor.Update(physics); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := categoryRepo.Move(physics.ID, &science.ID); err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if err := categoryRepo.Move(physics.ID, nil); err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if err := editor.Delete(physics.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	history, err := audit.History("categories", int64(physics.ID))
+	if err != nil {
+		t.Fatalf("History() failed: %v", err)
+	}
+	if got := auditOperations(history); got != "create,update,update,update,delete" {
+		t.Fatalf("Unexpected operations %s", got)
+	}
+
+	actors := make([]string, len(history))
+	for i, entry := range history {
+		actors[i] = entry.Actor
+	}
+	if got := strings.Join(actors, ","); got != "editor,editor,system,system,editor" {
+		t.Errorf("Unexpected actors %s", got)
+	}
+
+	if _, after := auditChange(t, history[0], "name"); after != `"Physics"` {
+		t.Errorf("Expected the created name, got %s", after)
+	}
+	if before, after := auditChange(t, history[1], "description"); before != `""` || after != `"Matter and energy"` {
+		t.Errorf("Expected the description change, got %s -> %s", before, after)
+	}
+	before, after := auditChange(t, history[2], "parent_id")
+	if before != "" || after != jsonNumber(science.ID) {
+		t.Errorf("Expected parent_id to be set to %d, got %s -> %s", science.ID, before, after)
+	}
+	if before, after := auditChange(t, history[3], "parent_id"); before != jsonNumber(science.ID) || after != "" {
+		t.Errorf("Expected parent_id to be cleared, got %s -> %s", before, after)
+	}
+	var stored models.Category
+	if err := categoryRepo.db.Unscoped().First(&stored, physics.ID).Error; err != nil {
+		t.Fatalf("Failed to read the deleted category: %v", err)
+	}
+	deletedAt, _ := json.Marshal(stored.DeletedAt)
+	if before, after := auditChange(t, history[4], "deleted_at"); before != "null" || after != string(deletedAt) {
+		t.Errorf("Expected deleted_at to be set to the stored %s, got %s -> %s", deletedAt, before, after)
+	}
+
+	// Deleting it again matches no row and records nothing
+	editor.Delete(physics.ID)
+	if again, _ := audit.History("categories", int64(physics.ID)); len(again) != len(history) {
+		t.Errorf("Expected no entry for a delete that matched nothing, got %s", auditOperations(again))
+	}
+
+	// Updating a deleted category matches no row and records nothing
+	editor.Update(physics)
+	if again, _ := audit.History("categories", int64(physics.ID)); len(again) != len(history) {
+		t.Errorf("Expected no entry for an update that matched nothing, got %s", auditOperations(again))
+	}
+}
+
+func jsonNumber(id uint) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
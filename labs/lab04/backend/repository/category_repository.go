@@ -1,167 +1,360 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 
+	"lab04-backend/database"
 	"lab04-backend/models"
 
 	"gorm.io/gorm"
 )
 
+// Category hierarchy errors
+var (
+	ErrParentNotFound      = errors.New("parent category not found")
+	ErrCategoryCycle       = errors.New("a category cannot be moved under itself or one of its descendants")
+	ErrCategoryHasChildren = errors.New("category has subcategories; move or delete them first")
+	ErrHierarchyMismatch   = errors.New("category parents and category_closure disagree")
+)
+
 // CategoryRepository handles database operations for categories using GORM
 // This repository demonstrates GORM ORM approach for database operations
+//
+// Categories form a tree. Besides each category's parent_id, the
+// category_closure table holds every ancestor-descendant pair, so subtrees
+// and ancestors are read with one join instead of a recursive query.
+// Every method that changes the tree keeps the two in step.
 type CategoryRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	dialect database.Dialect
 }
 
 // NewCategoryRepository creates a new CategoryRepository with GORM
 func NewCategoryRepository(gormDB *gorm.DB) *CategoryRepository {
-	return &CategoryRepository{db: gormDB}
+	dialect := database.SQLite
+	if gormDB.Dialector.Name() == "postgres" {
+		dialect = database.Postgres
+	}
+	return &CategoryRepository{db: gormDB, dialect: dialect}
+}
+
+// WithActor returns a copy of the repository that records actor in the
+// audit log as the author of its changes
+func (r *CategoryRepository) WithActor(actor string) *CategoryRepository {
+	return r.withDB(r.db.WithContext(models.WithActor(r.db.Statement.Context, actor)))
+}
+
+// withDB returns a copy of the repository running on db, such as a
+// transaction
+func (r *CategoryRepository) withDB(db *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: db, dialect: r.dialect}
 }
 
-// TODO: Implement Create method using GORM
+// Create inserts a category, under its ParentID if set. It returns
+// ErrParentNotFound if that parent does not exist.
 func (r *CategoryRepository) Create(category *models.Category) error {
-	// TODO: Create a new category using GORM
-	// - Use GORM's Create method: r.db.Create(category)
-	// - GORM automatically handles ID generation and timestamps
-	// - No need for manual SQL or RETURNING clauses
-	// Example: result := r.db.Create(category)
-	// return result.Error
-	//
-	// Notice how much simpler this is compared to manual SQL!
-	return fmt.Errorf("TODO: implement Create method with GORM")
-}
-
-// TODO: Implement GetByID method using GORM
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return r.withDB(tx).create(category)
+	})
+}
+
+func (r *CategoryRepository) create(category *models.Category) error {
+	if category.ParentID != nil {
+		if err := r.db.Select("id").First(&models.Category{}, *category.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrParentNotFound
+			}
+			return err
+		}
+	}
+	if err := r.db.Create(category).Error; err != nil {
+		return err
+	}
+
+	// The new category descends from itself and from every ancestor of its
+	// parent, one level further down
+	return r.db.Exec(`INSERT INTO category_closure (ancestor_id, descendant_id, depth)
+		SELECT id, id, 0 FROM categories WHERE id = ?
+		UNION ALL
+		SELECT cc.ancestor_id, c.id, cc.depth + 1
+		FROM category_closure cc JOIN categories c ON c.id = ?
+		WHERE cc.descendant_id = ?`,
+		category.ID, category.ID, category.ParentID,
+	).Error
+}
+
+// GetByID returns a category, or gorm.ErrRecordNotFound
 func (r *CategoryRepository) GetByID(id uint) (*models.Category, error) {
-	// TODO: Get category by ID using GORM
-	// - Use GORM's First method: r.db.First(&category, id)
-	// - GORM automatically generates the WHERE clause
-	// - Returns gorm.ErrRecordNotFound if not found
-	// Example:
-	// var category models.Category
-	// result := r.db.First(&category, id)
-	// return &category, result.Error
-	//
-	// Much cleaner than manual row scanning!
-	return nil, fmt.Errorf("TODO: implement GetByID method with GORM")
-}
-
-// TODO: Implement GetAll method using GORM
+	var category models.Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAll returns every category ordered by name
 func (r *CategoryRepository) GetAll() ([]models.Category, error) {
-	// TODO: Get all categories using GORM
-	// - Use GORM's Find method: r.db.Find(&categories)
-	// - GORM automatically handles the slice allocation
-	// - Can add Order() for sorting: r.db.Order("name").Find(&categories)
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Order("name").Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement GetAll method with GORM")
-}
-
-// TODO: Implement Update method using GORM
+	var categories []models.Category
+	err := r.db.Order("name").Find(&categories).Error
+	return categories, err
+}
+
+// Update saves a category's name, description, color and active flag. Its
+// parent is left alone; use Move to change it.
 func (r *CategoryRepository) Update(category *models.Category) error {
-	// TODO: Update category using GORM
-	// - Use GORM's Save method: r.db.Save(category)
-	// - GORM automatically updates only changed fields
-	// - Handles updated_at timestamp automatically
-	// - Alternative: r.db.Model(category).Updates(updates)
-	//
-	// Example:
-	// result := r.db.Save(category)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Update method with GORM")
-}
-
-// TODO: Implement Delete method using GORM
+	if err := category.Validate(); err != nil {
+		return err
+	}
+	result := r.db.Model(category).
+		Select("Name", "Description", "Color", "Active", "UpdatedAt").
+		Updates(category)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete soft-deletes a category. A category with subcategories cannot be
+// deleted, which would leave them under a hidden parent, and gets
+// ErrCategoryHasChildren.
 func (r *CategoryRepository) Delete(id uint) error {
-	// TODO: Delete category using GORM
-	// - Use GORM's Delete method: r.db.Delete(&models.Category{}, id)
-	// - GORM can do soft delete if model has DeletedAt field
-	// - For hard delete: r.db.Unscoped().Delete(&models.Category{}, id)
-	//
-	// Example:
-	// result := r.db.Delete(&models.Category{}, id)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Delete method with GORM")
-}
-
-// TODO: Implement FindByName method using GORM
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrCategoryHasChildren
+		}
+
+		// Loaded first so the delete hooks know which category it was
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+}
+
+// FindByName returns the category with exactly this name, or
+// gorm.ErrRecordNotFound
 func (r *CategoryRepository) FindByName(name string) (*models.Category, error) {
-	// TODO: Find category by name using GORM
-	// - Use GORM's Where method: r.db.Where("name = ?", name).First(&category)
-	// - GORM handles SQL injection protection automatically
-	// - Can use struct for conditions: r.db.Where(&models.Category{Name: name}).First(&category)
-	//
-	// Example:
-	// var category models.Category
-	// result := r.db.Where("name = ?", name).First(&category)
-	// return &category, result.Error
-	return nil, fmt.Errorf("TODO: implement FindByName method with GORM")
-}
-
-// TODO: Implement SearchCategories method using GORM
+	var category models.Category
+	if err := r.db.Where("name = ?", name).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// SearchCategories returns up to limit categories whose name contains query,
+// ignoring case, ordered by name
 func (r *CategoryRepository) SearchCategories(query string, limit int) ([]models.Category, error) {
-	// TODO: Search categories using GORM
-	// - Use GORM's Where with LIKE: r.db.Where("name LIKE ?", "%"+query+"%")
-	// - Add Limit: .Limit(limit)
-	// - Add Order: .Order("name")
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Where("name LIKE ?", "%"+query+"%").
-	//               Order("name").
-	//               Limit(limit).
-	//               Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement SearchCategories method with GORM")
-}
-
-// TODO: Implement GetCategoriesWithPosts method using GORM associations
+	var categories []models.Category
+	err := r.db.
+		Where("name "+r.dialect.CaseInsensitiveLike()+` ? ESCAPE '\'`, "%"+likeEscaper.Replace(query)+"%").
+		Order("name").
+		Limit(limitOrDefault(limit)).
+		Find(&categories).Error
+	return categories, err
+}
+
+// GetCategoriesWithPosts returns every category with its posts loaded,
+// leaving out deleted posts
 func (r *CategoryRepository) GetCategoriesWithPosts() ([]models.Category, error) {
-	// TODO: Get categories with associated posts using GORM
-	// - Use GORM's Preload to load associations: r.db.Preload("Posts").Find(&categories)
-	// - GORM automatically handles the JOINs and relationships
-	// - Much simpler than manual JOIN queries!
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Preload("Posts").Find(&categories)
-	// return categories, result.Error
-	//
-	// This assumes Category model has Posts relationship defined
-	return nil, fmt.Errorf("TODO: implement GetCategoriesWithPosts method with GORM Preload")
-}
-
-// TODO: Implement Count method using GORM
+	var categories []models.Category
+	err := r.db.
+		Preload("Posts", "deleted_at IS NULL", func(db *gorm.DB) *gorm.DB { return db.Order("posts.id") }).
+		Order("name").
+		Find(&categories).Error
+	return categories, err
+}
+
+// Count returns the number of categories, leaving out deleted ones
 func (r *CategoryRepository) Count() (int64, error) {
-	// TODO: Count categories using GORM
-	// - Use GORM's Count method: r.db.Model(&models.Category{}).Count(&count)
-	// - GORM returns int64 for count operations
-	//
-	// Example:
-	// var count int64
-	// result := r.db.Model(&models.Category{}).Count(&count)
-	// return count, result.Error
-	return 0, fmt.Errorf("TODO: implement Count method with GORM")
-}
-
-// TODO: Implement Transaction example using GORM
+	var count int64
+	err := r.db.Model(&models.Category{}).Count(&count).Error
+	return count, err
+}
+
+// CreateWithTransaction creates all the categories or none of them. A
+// category may have an earlier one in the slice as its parent.
 func (r *CategoryRepository) CreateWithTransaction(categories []models.Category) error {
-	// TODO: Create multiple categories in a transaction using GORM
-	// - Use GORM's Transaction method: r.db.Transaction(func(tx *gorm.DB) error {...})
-	// - GORM automatically handles rollback on error
-	// - Much simpler than manual transaction management!
-	//
-	// Example:
-	// return r.db.Transaction(func(tx *gorm.DB) error {
-	//     for _, category := range categories {
-	//         if err := tx.Create(&category).Error; err != nil {
-	//             return err // GORM will rollback automatically
-	//         }
-	//     }
-	//     return nil // GORM will commit automatically
-	// })
-	return fmt.Errorf("TODO: implement CreateWithTransaction method with GORM")
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		repo := r.withDB(tx)
+		for i := range categories {
+			if err := repo.create(&categories[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Move makes parentID the parent of a category, or makes it top-level if
+// parentID is nil. Its whole subtree moves with it. Moving a category
+// under itself or a descendant would make a cycle and gets
+// ErrCategoryCycle.
+func (r *CategoryRepository) Move(id uint, parentID *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Concurrent moves could each pass the cycle check and together
+		// make a loop, so Postgres runs them one at a time. SQLite allows
+		// one writer anyway.
+		if r.dialect == database.Postgres {
+			if err := tx.Exec("LOCK TABLE category_closure IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+		}
+
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+		if parentID != nil {
+			if err := tx.Select("id").First(&models.Category{}, *parentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrParentNotFound
+				}
+				return err
+			}
+			var inSubtree int64
+			err := tx.Model(&models.CategoryClosure{}).
+				Where("ancestor_id = ? AND descendant_id = ?", id, *parentID).
+				Count(&inSubtree).Error
+			if err != nil {
+				return err
+			}
+			if inSubtree > 0 {
+				return ErrCategoryCycle
+			}
+		}
+
+		// Detach the subtree from its old ancestors, keeping the pairs
+		// inside it
+		err := tx.Exec(`DELETE FROM category_closure
+			WHERE descendant_id IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = ?)
+			AND ancestor_id NOT IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = ?)`,
+			id, id,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		// Pair every ancestor of the new parent with every member of the
+		// subtree
+		if parentID != nil {
+			err := tx.Exec(`INSERT INTO category_closure (ancestor_id, descendant_id, depth)
+				SELECT a.ancestor_id, d.descendant_id, a.depth + d.depth + 1
+				FROM category_closure a, category_closure d
+				WHERE a.descendant_id = ? AND d.ancestor_id = ?`,
+				*parentID, id,
+			).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&category).Update("parent_id", parentID).Error
+	})
+}
+
+// GetSubtree returns a category and all its descendants in depth-first
+// order, siblings sorted by name, so the result reads like an indented
+// outline. It returns gorm.ErrRecordNotFound if the category does not
+// exist, and ErrHierarchyMismatch if category_closure places a category in
+// the subtree that its parent_id does not.
+func (r *CategoryRepository) GetSubtree(id uint) ([]models.CategoryNode, error) {
+	var nodes []models.CategoryNode
+	err := r.db.Model(&models.Category{}).
+		Select("categories.*, category_closure.depth").
+		Joins("JOIN category_closure ON category_closure.descendant_id = categories.id").
+		Where("category_closure.ancestor_id = ?", id).
+		Order("category_closure.depth, categories.name").
+		Find(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// Rows come a level at a time, so each node's children are collected
+	// in name order before the walk
+	children := make(map[uint][]int)
+	for i, node := range nodes[1:] {
+		if node.ParentID == nil {
+			return nil, fmt.Errorf("%w: category %d is top-level but under category %d", ErrHierarchyMismatch, node.ID, id)
+		}
+		children[*node.ParentID] = append(children[*node.ParentID], i+1)
+	}
+	ordered := make([]models.CategoryNode, 0, len(nodes))
+	var walk func(i int)
+	walk = func(i int) {
+		ordered = append(ordered, nodes[i])
+		for _, child := range children[nodes[i].ID] {
+			walk(child)
+		}
+	}
+	walk(0)
+	// Nodes whose parent is outside the subtree were never reached
+	if len(ordered) != len(nodes) {
+		return nil, fmt.Errorf("%w: subtree of category %d", ErrHierarchyMismatch, id)
+	}
+	return ordered, nil
+}
+
+// GetAncestors returns a category's ancestors from the top-level category
+// down to its parent. A top-level category has none.
+func (r *CategoryRepository) GetAncestors(id uint) ([]models.Category, error) {
+	return r.ancestors(id, 1)
+}
+
+// GetBreadcrumbs returns the path from the top-level category down to and
+// including the category itself
+func (r *CategoryRepository) GetBreadcrumbs(id uint) ([]models.Category, error) {
+	return r.ancestors(id, 0)
+}
+
+func (r *CategoryRepository) ancestors(id uint, minDepth int) ([]models.Category, error) {
+	if err := r.db.Select("id").First(&models.Category{}, id).Error; err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	err := r.db.
+		Joins("JOIN category_closure ON category_closure.ancestor_id = categories.id").
+		Where("category_closure.descendant_id = ? AND category_closure.depth >= ?", id, minDepth).
+		Order("category_closure.depth DESC").
+		Find(&categories).Error
+	return categories, err
+}
+
+// GetPostCounts counts the posts in each category, both those assigned to
+// it directly and those anywhere in its subtree, ordered by category name.
+// A post filed under several categories of one subtree counts once toward
+// it. With a root, only that category and its descendants are counted.
+// Deleted posts and categories are left out.
+func (r *CategoryRepository) GetPostCounts(root *uint) ([]models.CategoryPostCount, error) {
+	query := r.db.Table("categories c").
+		Select(`c.id AS category_id, c.name,
+			COUNT(DISTINCT CASE WHEN cc.depth = 0 THEN p.id END) AS post_count,
+			COUNT(DISTINCT p.id) AS subtree_post_count`).
+		Joins("JOIN category_closure cc ON cc.ancestor_id = c.id").
+		Joins("JOIN categories d ON d.id = cc.descendant_id AND d.deleted_at IS NULL").
+		Joins("LEFT JOIN post_categories pc ON pc.category_id = d.id").
+		Joins("LEFT JOIN posts p ON p.id = pc.post_id AND p.deleted_at IS NULL").
+		Where("c.deleted_at IS NULL").
+		Group("c.id, c.name").
+		Order("c.name")
+	if root != nil {
+		if err := r.db.Select("id").First(&models.Category{}, *root).Error; err != nil {
+			return nil, err
+		}
+		query = query.Where("c.id IN (SELECT descendant_id FROM category_closure WHERE ancestor_id = ?)", *root)
+	}
+
+	var counts []models.CategoryPostCount
+	err := query.Scan(&counts).Error
+	return counts, err
 }
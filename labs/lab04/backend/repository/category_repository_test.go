@@ -1,248 +1,509 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"gorm.io/gorm"
 )
 
+// setupCategoryRepo returns a CategoryRepository over a migrated test
+// database, and the database for seeding users and posts
+func setupCategoryRepo(t *testing.T) (*CategoryRepository, *sql.DB) {
+	t.Helper()
+	db := openTestDB(t)
+	gormDB, err := database.OpenGORM(db)
+	if err != nil {
+		t.Fatalf("OpenGORM() failed: %v", err)
+	}
+	return NewCategoryRepository(gormDB), db
+}
+
+// assignCategory files a post under a category
+func assignCategory(t *testing.T, db *sql.DB, postID int, categoryID uint) {
+	t.Helper()
+	_, err := db.Exec(database.DialectOf(db).Rebind(
+		`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`), postID, categoryID)
+	if err != nil {
+		t.Fatalf("Failed to assign post %d to category %d: %v", postID, categoryID, err)
+	}
+}
+
+// categoryNames lists the names of categories in order
+func categoryNames(categories []models.Category) string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Name
+	}
+	return strings.Join(names, ",")
+}
+
 // TestCategoryRepository tests the GORM ORM approach
 func TestCategoryRepository(t *testing.T) {
-	// TODO: Setup GORM database for testing
-	// db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	// if err != nil {
-	//     t.Fatalf("Failed to connect to database: %v", err)
-	// }
-
-	// TODO: Auto-migrate models
-	// err = db.AutoMigrate(&models.Category{}, &models.Post{})
-	// if err != nil {
-	//     t.Fatalf("Failed to migrate database: %v", err)
-	// }
-
-	// Create repository instance
-	// categoryRepo := NewCategoryRepository(db)
-
-	// TODO: Test Create method with GORM
+	categoryRepo, db := setupCategoryRepo(t)
+
+	var technology models.Category
 	t.Run("Create category with GORM", func(t *testing.T) {
-		// TODO: Test GORM Create functionality
-		// - Create a category using GORM
-		// - Verify ID is auto-generated
-		// - Verify timestamps are set automatically
-		// - Test validation via GORM hooks
-		//
-		// Example:
-		// category := &models.Category{
-		//     Name: "Technology",
-		//     Description: "Tech-related posts",
-		//     Color: "#007bff",
-		// }
-		// err := categoryRepo.Create(category)
-		// assert.NoError(t, err)
-		// assert.NotZero(t, category.ID)
-		// assert.NotZero(t, category.CreatedAt)
-
-		t.Skip("TODO: implement GORM Create test")
+		technology = models.Category{Name: "Technology", Description: "Tech-related posts", Color: "#007bff"}
+		if err := categoryRepo.Create(&technology); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if technology.ID == 0 || technology.CreatedAt.IsZero() || technology.UpdatedAt.IsZero() {
+			t.Errorf("Expected an ID and timestamps, got %+v", technology)
+		}
+
+		duplicate := models.Category{Name: "Technology"}
+		if err := categoryRepo.Create(&duplicate); err == nil {
+			t.Error("Expected an error creating a duplicate name")
+		}
 	})
 
-	// TODO: Test GetByID method with GORM
 	t.Run("GetByID with GORM", func(t *testing.T) {
-		// TODO: Test GORM First functionality
-		// - Create test category
-		// - Retrieve by ID using GORM
-		// - Test record not found handling
-		//
-		// Example:
-		// category, err := categoryRepo.GetByID(1)
-		// assert.NoError(t, err)
-		// assert.Equal(t, "Technology", category.Name)
-		//
-		// // Test not found
-		// _, err = categoryRepo.GetByID(999)
-		// assert.Error(t, err)
-		// assert.Equal(t, gorm.ErrRecordNotFound, err)
-
-		t.Skip("TODO: implement GORM GetByID test")
+		category, err := categoryRepo.GetByID(technology.ID)
+		if err != nil || category.Name != "Technology" || !category.Active {
+			t.Errorf("Expected the active Technology category, got %+v, %v", category, err)
+		}
+		if _, err := categoryRepo.GetByID(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
 	})
 
-	// TODO: Test GetAll method with GORM
 	t.Run("GetAll with GORM", func(t *testing.T) {
-		// TODO: Test GORM Find functionality
-		// - Create multiple categories
-		// - Retrieve all using GORM
-		// - Test ordering
-		//
-		// Example:
-		// categories, err := categoryRepo.GetAll()
-		// assert.NoError(t, err)
-		// assert.Len(t, categories, 3)
-		// // Verify ordering by name
-		// assert.Equal(t, "Category A", categories[0].Name)
-
-		t.Skip("TODO: implement GORM GetAll test")
+		for _, name := range []string{"Science", "Art"} {
+			if err := categoryRepo.Create(&models.Category{Name: name}); err != nil {
+				t.Fatalf("Create() failed: %v", err)
+			}
+		}
+		categories, err := categoryRepo.GetAll()
+		if err != nil {
+			t.Fatalf("GetAll() failed: %v", err)
+		}
+		if got := categoryNames(categories); got != "Art,Science,Technology" {
+			t.Errorf("Expected categories ordered by name, got %s", got)
+		}
 	})
 
-	// TODO: Test Update method with GORM
 	t.Run("Update with GORM", func(t *testing.T) {
-		// TODO: Test GORM Save/Updates functionality
-		// - Create category
-		// - Update using GORM
-		// - Verify updated_at is automatically set
-		// - Test partial updates
-		//
-		// Example:
-		// category.Name = "Updated Technology"
-		// err := categoryRepo.Update(category)
-		// assert.NoError(t, err)
-		// assert.Equal(t, "Updated Technology", category.Name)
-		// assert.True(t, category.UpdatedAt.After(originalUpdatedAt))
-
-		t.Skip("TODO: implement GORM Update test")
-	})
-
-	// TODO: Test Delete method with GORM
-	t.Run("Delete with GORM", func(t *testing.T) {
-		// TODO: Test GORM Delete functionality
-		// - Test soft delete (if DeletedAt field exists)
-		// - Test hard delete (with Unscoped)
-		// - Verify cascade behavior
-		//
-		// Example:
-		// err := categoryRepo.Delete(category.ID)
-		// assert.NoError(t, err)
-		//
-		// // Verify soft delete
-		// _, err = categoryRepo.GetByID(category.ID)
-		// assert.Error(t, err)
-		// assert.Equal(t, gorm.ErrRecordNotFound, err)
-
-		t.Skip("TODO: implement GORM Delete test")
+		category, _ := categoryRepo.GetByID(technology.ID)
+		original := category.UpdatedAt
+		category.Name = "Updated Technology"
+		category.Active = false
+		if err := categoryRepo.Update(category); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+
+		stored, _ := categoryRepo.GetByID(technology.ID)
+		if stored.Name != "Updated Technology" || stored.Active {
+			t.Errorf("Expected the new name and inactive, got %+v", stored)
+		}
+		if !stored.UpdatedAt.After(original) {
+			t.Errorf("Expected updated_at to move past %v, got %v", original, stored.UpdatedAt)
+		}
+
+		category.Color = "blue"
+		if err := categoryRepo.Update(category); !errors.Is(err, models.ErrInvalidColor) {
+			t.Errorf("Expected ErrInvalidColor, got %v", err)
+		}
+		if err := categoryRepo.Update(&models.Category{ID: 999, Name: "Missing"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
 	})
 
-	// TODO: Test FindByName method with GORM
 	t.Run("FindByName with GORM", func(t *testing.T) {
-		// TODO: Test GORM Where functionality
-		// - Test exact matches
-		// - Test case sensitivity
-		// - Test not found scenarios
-		//
-		// Example:
-		// category, err := categoryRepo.FindByName("Technology")
-		// assert.NoError(t, err)
-		// assert.Equal(t, "Technology", category.Name)
-
-		t.Skip("TODO: implement GORM FindByName test")
+		category, err := categoryRepo.FindByName("Science")
+		if err != nil || category.Name != "Science" {
+			t.Errorf("Expected Science, got %+v, %v", category, err)
+		}
+		if _, err := categoryRepo.FindByName("Nothing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
 	})
 
-	// TODO: Test SearchCategories method with GORM
 	t.Run("SearchCategories with GORM", func(t *testing.T) {
-		// TODO: Test GORM LIKE functionality
-		// - Test partial name matches
-		// - Test limit functionality
-		// - Test ordering
-		//
-		// Example:
-		// categories, err := categoryRepo.SearchCategories("Tech", 10)
-		// assert.NoError(t, err)
-		// assert.True(t, len(categories) <= 10)
-
-		t.Skip("TODO: implement GORM SearchCategories test")
+		categories, err := categoryRepo.SearchCategories("tech", 10)
+		if err != nil || categoryNames(categories) != "Updated Technology" {
+			t.Errorf("Expected a case-insensitive match, got %+v, %v", categories, err)
+		}
+		categories, _ = categoryRepo.SearchCategories("e", 2)
+		if got := categoryNames(categories); got != "Science,Updated Technology" {
+			t.Errorf("Expected two matches in name order, got %s", got)
+		}
+		if categories, _ := categoryRepo.SearchCategories("%", 10); len(categories) != 0 {
+			t.Errorf("Expected %% to be matched literally, got %+v", categories)
+		}
 	})
 
-	// TODO: Test GetCategoriesWithPosts method with GORM Preload
 	t.Run("GetCategoriesWithPosts with GORM Preload", func(t *testing.T) {
-		// TODO: Test GORM Preload functionality
-		// - Create categories and posts
-		// - Test eager loading with Preload
-		// - Verify associations are loaded
-		//
-		// Example:
-		// categories, err := categoryRepo.GetCategoriesWithPosts()
-		// assert.NoError(t, err)
-		// for _, category := range categories {
-		//     assert.NotNil(t, category.Posts) // Posts should be loaded
-		// }
-
-		t.Skip("TODO: implement GORM Preload test")
+		users := NewUserRepository(db)
+		posts := NewPostRepository(db)
+		author, _ := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		first, _ := posts.Create(&models.CreatePostRequest{UserID: author.ID, Title: "First post"})
+		deleted, _ := posts.Create(&models.CreatePostRequest{UserID: author.ID, Title: "Deleted post"})
+		assignCategory(t, db, first.ID, technology.ID)
+		assignCategory(t, db, deleted.ID, technology.ID)
+		posts.Delete(deleted.ID)
+
+		categories, err := categoryRepo.GetCategoriesWithPosts()
+		if err != nil {
+			t.Fatalf("GetCategoriesWithPosts() failed: %v", err)
+		}
+		for _, category := range categories {
+			want := 0
+			if category.ID == technology.ID {
+				want = 1
+			}
+			if len(category.Posts) != want {
+				t.Errorf("Expected %s to have %d posts, got %+v", category.Name, want, category.Posts)
+			}
+		}
 	})
 
-	// TODO: Test Count method with GORM
 	t.Run("Count with GORM", func(t *testing.T) {
-		// TODO: Test GORM Count functionality
-		// - Test count with no records
-		// - Test count with multiple records
-		// - Test count with soft deleted records
-		//
-		// Example:
-		// count, err := categoryRepo.Count()
-		// assert.NoError(t, err)
-		// assert.Equal(t, int64(3), count)
-
-		t.Skip("TODO: implement GORM Count test")
+		if count, err := categoryRepo.Count(); err != nil || count != 3 {
+			t.Errorf("Expected 3 categories, got %d, %v", count, err)
+		}
+	})
+
+	t.Run("Delete with GORM", func(t *testing.T) {
+		art, _ := categoryRepo.FindByName("Art")
+		if err := categoryRepo.Delete(art.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := categoryRepo.GetByID(art.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected a deleted category to be hidden, got %v", err)
+		}
+		if count, _ := categoryRepo.Count(); count != 2 {
+			t.Errorf("Expected deleted categories to be left out of Count, got %d", count)
+		}
+		if err := categoryRepo.Delete(art.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound deleting twice, got %v", err)
+		}
 	})
 
-	// TODO: Test Transaction method with GORM
 	t.Run("Transaction with GORM", func(t *testing.T) {
-		// TODO: Test GORM Transaction functionality
-		// - Test successful transaction
-		// - Test transaction rollback on error
-		// - Verify atomicity
-		//
-		// Example:
-		// categories := []models.Category{
-		//     {Name: "Cat1"}, {Name: "Cat2"}, {Name: "Cat3"},
-		// }
-		// err := categoryRepo.CreateWithTransaction(categories)
-		// assert.NoError(t, err)
-
-		t.Skip("TODO: implement GORM Transaction test")
+		categories := []models.Category{{Name: "Cat1"}, {Name: "Cat2"}, {Name: "Cat3"}}
+		if err := categoryRepo.CreateWithTransaction(categories); err != nil {
+			t.Fatalf("CreateWithTransaction() failed: %v", err)
+		}
+		for _, category := range categories {
+			if category.ID == 0 {
+				t.Errorf("Expected IDs to be set on the caller's categories, got %+v", categories)
+			}
+		}
+
+		// The duplicate rolls back the category before it
+		if err := categoryRepo.CreateWithTransaction([]models.Category{{Name: "Cat4"}, {Name: "Cat1"}}); err == nil {
+			t.Fatal("Expected an error for a duplicate name")
+		}
+		if _, err := categoryRepo.FindByName("Cat4"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected Cat4 to be rolled back, got %v", err)
+		}
+	})
+}
+
+func TestCategoryHierarchy(t *testing.T) {
+	categoryRepo, db := setupCategoryRepo(t)
+
+	// Tree under test:
+	//
+	//	Science
+	//	├── Physics
+	//	│   └── Quantum
+	//	└── Biology
+	//	Art
+	create := func(name string, parent *models.Category) *models.Category {
+		t.Helper()
+		category := &models.Category{Name: name}
+		if parent != nil {
+			category.ParentID = &parent.ID
+		}
+		if err := categoryRepo.Create(category); err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+		return category
+	}
+	science := create("Science", nil)
+	physics := create("Physics", science)
+	quantum := create("Quantum", physics)
+	biology := create("Biology", science)
+	art := create("Art", nil)
+
+	outline := func(id uint) string {
+		t.Helper()
+		nodes, err := categoryRepo.GetSubtree(id)
+		if err != nil {
+			t.Fatalf("GetSubtree() failed: %v", err)
+		}
+		lines := make([]string, len(nodes))
+		for i, node := range nodes {
+			lines[i] = strings.Repeat("-", node.Depth) + node.Name
+		}
+		return strings.Join(lines, ",")
+	}
+
+	t.Run("Create under a missing parent", func(t *testing.T) {
+		missing := uint(999)
+		if err := categoryRepo.Create(&models.Category{Name: "Orphan", ParentID: &missing}); !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("Expected ErrParentNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Subtree", func(t *testing.T) {
+		if got := outline(science.ID); got != "Science,-Biology,-Physics,--Quantum" {
+			t.Errorf("Unexpected subtree %s", got)
+		}
+		if got := outline(quantum.ID); got != "Quantum" {
+			t.Errorf("Expected a leaf's subtree to be itself, got %s", got)
+		}
+		if _, err := categoryRepo.GetSubtree(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Ancestors and breadcrumbs", func(t *testing.T) {
+		ancestors, err := categoryRepo.GetAncestors(quantum.ID)
+		if err != nil || categoryNames(ancestors) != "Science,Physics" {
+			t.Errorf("Expected Science,Physics, got %s, %v", categoryNames(ancestors), err)
+		}
+		breadcrumbs, err := categoryRepo.GetBreadcrumbs(quantum.ID)
+		if err != nil || categoryNames(breadcrumbs) != "Science,Physics,Quantum" {
+			t.Errorf("Expected Science,Physics,Quantum, got %s, %v", categoryNames(breadcrumbs), err)
+		}
+		if ancestors, err := categoryRepo.GetAncestors(science.ID); err != nil || len(ancestors) != 0 {
+			t.Errorf("Expected no ancestors for a top-level category, got %+v, %v", ancestors, err)
+		}
+		if _, err := categoryRepo.GetBreadcrumbs(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Move a subtree", func(t *testing.T) {
+		if err := categoryRepo.Move(physics.ID, &art.ID); err != nil {
+			t.Fatalf("Move() failed: %v", err)
+		}
+		if got := outline(art.ID); got != "Art,-Physics,--Quantum" {
+			t.Errorf("Expected Physics and Quantum under Art, got %s", got)
+		}
+		if got := outline(science.ID); got != "Science,-Biology" {
+			t.Errorf("Expected Physics gone from Science, got %s", got)
+		}
+		breadcrumbs, _ := categoryRepo.GetBreadcrumbs(quantum.ID)
+		if got := categoryNames(breadcrumbs); got != "Art,Physics,Quantum" {
+			t.Errorf("Expected Quantum's breadcrumbs to follow the move, got %s", got)
+		}
+		moved, _ := categoryRepo.GetByID(physics.ID)
+		if moved.ParentID == nil || *moved.ParentID != art.ID {
+			t.Errorf("Expected parent_id to be Art, got %v", moved.ParentID)
+		}
+
+		// To the top level and back
+		if err := categoryRepo.Move(physics.ID, nil); err != nil {
+			t.Fatalf("Move() to the top level failed: %v", err)
+		}
+		if ancestors, _ := categoryRepo.GetAncestors(quantum.ID); categoryNames(ancestors) != "Physics" {
+			t.Errorf("Expected Physics to be top-level, got ancestors %s", categoryNames(ancestors))
+		}
+		if err := categoryRepo.Move(physics.ID, &science.ID); err != nil {
+			t.Fatalf("Move() back failed: %v", err)
+		}
+		if got := outline(science.ID); got != "Science,-Biology,-Physics,--Quantum" {
+			t.Errorf("Expected the original tree back, got %s", got)
+		}
+	})
+
+	t.Run("Moves that would make a cycle", func(t *testing.T) {
+		if err := categoryRepo.Move(science.ID, &science.ID); !errors.Is(err, ErrCategoryCycle) {
+			t.Errorf("Expected ErrCategoryCycle moving under itself, got %v", err)
+		}
+		if err := categoryRepo.Move(science.ID, &quantum.ID); !errors.Is(err, ErrCategoryCycle) {
+			t.Errorf("Expected ErrCategoryCycle moving under a descendant, got %v", err)
+		}
+		if got := outline(science.ID); got != "Science,-Biology,-Physics,--Quantum" {
+			t.Errorf("Expected a rejected move to change nothing, got %s", got)
+		}
+
+		missing := uint(999)
+		if err := categoryRepo.Move(science.ID, &missing); !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("Expected ErrParentNotFound, got %v", err)
+		}
+		if err := categoryRepo.Move(999, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := categoryRepo.Delete(physics.ID); !errors.Is(err, ErrCategoryHasChildren) {
+			t.Errorf("Expected ErrCategoryHasChildren, got %v", err)
+		}
+	})
+
+	t.Run("Post counts", func(t *testing.T) {
+		users := NewUserRepository(db)
+		posts := NewPostRepository(db)
+		author, _ := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		newPost := func(title string) int {
+			post, err := posts.Create(&models.CreatePostRequest{UserID: author.ID, Title: title})
+			if err != nil {
+				t.Fatalf("Failed to create post: %v", err)
+			}
+			return post.ID
+		}
+
+		atoms := newPost("Atoms explained")
+		assignCategory(t, db, atoms, physics.ID)
+		assignCategory(t, db, atoms, quantum.ID) // Counted once for Physics and Science
+		assignCategory(t, db, newPost("Entanglement"), quantum.ID)
+		assignCategory(t, db, newPost("Cells"), biology.ID)
+		deleted := newPost("Deleted post")
+		assignCategory(t, db, deleted, science.ID)
+		posts.Delete(deleted)
+
+		counts, err := categoryRepo.GetPostCounts(nil)
+		if err != nil {
+			t.Fatalf("GetPostCounts() failed: %v", err)
+		}
+		want := map[string][2]int64{
+			"Art":     {0, 0},
+			"Biology": {1, 1},
+			"Physics": {1, 2},
+			"Quantum": {2, 2},
+			"Science": {0, 3},
+		}
+		if len(counts) != len(want) {
+			t.Fatalf("Expected counts for %d categories, got %+v", len(want), counts)
+		}
+		for _, count := range counts {
+			if got := [2]int64{count.PostCount, count.SubtreePostCount}; got != want[count.Name] {
+				t.Errorf("%s: expected direct and subtree counts %v, got %v", count.Name, want[count.Name], got)
+			}
+		}
+
+		counts, err = categoryRepo.GetPostCounts(&physics.ID)
+		if err != nil || len(counts) != 2 || counts[0].Name != "Physics" || counts[1].Name != "Quantum" {
+			t.Errorf("Expected counts for Physics and Quantum only, got %+v, %v", counts, err)
+		}
+	})
+
+	t.Run("Mismatched closure", func(t *testing.T) {
+		// parent_id changed without going through Move
+		if _, err := db.Exec(database.DialectOf(db).Rebind(`UPDATE categories SET parent_id = NULL WHERE id = ?`), quantum.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := categoryRepo.GetSubtree(science.ID); !errors.Is(err, ErrHierarchyMismatch) {
+			t.Errorf("Expected ErrHierarchyMismatch for a top-level category, got %v", err)
+		}
+		if _, err := db.Exec(database.DialectOf(db).Rebind(`UPDATE categories SET parent_id = ? WHERE id = ?`), art.ID, quantum.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := categoryRepo.GetSubtree(science.ID); !errors.Is(err, ErrHierarchyMismatch) {
+			t.Errorf("Expected ErrHierarchyMismatch for a parent outside the subtree, got %v", err)
+		}
 	})
 }
 
 // TestGORMModelHooks tests GORM model hooks and lifecycle
 func TestGORMModelHooks(t *testing.T) {
-	// TODO: Test GORM hooks
-	t.Run("BeforeCreate hook", func(t *testing.T) {
-		// TODO: Test BeforeCreate hook functionality
-		// - Verify hook is called
-		// - Test data validation in hook
-		// - Test default value setting
+	categoryRepo, db := setupCategoryRepo(t)
 
-		t.Skip("TODO: implement GORM BeforeCreate hook test")
+	t.Run("BeforeCreate hook", func(t *testing.T) {
+		category := &models.Category{Name: "Defaults"}
+		if err := categoryRepo.Create(category); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if category.Color != models.DefaultCategoryColor {
+			t.Errorf("Expected the default color, got %q", category.Color)
+		}
+
+		if err := categoryRepo.Create(&models.Category{Name: "X"}); !errors.Is(err, models.ErrCategoryNameLength) {
+			t.Errorf("Expected ErrCategoryNameLength, got %v", err)
+		}
+		if count, _ := categoryRepo.Count(); count != 1 {
+			t.Errorf("Expected the invalid category not to be created, got %d categories", count)
+		}
 	})
 
 	t.Run("AfterCreate hook", func(t *testing.T) {
-		// TODO: Test AfterCreate hook functionality
-		// - Verify hook is called after creation
-		// - Test side effects (logging, notifications)
-
-		t.Skip("TODO: implement GORM AfterCreate hook test")
+		category, err := categoryRepo.FindByName("Defaults")
+		if err != nil {
+			t.Fatalf("FindByName() failed: %v", err)
+		}
+		history, err := NewAuditRepository(db).History("categories", int64(category.ID))
+		if err != nil || len(history) != 1 || history[0].Operation != models.AuditCreate {
+			t.Errorf("Expected the create in the audit log, got %+v, %v", history, err)
+		}
 	})
 
 	t.Run("Validation methods", func(t *testing.T) {
-		// TODO: Test model validation methods
-		// - Test IsActive method
-		// - Test validation constraints
-
-		t.Skip("TODO: implement GORM validation tests")
+		tests := []struct {
+			req  models.CreateCategoryRequest
+			want error
+		}{
+			{models.CreateCategoryRequest{Name: "Go", Color: "#0af"}, nil},
+			{models.CreateCategoryRequest{Name: "  "}, models.ErrCategoryNameRequired},
+			{models.CreateCategoryRequest{Name: strings.Repeat("a", 101)}, models.ErrCategoryNameLength},
+			{models.CreateCategoryRequest{Name: "Go", Description: strings.Repeat("a", 501)}, models.ErrDescriptionTooLong},
+			{models.CreateCategoryRequest{Name: "Go", Color: "#12345g"}, models.ErrInvalidColor},
+		}
+		for _, tt := range tests {
+			if err := tt.req.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate(%+v) = %v, expected %v", tt.req, err, tt.want)
+			}
+		}
+
+		category := (&models.CreateCategoryRequest{Name: "Go"}).ToCategory()
+		if !category.IsActive() {
+			t.Error("Expected a new category to be active")
+		}
 	})
 }
 
 // TestGORMScopes tests GORM scopes functionality
 func TestGORMScopes(t *testing.T) {
-	// TODO: Test GORM scopes
-	t.Run("ActiveCategories scope", func(t *testing.T) {
-		// TODO: Test ActiveCategories scope
-		// - Create active and inactive categories
-		// - Test scope filters correctly
+	categoryRepo, db := setupCategoryRepo(t)
+	gormDB := categoryRepo.db
+
+	active := &models.Category{Name: "Active"}
+	inactive := &models.Category{Name: "Inactive"}
+	for _, category := range []*models.Category{active, inactive} {
+		if err := categoryRepo.Create(category); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	inactive.Active = false
+	if err := categoryRepo.Update(inactive); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
 
-		t.Skip("TODO: implement GORM ActiveCategories scope test")
+	t.Run("ActiveCategories scope", func(t *testing.T) {
+		var categories []models.Category
+		if err := gormDB.Scopes(models.ActiveCategories).Find(&categories).Error; err != nil {
+			t.Fatalf("Find() failed: %v", err)
+		}
+		if got := categoryNames(categories); got != "Active" {
+			t.Errorf("Expected only the active category, got %s", got)
+		}
 	})
 
 	t.Run("CategoriesWithPosts scope", func(t *testing.T) {
-		// TODO: Test CategoriesWithPosts scope
-		// - Create categories with and without posts
-		// - Test scope filters correctly
-
-		t.Skip("TODO: implement GORM CategoriesWithPosts scope test")
+		author, _ := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		post, _ := NewPostRepository(db).Create(&models.CreatePostRequest{UserID: author.ID, Title: "Filed post"})
+		assignCategory(t, db, post.ID, inactive.ID)
+
+		var categories []models.Category
+		if err := gormDB.Scopes(models.CategoriesWithPosts).Find(&categories).Error; err != nil {
+			t.Fatalf("Find() failed: %v", err)
+		}
+		if got := categoryNames(categories); got != "Inactive" {
+			t.Errorf("Expected only the category with a post, got %s", got)
+		}
+		if count, err := inactive.PostCount(gormDB); err != nil || count != 1 {
+			t.Errorf("Expected PostCount() = 1, got %d, %v", count, err)
+		}
 	})
 }
 
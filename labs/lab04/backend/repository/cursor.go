@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lab04-backend/models"
+)
+
+// Pagination errors
+var (
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrCursorWithOffset = errors.New("cursor and offset cannot be used together")
+)
+
+// defaultCursorKey signs cursors for repositories without a key of their
+// own. It is random per process, so its cursors stop working on restart.
+var defaultCursorKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// PostPage is one page of posts in keyset order
+type PostPage struct {
+	Posts      []models.Post
+	NextCursor string // Passed back to fetch the following page; empty on the last page
+}
+
+// pageCursor marks the last post of a page in an (orderBy, id) ordering.
+// The ordering is part of the cursor so it cannot be replayed against a
+// different one.
+type pageCursor struct {
+	OrderBy  string
+	OrderDir string
+	Value    string // Sort column value: the title, or a timestamp in Unix nanoseconds
+	ID       int
+}
+
+// newPostPage makes a page from up to limit+1 posts fetched in keyset
+// order. The extra post only shows that another page follows.
+func newPostPage(key []byte, posts []models.Post, limit int, orderBy, orderDir string) *PostPage {
+	page := &PostPage{Posts: posts}
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		page.NextCursor = encodeCursor(key, cursorAfter(posts[limit-1], orderBy, orderDir))
+	}
+	return page
+}
+
+// cursorAfter returns the cursor positioned at post
+func cursorAfter(post models.Post, orderBy, orderDir string) pageCursor {
+	c := pageCursor{OrderBy: orderBy, OrderDir: orderDir, ID: post.ID}
+	switch orderBy {
+	case "title":
+		c.Value = post.Title
+	case "updated_at":
+		c.Value = strconv.FormatInt(post.UpdatedAt.UnixNano(), 10)
+	default:
+		c.Value = strconv.FormatInt(post.CreatedAt.UnixNano(), 10)
+	}
+	return c
+}
+
+// sortValue converts the cursor's value to what its column is compared with
+func (c pageCursor) sortValue() (interface{}, error) {
+	if c.OrderBy == "title" {
+		return c.Value, nil
+	}
+	nanos, err := strconv.ParseInt(c.Value, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// UTC, like the timestamps the repositories write, since SQLite
+	// compares them as strings and the offset is part of the string
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+// keysetCondition selects the rows after the cursor. Rows are ordered by
+// the sort column and then id in the same direction, so a row value
+// comparison finds them and new rows never shift later pages.
+func (c pageCursor) keysetCondition() (string, []interface{}, error) {
+	value, err := c.sortValue()
+	if err != nil {
+		return "", nil, err
+	}
+	op := ">"
+	if c.OrderDir == "DESC" {
+		op = "<"
+	}
+	return "(" + c.OrderBy + ", id) " + op + " (?, ?)", []interface{}{value, c.ID}, nil
+}
+
+// encodeCursor turns a page position into an opaque, URL-safe token signed
+// with key so clients cannot forge positions
+func encodeCursor(key []byte, c pageCursor) string {
+	raw := fmt.Sprintf("%s:%s:%d:%s", c.OrderBy, c.OrderDir, c.ID, c.Value)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(raw))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(key, payload))
+}
+
+// decodeCursor checks and parses a token produced by encodeCursor
+func decodeCursor(key []byte, token string) (pageCursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signCursor(key, payload)) {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	// The value goes last since a title may contain colons
+	parts := strings.SplitN(string(raw), ":", 4)
+	if len(parts) != 4 || !postSortColumns[parts[0]] || (parts[1] != "ASC" && parts[1] != "DESC") {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return pageCursor{OrderBy: parts[0], OrderDir: parts[1], ID: id, Value: parts[3]}, nil
+}
+
+func signCursor(key []byte, payload string) []byte {
+	if len(key) == 0 {
+		key = defaultCursorKey
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
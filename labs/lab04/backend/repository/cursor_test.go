@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+)
+
+func TestCursorSigning(t *testing.T) {
+	key := []byte("secret")
+	c := pageCursor{OrderBy: "title", OrderDir: "ASC", ID: 7, Value: "Colons: are: fine"}
+	token := encodeCursor(key, c)
+
+	got, err := decodeCursor(key, token)
+	if err != nil || got != c {
+		t.Fatalf("decodeCursor() = %+v, %v; want %+v", got, err, c)
+	}
+	if _, err := decodeCursor([]byte("other"), token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected a cursor signed with another key to be rejected, got %v", err)
+	}
+
+	// Changing any part of the payload breaks the signature
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := encodeCursor([]byte("attacker"), pageCursor{OrderBy: "title", OrderDir: "ASC", ID: 1, Value: "a"})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	for _, bad := range []string{"", "garbage", payload, forgedPayload + "." + signature, payload + ".!!"} {
+		if _, err := decodeCursor(key, bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestSearchPostsPage(t *testing.T) {
+	db := openTestDB(t)
+	search := NewSearchService(db)
+	ctx := context.Background()
+
+	alice, _ := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	base := time.Now().Add(-time.Hour)
+	insert := func(title string, minute int) {
+		t.Helper()
+		at := base.Add(time.Duration(minute) * time.Minute)
+		_, err := db.Exec(database.DialectOf(db).Rebind(
+			`INSERT INTO posts (user_id, title, content, published, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`),
+			alice.ID, title, "content", true, at, at.Add(time.Duration(-minute)*time.Second))
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+	// Repeated titles and timestamps make the id tie-break matter
+	for i, title := range []string{"Delta", "Alpha", "Charlie", "Alpha", "Bravo", "Charlie", "Echo"} {
+		insert(title, i/2)
+	}
+	all, _ := search.SearchPosts(ctx, SearchFilters{})
+	original := len(all)
+
+	for _, orderBy := range []string{"title", "created_at", "updated_at"} {
+		for _, orderDir := range []string{"ASC", "DESC"} {
+			name := orderBy + " " + orderDir
+			filters := SearchFilters{OrderBy: orderBy, OrderDir: orderDir, Limit: 2}
+			expected, err := search.SearchPosts(ctx, SearchFilters{OrderBy: orderBy, OrderDir: orderDir})
+			if err != nil {
+				t.Fatalf("%s: SearchPosts() failed: %v", name, err)
+			}
+
+			seen := map[int]bool{}
+			var got []int
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatalf("%s: paging did not end", name)
+				}
+				page, err := search.SearchPostsPage(ctx, filters)
+				if err != nil {
+					t.Fatalf("%s: SearchPostsPage() failed: %v", name, err)
+				}
+				for _, p := range page.Posts {
+					if seen[p.ID] {
+						t.Errorf("%s: post %d returned twice", name, p.ID)
+					}
+					seen[p.ID] = true
+					got = append(got, p.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				// Rows inserted mid-way must not shift the remaining pages
+				if pages == 0 {
+					insert(fmt.Sprintf("Foxtrot %s", name), 10)
+				}
+				filters.Cursor = page.NextCursor
+			}
+
+			for i, p := range expected {
+				if !seen[p.ID] {
+					t.Errorf("%s: post %d (%s) was skipped", name, p.ID, p.Title)
+				}
+				if i < 2 && got[i] != p.ID {
+					t.Errorf("%s: expected post %d at position %d, got %d", name, p.ID, i, got[i])
+				}
+			}
+		}
+	}
+
+	all, _ = search.SearchPosts(ctx, SearchFilters{Limit: 100})
+	if len(all) != original+6 {
+		t.Errorf("Expected %d posts after the inserts, got %d", original+6, len(all))
+	}
+
+	page, _ := search.SearchPostsPage(ctx, SearchFilters{OrderBy: "title", Limit: 1})
+	if _, err := search.SearchPostsPage(ctx, SearchFilters{OrderBy: "created_at", Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected a cursor for another ordering to be rejected, got %v", err)
+	}
+	if _, err := search.SearchPostsPage(ctx, SearchFilters{OrderBy: "title", Cursor: page.NextCursor, Offset: 1}); !errors.Is(err, ErrCursorWithOffset) {
+		t.Errorf("Expected ErrCursorWithOffset, got %v", err)
+	}
+
+	other := NewSearchService(db)
+	other.SetCursorKey([]byte("another key"))
+	if _, err := other.SearchPostsPage(ctx, SearchFilters{OrderBy: "title", Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected a cursor signed with another key to be rejected, got %v", err)
+	}
+}
+
+func TestPostRepository_GetPage(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewPostRepository(db)
+	repo.SetCursorKey([]byte("secret"))
+
+	alice, _ := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	for i := 0; i < 5; i++ {
+		if _, err := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: fmt.Sprintf("Post number %d", i)}); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+
+	first, err := repo.GetPage("", 2)
+	if err != nil {
+		t.Fatalf("GetPage() failed: %v", err)
+	}
+	if len(first.Posts) != 2 || first.Posts[0].Title != "Post number 4" || first.NextCursor == "" {
+		t.Fatalf("Expected the 2 newest posts and a cursor, got %+v", first)
+	}
+
+	// A newer post lands before the first page rather than in the next one
+	repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Late post"})
+
+	second, _ := repo.GetPage(first.NextCursor, 2)
+	third, _ := repo.GetPage(second.NextCursor, 2)
+	if titles := postTitles(append(second.Posts, third.Posts...)); strings.Join(titles, ",") != "Post number 2,Post number 1,Post number 0" {
+		t.Errorf("Expected the remaining older posts in order, got %v", titles)
+	}
+	if third.NextCursor != "" {
+		t.Errorf("Expected no cursor on the last page, got %q", third.NextCursor)
+	}
+
+	if _, err := repo.GetPage("not-a-cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestPostRepository_GetPageAcrossTimeZones(t *testing.T) {
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+
+	db := openTestDB(t)
+	repo := NewPostRepository(db)
+	alice, _ := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+
+	// Posts written by a process west of UTC and paged by one east of it
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	for i := 0; i < 4; i++ {
+		if _, err := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: fmt.Sprintf("Post number %d", i)}); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	time.Local = time.FixedZone("UTC+9", 9*60*60)
+
+	first, err := repo.GetPage("", 2)
+	if err != nil {
+		t.Fatalf("GetPage() failed: %v", err)
+	}
+	second, err := repo.GetPage(first.NextCursor, 2)
+	if err != nil {
+		t.Fatalf("GetPage() failed: %v", err)
+	}
+	if titles := postTitles(append(first.Posts, second.Posts...)); strings.Join(titles, ",") != "Post number 3,Post number 2,Post number 1,Post number 0" {
+		t.Errorf("Expected every post once, newest first, got %v", titles)
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Markers around matched terms in highlighted titles and snippets
+const (
+	highlightOpen   = "<mark>"
+	highlightClose  = "</mark>"
+	snippetEllipsis = "…"
+)
+
+// snippetTokens is how many tokens an FTS5 snippet spans, and
+// fallbackSnippetRunes roughly the same in characters for snippets built
+// without FTS5
+const (
+	snippetTokens        = 16
+	fallbackSnippetRunes = 96
+)
+
+// searchTerm is one word or quoted phrase of a search query
+type searchTerm struct {
+	text   string
+	prefix bool // Ends in *, so it matches words starting with text
+}
+
+// parseSearchQuery splits a query into words and "quoted phrases". A
+// trailing * makes a word or phrase match as a prefix. Anything else,
+// including FTS5 operators, is taken literally, so no input can make a
+// query invalid. Terms without letters or digits are dropped.
+func parseSearchQuery(query string) []searchTerm {
+	var terms []searchTerm
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+			continue
+		case runes[i] == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term := searchTerm{text: strings.Join(strings.Fields(string(runes[i+1:min(end, len(runes))])), " ")}
+			i = end + 1
+			if i < len(runes) && runes[i] == '*' {
+				term.prefix = true
+				i++
+			}
+			terms = appendTerm(terms, term)
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			terms = appendTerm(terms, searchTerm{
+				text:   strings.ReplaceAll(word, "*", ""),
+				prefix: strings.HasSuffix(word, "*"),
+			})
+			i = end
+		}
+	}
+	return terms
+}
+
+func appendTerm(terms []searchTerm, term searchTerm) []searchTerm {
+	if strings.IndexFunc(term.text, isWordRune) < 0 {
+		return terms
+	}
+	return append(terms, term)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ftsMatch writes terms as an FTS5 query that matches rows containing all
+// of them. Each term is quoted so FTS5 reads it as a string, never as
+// syntax.
+func ftsMatch(terms []searchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + strings.ReplaceAll(term.text, `"`, `""`) + `"`
+		if term.prefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// highlightTerms marks every occurrence of the terms in text, ignoring
+// case. It stands in for FTS5's highlight() when there is no index.
+func highlightTerms(text string, terms []searchTerm) string {
+	runes := []rune(text)
+	marked := matchedRunes(runes, terms)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(highlightOpen)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(highlightClose)
+		}
+	}
+	return b.String()
+}
+
+// snippetAround returns the part of text around the first match, with the
+// terms highlighted, standing in for FTS5's snippet()
+func snippetAround(text string, terms []searchTerm) string {
+	runes := []rune(text)
+	if len(runes) <= fallbackSnippetRunes {
+		return highlightTerms(text, terms)
+	}
+
+	first := 0
+	for i, m := range matchedRunes(runes, terms) {
+		if m {
+			first = i
+			break
+		}
+	}
+	start := max(0, first-fallbackSnippetRunes/3)
+	end := min(len(runes), start+fallbackSnippetRunes)
+	start = max(0, end-fallbackSnippetRunes)
+
+	snippet := highlightTerms(string(runes[start:end]), terms)
+	if start > 0 {
+		snippet = snippetEllipsis + snippet
+	}
+	if end < len(runes) {
+		snippet += snippetEllipsis
+	}
+	return snippet
+}
+
+// matchedRunes reports which runes of text fall inside a term
+func matchedRunes(text []rune, terms []searchTerm) []bool {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term.text))
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) == string(needle) {
+				for j := i; j < i+len(needle); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+	return marked
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []searchTerm
+		match string
+	}{
+		{"golang", []searchTerm{{text: "golang"}}, `"golang"`},
+		{"go* tips", []searchTerm{{text: "go", prefix: true}, {text: "tips"}}, `"go"* "tips"`},
+		{`"hello   world" again`, []searchTerm{{text: "hello world"}, {text: "again"}}, `"hello world" "again"`},
+		{`"hello wor"*`, []searchTerm{{text: "hello wor", prefix: true}}, `"hello wor"*`},
+		// FTS5 syntax is taken literally and cannot break the query
+		{`title:x OR NOT "unclosed`, []searchTerm{{text: "title:x"}, {text: "OR"}, {text: "NOT"}, {text: "unclosed"}}, `"title:x" "OR" "NOT" "unclosed"`},
+		{`say""hi`, []searchTerm{{text: "say"}, {text: "hi"}}, `"say" "hi"`},
+		{`* - "" ()`, nil, ``},
+	}
+	for _, tt := range tests {
+		got := parseSearchQuery(tt.query)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSearchQuery(%q) = %+v; want %+v", tt.query, got, tt.want)
+		}
+		if match := ftsMatch(got); match != tt.match {
+			t.Errorf("ftsMatch(%q) = %s; want %s", tt.query, match, tt.match)
+		}
+	}
+}
+
+func TestHighlightTerms(t *testing.T) {
+	terms := parseSearchQuery("go lang")
+	if got := highlightTerms("Golang and GO", terms); got != "<mark>Golang</mark> and <mark>GO</mark>" {
+		t.Errorf("highlightTerms() = %s", got)
+	}
+
+	long := strings.Repeat("filler ", 30) + "the golang part " + strings.Repeat("more ", 30)
+	snippet := snippetAround(long, parseSearchQuery("golang"))
+	if !strings.HasPrefix(snippet, snippetEllipsis) || !strings.HasSuffix(snippet, snippetEllipsis) || !strings.Contains(snippet, "<mark>golang</mark>") {
+		t.Errorf("Expected a trimmed snippet around the match, got %q", snippet)
+	}
+}
+
+func TestSearchPostHits(t *testing.T) {
+	db := openTestDB(t)
+	search := NewSearchService(db)
+	posts := NewPostRepository(db)
+	ctx := context.Background()
+	fullText, err := database.HasFullTextIndex(ctx, db)
+	if err != nil {
+		t.Fatalf("HasFullTextIndex() failed: %v", err)
+	}
+	t.Logf("full-text index available: %v", fullText)
+
+	alice, _ := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	create := func(title, content string) *models.Post {
+		t.Helper()
+		post, err := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: title, Content: content, Published: true})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		return post
+	}
+	inContent := create("Weekly notes", "Some thoughts about databases and golang tooling")
+	inTitle := create("Golang generics", "Type parameters explained")
+	phrase := create("Concurrency", "Channels make golang concurrency pleasant")
+	removed := create("Golang removed", "Deleted before searching")
+	posts.Delete(removed.ID)
+
+	hits, err := search.SearchPostHits(ctx, SearchFilters{Query: "golang"})
+	if err != nil {
+		t.Fatalf("SearchPostHits() failed: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("Expected 3 hits without the deleted post, got %+v", hits)
+	}
+	if fullText {
+		// bm25 weights the title match above the content matches
+		if hits[0].ID != inTitle.ID || hits[0].Score <= hits[1].Score {
+			t.Errorf("Expected the title match ranked first, got %+v", hits)
+		}
+	}
+	for _, hit := range hits {
+		if !strings.Contains(strings.ToLower(hit.HighlightedTitle+hit.Snippet), "<mark>golang</mark>") {
+			t.Errorf("Expected the match highlighted in post %d, got %q / %q", hit.ID, hit.HighlightedTitle, hit.Snippet)
+		}
+	}
+
+	if hits, _ := search.SearchPostHits(ctx, SearchFilters{Query: `"golang concurrency"`}); len(hits) != 1 || hits[0].ID != phrase.ID {
+		t.Errorf("Expected only the phrase match, got %+v", hits)
+	}
+	if hits, _ := search.SearchPostHits(ctx, SearchFilters{Query: "datab*"}); len(hits) != 1 || hits[0].ID != inContent.ID {
+		t.Errorf("Expected the prefix to match databases, got %+v", hits)
+	}
+	if hits, _ := search.SearchPostHits(ctx, SearchFilters{Query: "golang", Limit: 1, Offset: 1}); len(hits) != 1 {
+		t.Errorf("Expected paging with limit and offset, got %d hits", len(hits))
+	}
+
+	// The index follows edits and deletes
+	title := "Rust generics"
+	posts.Update(inTitle.ID, &models.UpdatePostRequest{Title: &title})
+	posts.Purge(phrase.ID)
+	found, err := search.SearchPosts(ctx, SearchFilters{Query: "golang"})
+	if err != nil || len(found) != 1 || found[0].ID != inContent.ID {
+		t.Errorf("Expected only the untouched post to match, got %+v, %v", found, err)
+	}
+
+	if _, err := search.SearchPostHits(ctx, SearchFilters{Query: "  *  "}); !errors.Is(err, ErrQueryRequired) {
+		t.Errorf("Expected ErrQueryRequired, got %v", err)
+	}
+	if _, err := search.SearchPostHits(ctx, SearchFilters{Query: "golang", Cursor: "x"}); !errors.Is(err, ErrCursorRanked) {
+		t.Errorf("Expected ErrCursorRanked, got %v", err)
+	}
+}
@@ -1,101 +1,516 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	"lab04-backend/database"
 	"lab04-backend/models"
 )
 
+// Post errors
+var (
+	ErrUserDeleted      = errors.New("post belongs to a deleted user; restore the user first")
+	ErrCategoryNotFound = errors.New("category not found")
+)
+
+// categoriesPerQuery caps the IDs sent in one IN list, well under the bind
+// parameter limits of SQLite and Postgres
+const categoriesPerQuery = 500
+
+// postColumns selects models.PostColumns under the names of the Post's db
+// tags for scany. Content is nullable, so an unset one is selected as an
+// empty string.
+const postColumns = "id, user_id, title, COALESCE(content, '') AS content, published, created_at, updated_at, deleted_at"
+
 // PostRepository handles database operations for posts
 // This repository demonstrates SCANY MAPPING approach for result scanning
 type PostRepository struct {
-	db *sql.DB
+	db        DBTX
+	dialect   database.Dialect
+	cursorKey []byte
+	actor     string          // Recorded in the audit log for changes
+	ctx       context.Context // Governs every query; see WithContext
 }
 
 // NewPostRepository creates a new PostRepository
 func NewPostRepository(db *sql.DB) *PostRepository {
-	return &PostRepository{db: db}
+	return &PostRepository{db: db, dialect: database.DialectOf(db), ctx: context.Background()}
+}
+
+// SetCursorKey sets the key page cursors are signed with. Share one key
+// between instances and restarts for cursors to stay valid; without one a
+// random per-process key is used.
+func (r *PostRepository) SetCursorKey(key []byte) {
+	r.cursorKey = key
 }
 
-// TODO: Implement Create method using scany for result mapping
+// WithActor returns a copy of the repository that records actor in the
+// audit log as the author of its changes
+func (r *PostRepository) WithActor(actor string) *PostRepository {
+	repo := *r
+	repo.actor = actor
+	return &repo
+}
+
+// WithContext returns a copy of the repository whose queries stop when ctx
+// is cancelled or times out. Repositories of a unit of work already use
+// the context given to UnitOfWork.Do.
+func (r *PostRepository) WithContext(ctx context.Context) *PostRepository {
+	repo := *r
+	repo.ctx = ctx
+	return &repo
+}
+
+// withDB returns a copy of the repository running on db, such as a
+// transaction
+func (r *PostRepository) withDB(db DBTX) *PostRepository {
+	repo := *r
+	repo.db = db
+	return &repo
+}
+
+// Create validates and inserts a new post
 func (r *PostRepository) Create(req *models.CreatePostRequest) (*models.Post, error) {
-	// TODO: Create a new post in the database using scany for result mapping
-	// - Validate the request using req.Validate()
-	// - Insert into posts table with RETURNING clause
-	// - Use sqlscan.Get() to scan the RETURNING result into a Post struct
-	// Example: sqlscan.Get(context.Background(), r.db, &post, query, args...)
-	// This eliminates manual row scanning compared to user repository
-	return nil, fmt.Errorf("TODO: implement Create method with scany mapping")
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	post := req.ToPost()
+	err := inTx(r.ctx, r.db, func(db DBTX) error {
+		err := sqlscan.Get(r.ctx, db, post, r.dialect.Rebind(
+			`INSERT INTO posts (user_id, title, content, published, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING `+postColumns),
+			post.UserID, post.Title, post.Content, post.Published, post.CreatedAt, post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditCreate, "posts", post.ID, nil, post)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
-// TODO: Implement GetByID method using scany
+// GetByID returns a post, or sql.ErrNoRows if there is none or it is
+// deleted
 func (r *PostRepository) GetByID(id int) (*models.Post, error) {
-	// TODO: Get post by ID from database using scany
-	// - Use sqlscan.Get() instead of manual row.Scan()
-	// Example: sqlscan.Get(context.Background(), r.db, &post, "SELECT * FROM posts WHERE id = $1", id)
-	// Notice how this eliminates the need for manual field scanning
-	return nil, fmt.Errorf("TODO: implement GetByID method with scany")
+	return r.getPost(`WHERE id = ? AND deleted_at IS NULL`, id)
 }
 
-// TODO: Implement GetByUserID method using scany
+// GetByIDIncludingDeleted returns a post even if it is soft deleted, or
+// sql.ErrNoRows if there is none
+func (r *PostRepository) GetByIDIncludingDeleted(id int) (*models.Post, error) {
+	return r.getPost(`WHERE id = ?`, id)
+}
+
+func (r *PostRepository) getPost(where string, args ...interface{}) (*models.Post, error) {
+	var post models.Post
+	if err := sqlscan.Get(r.ctx, r.db, &post, r.dialect.Rebind(`SELECT `+postColumns+` FROM posts `+where), args...); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// GetByUserID returns a user's posts, newest first
 func (r *PostRepository) GetByUserID(userID int) ([]models.Post, error) {
-	// TODO: Get all posts by user ID using scany
-	// - Use sqlscan.Select() for multiple rows instead of manual rows.Next() loop
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, query, userID)
-	// This eliminates manual iteration and scanning
-	return nil, fmt.Errorf("TODO: implement GetByUserID method with scany")
+	return r.selectPosts(`AND user_id = ?`, 0, userID)
 }
 
-// TODO: Implement GetPublished method using scany
+// GetPublished returns every published post, newest first
 func (r *PostRepository) GetPublished() ([]models.Post, error) {
-	// TODO: Get all published posts using scany
-	// - Use sqlscan.Select() for multiple rows
-	// - Query posts where published = true
-	// - Order by created_at DESC
-	return nil, fmt.Errorf("TODO: implement GetPublished method with scany")
+	return r.selectPosts(`AND published = ?`, 0, true)
 }
 
-// TODO: Implement GetAll method using scany
+// GetAll returns every post, newest first
 func (r *PostRepository) GetAll() ([]models.Post, error) {
-	// TODO: Get all posts from database using scany
-	// - Use sqlscan.Select() instead of manual rows iteration
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, "SELECT * FROM posts ORDER BY created_at DESC")
-	// Compare this simplicity with manual scanning in user repository
-	return nil, fmt.Errorf("TODO: implement GetAll method with scany")
+	return r.selectPosts(``, 0)
+}
+
+// GetPage returns up to limit posts, newest first, starting after the
+// position in cursor. An empty cursor starts from the newest post. Posts
+// created while paging appear before the first page, so they never shift
+// the pages that follow.
+func (r *PostRepository) GetPage(cursor string, limit int) (*PostPage, error) {
+	limit = limitOrDefault(limit)
+	var and string
+	var args []interface{}
+	if cursor != "" {
+		c, err := decodeCursor(r.cursorKey, cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.OrderBy != "created_at" || c.OrderDir != "DESC" {
+			return nil, ErrInvalidCursor
+		}
+		condition, conditionArgs, err := c.keysetCondition()
+		if err != nil {
+			return nil, err
+		}
+		and, args = "AND "+condition, conditionArgs
+	}
+
+	posts, err := r.selectPosts(and, limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newPostPage(r.cursorKey, posts, limit, "created_at", "DESC"), nil
 }
 
-// TODO: Implement Update method using scany
+// selectPosts returns up to limit posts that are not deleted and match the
+// extra conditions in and, newest first. A limit of 0 returns them all.
+func (r *PostRepository) selectPosts(and string, limit int, args ...interface{}) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE deleted_at IS NULL ` + and + ` ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(limit)
+	}
+	posts := make([]models.Post, 0)
+	if err := sqlscan.Select(r.ctx, r.db, &posts, r.dialect.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Update changes the fields set in req and returns the updated post, or
+// sql.ErrNoRows if there is none or it is deleted. The updated post must
+// pass Post.Validate, so a post with empty content cannot be published.
 func (r *PostRepository) Update(id int, req *models.UpdatePostRequest) (*models.Post, error) {
-	// TODO: Update post in database using scany
-	// - Build dynamic UPDATE query based on non-nil fields in req
-	// - Update updated_at timestamp
-	// - Use sqlscan.Get() with RETURNING clause to get updated post
-	// This avoids a separate SELECT query after UPDATE
-	return nil, fmt.Errorf("TODO: implement Update method with scany")
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []interface{}
+	if req.Title != nil {
+		sets = append(sets, "title = ?")
+		args = append(args, *req.Title)
+	}
+	if req.Content != nil {
+		sets = append(sets, "content = ?")
+		args = append(args, *req.Content)
+	}
+	if req.Published != nil {
+		sets = append(sets, "published = ?")
+		args = append(args, *req.Published)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now().UTC(), id)
+
+	var post models.Post
+	err := inTx(r.ctx, r.db, func(db DBTX) error {
+		before, err := r.withDB(db).getPost(`WHERE id = ? AND deleted_at IS NULL`+forUpdate(r.dialect), id)
+		if err != nil {
+			return err
+		}
+		// Publishing needs content, whether the update sets it or the
+		// stored post already has it
+		merged := *before
+		if req.Title != nil {
+			merged.Title = *req.Title
+		}
+		if req.Content != nil {
+			merged.Content = *req.Content
+		}
+		if req.Published != nil {
+			merged.Published = *req.Published
+		}
+		if err := merged.Validate(); err != nil {
+			return err
+		}
+		err = sqlscan.Get(r.ctx, db, &post, r.dialect.Rebind(
+			`UPDATE posts SET `+strings.Join(sets, ", ")+` WHERE id = ? AND deleted_at IS NULL RETURNING `+postColumns),
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditUpdate, "posts", id, before, &post)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
 }
 
-// TODO: Implement Delete method (standard SQL)
+// Delete soft deletes a post, or returns sql.ErrNoRows if there is none or
+// it is already deleted
 func (r *PostRepository) Delete(id int) error {
-	// TODO: Delete post from database
-	// - Delete from posts table by ID
-	// - Return error if post doesn't exist
-	// Note: Delete operations typically don't need scany since no data is returned
-	return fmt.Errorf("TODO: implement Delete method")
+	now := time.Now().UTC()
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		result, err := db.ExecContext(r.ctx, r.dialect.Rebind(`UPDATE posts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`), now, id)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result); err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditDelete, "posts", id, deletedAt{nil}, deletedAt{now})
+	})
+}
+
+// Restore undeletes a post. It returns sql.ErrNoRows if there is no such
+// deleted post, and ErrUserDeleted while the post's author is deleted.
+func (r *PostRepository) Restore(id int) error {
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		var userDeleted bool
+		var deleted time.Time
+		err := db.QueryRowContext(r.ctx, r.dialect.Rebind(
+			`SELECT u.deleted_at IS NOT NULL, p.deleted_at FROM posts p JOIN users u ON u.id = p.user_id
+			WHERE p.id = ? AND p.deleted_at IS NOT NULL`+forUpdate(r.dialect)),
+			id,
+		).Scan(&userDeleted, &deleted)
+		if err != nil {
+			return err
+		}
+		if userDeleted {
+			return ErrUserDeleted
+		}
+		if _, err := db.ExecContext(r.ctx, r.dialect.Rebind(`UPDATE posts SET deleted_at = NULL WHERE id = ?`), id); err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditRestore, "posts", id, deletedAt{deleted}, deletedAt{nil})
+	})
 }
 
-// TODO: Implement Count method (standard SQL)
+// Purge permanently removes a post, deleted or not. It returns
+// sql.ErrNoRows if there is none. The audit log keeps its last values.
+func (r *PostRepository) Purge(id int) error {
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		post, err := r.withDB(db).getPost(`WHERE id = ?`+forUpdate(r.dialect), id)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(r.ctx, r.dialect.Rebind(`DELETE FROM posts WHERE id = ?`), id); err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditPurge, "posts", id, post, nil)
+	})
+}
+
+// Count returns the number of posts that are not deleted
 func (r *PostRepository) Count() (int, error) {
-	// TODO: Count total number of posts
-	// - Return count of posts in database
-	// - Can use standard QueryRow.Scan() for single values like count
-	return 0, fmt.Errorf("TODO: implement Count method")
+	var count int
+	err := r.db.QueryRowContext(r.ctx, `SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL`).Scan(&count)
+	return count, err
 }
 
-// TODO: Implement CountByUserID method (standard SQL)
+// CountByUserID returns the number of posts by a user that are not deleted
 func (r *PostRepository) CountByUserID(userID int) (int, error) {
-	// TODO: Count posts by user ID
-	// - Return count of posts for specific user
-	// - Use standard QueryRow.Scan() for single integer result
-	return 0, fmt.Errorf("TODO: implement CountByUserID method")
+	var count int
+	err := r.db.QueryRowContext(r.ctx, r.dialect.Rebind(`SELECT COUNT(*) FROM posts WHERE user_id = ? AND deleted_at IS NULL`), userID).Scan(&count)
+	return count, err
+}
+
+// AssignCategories files a post under categories. Categories it is already
+// in are skipped. It returns sql.ErrNoRows if the post does not exist or is
+// deleted, and ErrCategoryNotFound if any category does not exist or is
+// deleted, in which case none are assigned.
+func (r *PostRepository) AssignCategories(postID int, categoryIDs ...uint) error {
+	ids := uniqueCategoryIDs(categoryIDs)
+	return r.changeCategories(postID, func(db DBTX) error {
+		for start := 0; start < len(ids); start += categoriesPerQuery {
+			batch := ids[start:min(start+categoriesPerQuery, len(ids))]
+			found, err := queryIDs(r.ctx, db, r.dialect.Rebind(
+				`SELECT id FROM categories WHERE deleted_at IS NULL AND id IN (`+placeholders(len(batch))+`)`),
+				batch...,
+			)
+			if err != nil {
+				return err
+			}
+			if len(found) != len(batch) {
+				return ErrCategoryNotFound
+			}
+		}
+		for _, id := range ids {
+			_, err := db.ExecContext(r.ctx, r.dialect.Rebind(
+				`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?) ON CONFLICT DO NOTHING`),
+				postID, id,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UnassignCategories removes a post from categories. Categories it is not
+// in are skipped. It returns sql.ErrNoRows if the post does not exist or is
+// deleted.
+func (r *PostRepository) UnassignCategories(postID int, categoryIDs ...uint) error {
+	ids := uniqueCategoryIDs(categoryIDs)
+	return r.changeCategories(postID, func(db DBTX) error {
+		for start := 0; start < len(ids); start += categoriesPerQuery {
+			batch := ids[start:min(start+categoriesPerQuery, len(ids))]
+			_, err := db.ExecContext(r.ctx, r.dialect.Rebind(
+				`DELETE FROM post_categories WHERE post_id = ? AND category_id IN (`+placeholders(len(batch))+`)`),
+				append([]interface{}{postID}, batch...)...,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// postCategoryIDs is how category assignments appear in the audit log
+type postCategoryIDs struct {
+	CategoryIDs []int `json:"category_ids"`
+}
+
+// changeCategories runs change on a post's category assignments in a
+// transaction, and audits them as an update of the post if they changed
+func (r *PostRepository) changeCategories(postID int, change func(db DBTX) error) error {
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		if _, err := r.withDB(db).getPost(`WHERE id = ? AND deleted_at IS NULL`+forUpdate(r.dialect), postID); err != nil {
+			return err
+		}
+		assigned := func() ([]int, error) {
+			return queryIDs(r.ctx, db, r.dialect.Rebind(
+				`SELECT category_id FROM post_categories WHERE post_id = ? ORDER BY category_id`), postID)
+		}
+
+		before, err := assigned()
+		if err != nil {
+			return err
+		}
+		if err := change(db); err != nil {
+			return err
+		}
+		after, err := assigned()
+		if err != nil {
+			return err
+		}
+		if slices.Equal(before, after) {
+			return nil
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditUpdate, "posts", postID, postCategoryIDs{before}, postCategoryIDs{after})
+	})
+}
+
+// GetCategories returns the categories a post is filed under, ordered by
+// name, leaving out deleted ones
+func (r *PostRepository) GetCategories(postID int) ([]models.Category, error) {
+	byPost, err := r.categoriesOf([]interface{}{postID})
+	if err != nil {
+		return nil, err
+	}
+	categories := byPost[postID]
+	if categories == nil {
+		categories = make([]models.Category, 0)
+	}
+	return categories, nil
+}
+
+// GetByIDWithCategories is GetByID with the post's categories loaded
+func (r *PostRepository) GetByIDWithCategories(id int) (*models.Post, error) {
+	post, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	posts := []models.Post{*post}
+	if err := r.LoadCategories(posts); err != nil {
+		return nil, err
+	}
+	return &posts[0], nil
+}
+
+// GetAllWithCategories is GetAll with each post's categories loaded
+func (r *PostRepository) GetAllWithCategories() ([]models.Post, error) {
+	posts, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.LoadCategories(posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// LoadCategories fills in the Categories of each post, ordered by name and
+// leaving out deleted categories. It reads them all with one query per 500
+// posts rather than one per post, so it suits any list of posts, such as a
+// page from GetPage or SearchPosts.
+func (r *PostRepository) LoadCategories(posts []models.Post) error {
+	for start := 0; start < len(posts); start += categoriesPerQuery {
+		batch := posts[start:min(start+categoriesPerQuery, len(posts))]
+		ids := make([]interface{}, len(batch))
+		for i, post := range batch {
+			ids[i] = post.ID
+		}
+		byPost, err := r.categoriesOf(ids)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			batch[i].Categories = byPost[batch[i].ID]
+			if batch[i].Categories == nil {
+				batch[i].Categories = make([]models.Category, 0)
+			}
+		}
+	}
+	return nil
+}
+
+// categoriesOf returns the categories of the posts with postIDs, keyed by
+// post ID
+func (r *PostRepository) categoriesOf(postIDs []interface{}) (map[int][]models.Category, error) {
+	rows, err := r.db.QueryContext(r.ctx, r.dialect.Rebind(
+		`SELECT pc.post_id, c.id, c.parent_id, c.name, c.description, c.color, c.active, c.created_at, c.updated_at
+		FROM post_categories pc JOIN categories c ON c.id = pc.category_id AND c.deleted_at IS NULL
+		WHERE pc.post_id IN (`+placeholders(len(postIDs))+`)
+		ORDER BY c.name`),
+		postIDs...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPost := make(map[int][]models.Category)
+	for rows.Next() {
+		var postID int
+		var c models.Category
+		var description, color sql.NullString
+		var parentID sql.NullInt64
+		if err := rows.Scan(&postID, &c.ID, &parentID, &c.Name, &description, &color, &c.Active, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := uint(parentID.Int64)
+			c.ParentID = &id
+		}
+		c.Description, c.Color = description.String, color.String
+		byPost[postID] = append(byPost[postID], c)
+	}
+	return byPost, rows.Err()
+}
+
+// uniqueCategoryIDs drops repeated IDs, keeping the first of each, as
+// query arguments
+func uniqueCategoryIDs(ids []uint) []interface{} {
+	seen := make(map[uint]bool, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	return args
+}
+
+// placeholders returns n comma-separated placeholders for an IN list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
 }
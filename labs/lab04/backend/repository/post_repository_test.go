@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"lab04-backend/models"
+)
+
+func TestPostRepository(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewPostRepository(db)
+
+	alice, err := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	draft, err := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Draft post"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if draft.ID == 0 || draft.CreatedAt.IsZero() {
+		t.Errorf("Expected an ID and timestamps, got %+v", draft)
+	}
+	published, err := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Published post", Content: "Hello", Published: true})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Empty", Published: true}); !errors.Is(err, models.ErrContentRequired) {
+		t.Errorf("Expected ErrContentRequired, got %v", err)
+	}
+
+	got, err := repo.GetByID(published.ID)
+	if err != nil || got.Content != "Hello" || !got.Published {
+		t.Errorf("GetByID() = %+v, %v", got, err)
+	}
+	if _, err := repo.GetByID(9999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	// Rows written outside the repository may have no content at all
+	if _, err := db.Exec(`UPDATE posts SET content = NULL WHERE id = ?`, draft.ID); err != nil {
+		t.Fatalf("Failed to clear content: %v", err)
+	}
+	if got, err := repo.GetByID(draft.ID); err != nil || got.Content != "" {
+		t.Errorf("Expected NULL content to read as empty, got %+v, %v", got, err)
+	}
+
+	all, _ := repo.GetAll()
+	if len(all) != 2 || all[0].ID != published.ID {
+		t.Errorf("Expected both posts newest first, got %+v", all)
+	}
+	byUser, _ := repo.GetByUserID(alice.ID)
+	if len(byUser) != 2 {
+		t.Errorf("Expected 2 posts for the user, got %d", len(byUser))
+	}
+	onlyPublished, _ := repo.GetPublished()
+	if len(onlyPublished) != 1 || onlyPublished[0].ID != published.ID {
+		t.Errorf("Expected only the published post, got %+v", onlyPublished)
+	}
+
+	// The draft has no content, so it cannot be published as it is
+	title, content, empty := "Renamed post", "Now with content", " "
+	publish := true
+	if _, err := repo.Update(draft.ID, &models.UpdatePostRequest{Published: &publish}); !errors.Is(err, models.ErrContentRequired) {
+		t.Errorf("Expected ErrContentRequired publishing an empty post, got %v", err)
+	}
+	if got, _ := repo.GetByID(draft.ID); got.Published {
+		t.Error("Expected the rejected update to leave the draft unpublished")
+	}
+	if _, err := repo.Update(published.ID, &models.UpdatePostRequest{Content: &empty}); !errors.Is(err, models.ErrContentRequired) {
+		t.Errorf("Expected ErrContentRequired emptying a published post, got %v", err)
+	}
+	updated, err := repo.Update(draft.ID, &models.UpdatePostRequest{Title: &title, Content: &content, Published: &publish})
+	if err != nil || updated.Title != title || !updated.Published || !updated.UpdatedAt.After(draft.UpdatedAt) {
+		t.Errorf("Update() = %+v, %v", updated, err)
+	}
+	if _, err := repo.Update(9999, &models.UpdatePostRequest{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows updating a missing post, got %v", err)
+	}
+
+	if n, _ := repo.CountByUserID(alice.ID); n != 2 {
+		t.Errorf("Expected 2 posts by the user, got %d", n)
+	}
+	if err := repo.Delete(draft.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := repo.Delete(draft.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+	if n, _ := repo.Count(); n != 1 {
+		t.Errorf("Expected 1 post after delete, got %d", n)
+	}
+}
+
+func TestPostCategories(t *testing.T) {
+	categoryRepo, db := setupCategoryRepo(t)
+	repo := NewPostRepository(db).WithActor("editor")
+
+	alice, err := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	first, _ := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "First post"})
+	second, _ := repo.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Second post"})
+	if first == nil || second == nil {
+		t.Fatal("Failed to create test posts")
+	}
+	science := &models.Category{Name: "Science", Description: "All of it"}
+	art := &models.Category{Name: "Art"}
+	retired := &models.Category{Name: "Retired"}
+	for _, category := range []*models.Category{science, art, retired} {
+		if err := categoryRepo.Create(category); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	physics := &models.Category{Name: "Physics", ParentID: &science.ID}
+	if err := categoryRepo.Create(physics); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if err := categoryRepo.Delete(retired.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	t.Run("Assign", func(t *testing.T) {
+		if err := repo.AssignCategories(first.ID, science.ID, physics.ID, art.ID); err != nil {
+			t.Fatalf("AssignCategories() failed: %v", err)
+		}
+		// Assigning again is not an error
+		if err := repo.AssignCategories(first.ID, science.ID); err != nil {
+			t.Errorf("Expected reassigning to be skipped, got %v", err)
+		}
+
+		categories, err := repo.GetCategories(first.ID)
+		if err != nil || categoryNames(categories) != "Art,Physics,Science" {
+			t.Fatalf("Expected the categories by name, got %s, %v", categoryNames(categories), err)
+		}
+		if got := categories[2]; got.ID != science.ID || got.Description != "All of it" || got.Color != models.DefaultCategoryColor || !got.Active {
+			t.Errorf("Expected every column of the category, got %+v", got)
+		}
+		if got := categories[1].ParentID; got == nil || *got != science.ID {
+			t.Errorf("Expected Physics to keep its parent, got %v", got)
+		}
+
+		for _, id := range []uint{retired.ID, 9999} {
+			if err := repo.AssignCategories(second.ID, art.ID, id); !errors.Is(err, ErrCategoryNotFound) {
+				t.Errorf("Expected ErrCategoryNotFound for category %d, got %v", id, err)
+			}
+		}
+		if categories, _ := repo.GetCategories(second.ID); len(categories) != 0 {
+			t.Errorf("Expected a failed assignment to assign nothing, got %s", categoryNames(categories))
+		}
+		if err := repo.AssignCategories(9999, art.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows for a missing post, got %v", err)
+		}
+	})
+
+	t.Run("Unassign", func(t *testing.T) {
+		if err := repo.UnassignCategories(first.ID, art.ID, retired.ID); err != nil {
+			t.Fatalf("UnassignCategories() failed: %v", err)
+		}
+		if categories, _ := repo.GetCategories(first.ID); categoryNames(categories) != "Physics,Science" {
+			t.Errorf("Expected Art to be removed, got %s", categoryNames(categories))
+		}
+		if err := repo.UnassignCategories(9999, art.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows for a missing post, got %v", err)
+		}
+	})
+
+	t.Run("Audited as post updates", func(t *testing.T) {
+		history, err := NewAuditRepository(db).History("posts", int64(first.ID))
+		if err != nil {
+			t.Fatalf("History() failed: %v", err)
+		}
+		// Create, assign and unassign; reassigning changed nothing
+		if got := auditOperations(history); got != "create,update,update" {
+			t.Fatalf("Unexpected operations %s", got)
+		}
+		before, after := auditChange(t, history[2], "category_ids")
+		if want := jsonIDs(science.ID, art.ID, physics.ID); before != want {
+			t.Errorf("Expected %s before, got %s", want, before)
+		}
+		if want := jsonIDs(science.ID, physics.ID); after != want || history[2].Actor != "editor" {
+			t.Errorf("Expected %s after by editor, got %s by %s", want, after, history[2].Actor)
+		}
+	})
+
+	t.Run("Preloaded", func(t *testing.T) {
+		post, err := repo.GetByIDWithCategories(first.ID)
+		if err != nil || categoryNames(post.Categories) != "Physics,Science" {
+			t.Errorf("GetByIDWithCategories() = %+v, %v", post, err)
+		}
+
+		all, err := repo.GetAllWithCategories()
+		if err != nil || len(all) != 2 {
+			t.Fatalf("GetAllWithCategories() = %+v, %v", all, err)
+		}
+		if all[0].ID != second.ID || all[0].Categories == nil || len(all[0].Categories) != 0 {
+			t.Errorf("Expected the uncategorised post with an empty list, got %+v", all[0])
+		}
+		if categoryNames(all[1].Categories) != "Physics,Science" {
+			t.Errorf("Expected the first post's categories, got %s", categoryNames(all[1].Categories))
+		}
+
+		// Deleted categories are left out
+		if err := categoryRepo.Move(physics.ID, nil); err != nil {
+			t.Fatalf("Move() failed: %v", err)
+		}
+		if err := categoryRepo.Delete(physics.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		page, _ := repo.GetPage("", 10)
+		if err := repo.LoadCategories(page.Posts); err != nil {
+			t.Fatalf("LoadCategories() failed: %v", err)
+		}
+		if categoryNames(page.Posts[1].Categories) != "Science" {
+			t.Errorf("Expected the deleted category to be left out, got %s", categoryNames(page.Posts[1].Categories))
+		}
+	})
+
+	t.Run("More categories than one query takes", func(t *testing.T) {
+		many := make([]models.Category, categoriesPerQuery+10)
+		for i := range many {
+			many[i].Name = fmt.Sprintf("Topic %d", i)
+		}
+		if err := categoryRepo.CreateWithTransaction(many); err != nil {
+			t.Fatalf("CreateWithTransaction() failed: %v", err)
+		}
+		ids := make([]uint, len(many))
+		for i, category := range many {
+			ids[i] = category.ID
+		}
+
+		// The missing category is in the last batch
+		if err := repo.AssignCategories(second.ID, append(ids, 99999)...); !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+		}
+		if err := repo.AssignCategories(second.ID, ids...); err != nil {
+			t.Fatalf("AssignCategories() failed: %v", err)
+		}
+		if categories, _ := repo.GetCategories(second.ID); len(categories) != len(ids) {
+			t.Errorf("Expected %d categories, got %d", len(ids), len(categories))
+		}
+		if err := repo.UnassignCategories(second.ID, ids...); err != nil {
+			t.Fatalf("UnassignCategories() failed: %v", err)
+		}
+		if categories, _ := repo.GetCategories(second.ID); len(categories) != 0 {
+			t.Errorf("Expected every category to be removed, got %d", len(categories))
+		}
+	})
+}
+
+// jsonIDs encodes category IDs as the audit log records them
+func jsonIDs(ids ...uint) string {
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	data, _ := json.Marshal(sorted)
+	return string(data)
+}
@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"strings"
+	"sync/atomic"
 
+	"lab04-backend/database"
 	"lab04-backend/models"
 
 	"github.com/Masterminds/squirrel"
@@ -13,151 +16,447 @@ import (
 // SearchService handles dynamic search operations using Squirrel query builder
 // This service demonstrates SQUIRREL QUERY BUILDER approach for dynamic SQL
 type SearchService struct {
-	db   *sql.DB
-	psql squirrel.StatementBuilderType
+	db        *sql.DB
+	dialect   database.Dialect
+	builder   squirrel.StatementBuilderType
+	cursorKey []byte
+	fullText  atomic.Bool // Whether posts_fts was usable at the last check
 }
 
 // SearchFilters represents search parameters
 type SearchFilters struct {
-	Query        string // Search in title and content
-	UserID       *int   // Filter by user ID
-	Published    *bool  // Filter by published status
-	MinWordCount *int   // Minimum word count in content
-	Limit        int    // Results limit (default 50)
-	Offset       int    // Results offset (for pagination)
-	OrderBy      string // Order by field (title, created_at, updated_at)
-	OrderDir     string // Order direction (ASC, DESC)
+	Query         string // Search in title and content: all words must match, "quoted phrases" match exactly and word* matches a prefix
+	UserID        *int   // Filter by user ID
+	Published     *bool  // Filter by published status
+	MinWordCount  *int   // Minimum word count in content
+	CategoryIDs   []uint // Filter by categories the posts are filed under directly, not through subcategories; at most 500
+	CategoryMatch string // Posts in any of CategoryIDs (default) or in all of them: "any" or "all"
+	Limit         int    // Results limit (default 50)
+	Offset        int    // Results offset (for pagination)
+	Cursor        string // Position from a previous page's NextCursor (cannot be combined with Offset)
+	OrderBy       string // Order by field (title, created_at, updated_at)
+	OrderDir      string // Order direction (ASC, DESC)
 }
 
-// NewSearchService creates a new SearchService
+// defaultSearchLimit applies when a search does not set a limit
+const defaultSearchLimit = 50
+
+// Search errors
+var (
+	ErrInvalidOrderBy       = errors.New("order by must be title, created_at or updated_at")
+	ErrInvalidOrderDir      = errors.New("order direction must be ASC or DESC")
+	ErrQueryRequired        = errors.New("a search query is required")
+	ErrCursorRanked         = errors.New("ranked results are paged with offset, not cursors")
+	ErrInvalidCategoryMatch = errors.New("category match must be any or all")
+	ErrTooManyCategories    = errors.New("at most 500 categories can be filtered on")
+)
+
+// postSortColumns whitelists the columns posts can be ordered by, since
+// ORDER BY cannot take a placeholder
+var postSortColumns = map[string]bool{
+	"title":      true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// NewSearchService creates a new SearchService using the placeholders of
+// db's dialect
 func NewSearchService(db *sql.DB) *SearchService {
+	dialect := database.DialectOf(db)
 	return &SearchService{
-		db:   db,
-		psql: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		db:      db,
+		dialect: dialect,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(dialect.Placeholders()),
 	}
 }
 
-// TODO: Implement SearchPosts method using Squirrel query builder
+// SetCursorKey sets the key page cursors are signed with. Share one key
+// between instances and restarts for cursors to stay valid; without one a
+// random per-process key is used.
+func (s *SearchService) SetCursorKey(key []byte) {
+	s.cursorKey = key
+}
+
+// SearchPosts returns the posts matching filters, leaving out deleted ones.
+// Without an order they come newest first.
 func (s *SearchService) SearchPosts(ctx context.Context, filters SearchFilters) ([]models.Post, error) {
-	// TODO: Build dynamic query using Squirrel instead of string concatenation
-	//
-	// Start with base query:
-	// query := s.psql.Select("id", "user_id", "title", "content", "published", "created_at", "updated_at").
-	//              From("posts")
-	//
-	// Add WHERE conditions dynamically:
-	// - If filters.Query: add ILIKE conditions for title and content
-	// - If filters.UserID: add user_id = ?
-	// - If filters.Published: add published = ?
-	// - If filters.MinWordCount: add word count condition
-	//
-	// Add ORDER BY dynamically:
-	// - Use OrderBy() and validate sort fields
-	//
-	// Add LIMIT/OFFSET:
-	// - Use Limit() and Offset()
-	//
-	// Build final SQL:
-	// sql, args, err := query.ToSql()
-	//
-	// Execute with scany:
-	// var posts []models.Post
-	// err = sqlscan.Select(ctx, s.db, &posts, sql, args...)
-	//
-	// This demonstrates the power of combining Squirrel (dynamic queries)
-	// with scany (automatic result mapping)
-
-	return nil, fmt.Errorf("TODO: implement SearchPosts with Squirrel query builder")
-}
-
-// TODO: Implement SearchUsers method using Squirrel
-func (s *SearchService) SearchUsers(ctx context.Context, nameQuery string, limit int) ([]models.User, error) {
-	// TODO: Build user search query with Squirrel
-	// query := s.psql.Select("id", "name", "email", "created_at", "updated_at").
-	//              From("users").
-	//              Where(squirrel.Like{"name": "%" + nameQuery + "%"}).
-	//              OrderBy("name").
-	//              Limit(uint64(limit))
-	//
-	// sql, args, err := query.ToSql()
-	// var users []models.User
-	// err = sqlscan.Select(ctx, s.db, &users, sql, args...)
+	page, err := s.SearchPostsPage(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return page.Posts, nil
+}
+
+// SearchPostsPage is SearchPosts with a cursor for the next page. A cursor
+// only continues the ordering it was issued for.
+func (s *SearchService) SearchPostsPage(ctx context.Context, filters SearchFilters) (*PostPage, error) {
+	orderBy := filters.OrderBy
+	if orderBy == "" {
+		orderBy = "created_at"
+	}
+	if !postSortColumns[orderBy] {
+		return nil, ErrInvalidOrderBy
+	}
+	orderDir := strings.ToUpper(filters.OrderDir)
+	switch orderDir {
+	case "":
+		orderDir = "DESC"
+	case "ASC", "DESC":
+	default:
+		return nil, ErrInvalidOrderDir
+	}
+	if err := validateCategoryFilter(filters); err != nil {
+		return nil, err
+	}
+
+	if err := s.detectFullText(ctx); err != nil {
+		return nil, err
+	}
+	limit := limitOrDefault(filters.Limit)
+	base := s.builder.Select(models.PostColumns).From("posts").Where("deleted_at IS NULL")
+	query := s.BuildDynamicQuery(base, filters).
+		// id breaks ties so pages do not overlap
+		OrderBy(orderBy+" "+orderDir, "id "+orderDir).
+		Limit(uint64(limit + 1))
+	if filters.Cursor != "" {
+		if filters.Offset > 0 {
+			return nil, ErrCursorWithOffset
+		}
+		c, err := decodeCursor(s.cursorKey, filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.OrderBy != orderBy || c.OrderDir != orderDir {
+			return nil, ErrInvalidCursor
+		}
+		condition, args, err := c.keysetCondition()
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	} else if filters.Offset > 0 {
+		query = query.Offset(uint64(filters.Offset))
+	}
 
-	return nil, fmt.Errorf("TODO: implement SearchUsers with Squirrel")
+	rows, err := query.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := models.ScanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return newPostPage(s.cursorKey, posts, limit, orderBy, orderDir), nil
+}
+
+// SearchUsers returns users who are not deleted and whose name contains
+// nameQuery, ignoring case, ordered by name
+func (s *SearchService) SearchUsers(ctx context.Context, nameQuery string, limit int) ([]models.User, error) {
+	rows, err := s.builder.Select(models.UserColumns).
+		From("users").
+		Where("deleted_at IS NULL").
+		Where(s.containsExpr("name", nameQuery)).
+		OrderBy("name", "id").
+		Limit(uint64(limitOrDefault(limit))).
+		RunWith(s.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models.ScanUsers(rows)
 }
 
-// TODO: Implement GetPostStats method using Squirrel with JOINs
+// GetPostStats aggregates every post and its author, and counts the posts
+// in each category, leaving out deleted ones
 func (s *SearchService) GetPostStats(ctx context.Context) (*PostStats, error) {
-	// TODO: Build complex query with JOINs using Squirrel
-	// query := s.psql.Select(
-	//     "COUNT(p.id) as total_posts",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_posts",
-	//     "COUNT(DISTINCT p.user_id) as active_users",
-	//     "AVG(LENGTH(p.content)) as avg_content_length",
-	// ).From("posts p").
-	//   Join("users u ON p.user_id = u.id")
-	//
-	// This shows how Squirrel handles complex queries better than string building
+	var stats PostStats
+	err := s.builder.Select(
+		"COUNT(p.id) AS total_posts",
+		"COUNT(CASE WHEN p.published THEN 1 END) AS published_posts",
+		"COUNT(DISTINCT p.user_id) AS active_users",
+		// Postgres averages integers as NUMERIC, which does not scan into a float64
+		"COALESCE(CAST(AVG(LENGTH(p.content)) AS DOUBLE PRECISION), 0) AS avg_content_length",
+	).From("posts p").
+		Join("users u ON p.user_id = u.id").
+		Where("p.deleted_at IS NULL AND u.deleted_at IS NULL").
+		RunWith(s.db).
+		QueryRowContext(ctx).
+		Scan(&stats.TotalPosts, &stats.PublishedPosts, &stats.ActiveUsers, &stats.AvgContentLength)
+	if err != nil {
+		return nil, err
+	}
+	if stats.ByCategory, err = s.categoryStats(ctx); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
 
-	return nil, fmt.Errorf("TODO: implement GetPostStats with Squirrel JOINs")
+// categoryStats counts the posts filed directly under each category, most
+// posts first, including categories with none
+func (s *SearchService) categoryStats(ctx context.Context) ([]CategoryStats, error) {
+	rows, err := s.builder.Select(
+		"c.id",
+		"c.name",
+		"COUNT(p.id) AS post_count",
+		"COUNT(CASE WHEN p.published THEN 1 END) AS published_count",
+	).From("categories c").
+		LeftJoin("post_categories pc ON pc.category_id = c.id").
+		// Filtering posts in the join keeps categories whose posts are all deleted
+		LeftJoin("posts p ON p.id = pc.post_id AND p.deleted_at IS NULL AND p.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)").
+		Where("c.deleted_at IS NULL").
+		GroupBy("c.id", "c.name").
+		OrderBy("post_count DESC", "c.name").
+		RunWith(s.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]CategoryStats, 0)
+	for rows.Next() {
+		var c CategoryStats
+		if err := rows.Scan(&c.CategoryID, &c.Name, &c.PostCount, &c.PublishedCount); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
 }
 
 // PostStats represents aggregated post statistics
 type PostStats struct {
-	TotalPosts       int     `db:"total_posts"`
-	PublishedPosts   int     `db:"published_posts"`
-	ActiveUsers      int     `db:"active_users"`
-	AvgContentLength float64 `db:"avg_content_length"`
+	TotalPosts       int             `db:"total_posts"`
+	PublishedPosts   int             `db:"published_posts"`
+	ActiveUsers      int             `db:"active_users"`
+	AvgContentLength float64         `db:"avg_content_length"`
+	ByCategory       []CategoryStats // Every category, most posts first; CategoryRepository.GetPostCounts also counts subcategories
 }
 
-// TODO: Implement BuildDynamicQuery helper method
+// CategoryStats counts the posts filed directly under a category
+type CategoryStats struct {
+	CategoryID     uint   `db:"category_id"`
+	Name           string `db:"name"`
+	PostCount      int    `db:"post_count"`
+	PublishedCount int    `db:"published_count"`
+}
+
+// BuildDynamicQuery adds a WHERE condition to baseQuery for each filter
+// that is set. Ordering and paging are left to the caller.
+//
+// The query text is matched through the posts_fts full-text index when
+// there is one, and with LIKE otherwise.
 func (s *SearchService) BuildDynamicQuery(baseQuery squirrel.SelectBuilder, filters SearchFilters) squirrel.SelectBuilder {
-	// TODO: Demonstrate how to build queries step by step with Squirrel
-	//
-	// query := baseQuery
-	//
-	// if filters.Query != "" {
-	//     searchTerm := "%" + filters.Query + "%"
-	//     query = query.Where(squirrel.Or{
-	//         squirrel.ILike{"title": searchTerm},
-	//         squirrel.ILike{"content": searchTerm},
-	//     })
-	// }
-	//
-	// if filters.UserID != nil {
-	//     query = query.Where(squirrel.Eq{"user_id": *filters.UserID})
-	// }
-	//
-	// if filters.Published != nil {
-	//     query = query.Where(squirrel.Eq{"published": *filters.Published})
-	// }
-	//
-	// This modular approach makes dynamic queries much cleaner
-	// than string concatenation used in manual SQL approaches
-
-	return baseQuery
-}
-
-// TODO: Implement GetTopUsers method using Squirrel with complex aggregation
+	query := baseQuery
+
+	if terms := parseSearchQuery(filters.Query); len(terms) > 0 {
+		if s.fullText.Load() {
+			query = query.Where("id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH ?)", ftsMatch(terms))
+		} else {
+			query = query.Where(s.containsAll("", terms))
+		}
+	}
+	for _, condition := range s.filterConditions("", filters) {
+		query = query.Where(condition)
+	}
+
+	return query
+}
+
+// filterConditions are the conditions for every filter except the query
+// text. table qualifies the column names, for queries that join posts.
+func (s *SearchService) filterConditions(table string, filters SearchFilters) []squirrel.Sqlizer {
+	var conditions []squirrel.Sqlizer
+	if filters.UserID != nil {
+		conditions = append(conditions, squirrel.Eq{table + "user_id": *filters.UserID})
+	}
+	if filters.Published != nil {
+		conditions = append(conditions, squirrel.Eq{table + "published": *filters.Published})
+	}
+	if filters.MinWordCount != nil {
+		conditions = append(conditions, squirrel.Expr(s.wordCountExpr(table+"content")+" >= ?", *filters.MinWordCount))
+	}
+	if ids := uniqueCategoryIDs(filters.CategoryIDs); len(ids) > 0 {
+		conditions = append(conditions, categoryCondition(table, ids, strings.EqualFold(filters.CategoryMatch, "all")))
+	}
+	return conditions
+}
+
+// categoryCondition matches posts filed under any of the categories, or
+// under all of them. Deleted categories match no posts. The IDs form one
+// IN list in the search's statement, so unlike AssignCategories they cannot
+// be sent in batches; validateCategoryFilter caps them at
+// categoriesPerQuery instead.
+func categoryCondition(table string, ids []interface{}, all bool) squirrel.Sqlizer {
+	subquery := `SELECT pc.post_id FROM post_categories pc
+		JOIN categories c ON c.id = pc.category_id AND c.deleted_at IS NULL
+		WHERE pc.category_id IN (` + placeholders(len(ids)) + `)`
+	args := ids
+	if all {
+		subquery += ` GROUP BY pc.post_id HAVING COUNT(pc.category_id) = ?`
+		args = append(args[:len(args):len(args)], len(ids))
+	}
+	return squirrel.Expr(table+"id IN ("+subquery+")", args...)
+}
+
+// validateCategoryFilter accepts a CategoryMatch of "any", "all" or unset,
+// in any case, and up to categoriesPerQuery distinct CategoryIDs
+func validateCategoryFilter(filters SearchFilters) error {
+	switch strings.ToLower(filters.CategoryMatch) {
+	case "", "any", "all":
+	default:
+		return ErrInvalidCategoryMatch
+	}
+	if len(uniqueCategoryIDs(filters.CategoryIDs)) > categoriesPerQuery {
+		return ErrTooManyCategories
+	}
+	return nil
+}
+
+// PostHit is a post matched by a search query
+type PostHit struct {
+	models.Post
+	Score            float64 // Relevance, higher is better; 0 without a full-text index
+	HighlightedTitle string  // Title with matched terms in <mark> tags
+	Snippet          string  // Extract of the content around the matches, with <mark> tags
+}
+
+// SearchPostHits returns the posts matching filters.Query, most relevant
+// first, with highlighted titles and snippets. Relevance is FTS5's bm25,
+// weighting title matches above content matches. Without a full-text index
+// the hits are matched with LIKE and come newest first, highlighted in Go.
+//
+// Results are paged with Limit and Offset: relevance shifts as posts are
+// added, so it cannot anchor a cursor. OrderBy and OrderDir are ignored.
+func (s *SearchService) SearchPostHits(ctx context.Context, filters SearchFilters) ([]PostHit, error) {
+	terms := parseSearchQuery(filters.Query)
+	if len(terms) == 0 {
+		return nil, ErrQueryRequired
+	}
+	if filters.Cursor != "" {
+		return nil, ErrCursorRanked
+	}
+	if err := validateCategoryFilter(filters); err != nil {
+		return nil, err
+	}
+	if err := s.detectFullText(ctx); err != nil {
+		return nil, err
+	}
+	if !s.fullText.Load() {
+		return s.searchPostHitsWithoutIndex(ctx, filters, terms)
+	}
+
+	columns := strings.Split(models.PostColumns, ", ")
+	for i, column := range columns {
+		columns[i] = "p." + column
+	}
+	query := s.builder.Select(columns...).
+		Column("-bm25(posts_fts, 10.0, 1.0) AS score").
+		Column("highlight(posts_fts, 0, ?, ?)", highlightOpen, highlightClose).
+		Column("COALESCE(snippet(posts_fts, 1, ?, ?, ?, ?), '')", highlightOpen, highlightClose, snippetEllipsis, snippetTokens).
+		From("posts_fts").
+		Join("posts p ON p.id = posts_fts.rowid").
+		Where("posts_fts MATCH ?", ftsMatch(terms)).
+		Where("p.deleted_at IS NULL")
+	for _, condition := range s.filterConditions("p.", filters) {
+		query = query.Where(condition)
+	}
+	query = query.OrderBy("score DESC", "p.id").Limit(uint64(limitOrDefault(filters.Limit)))
+	if filters.Offset > 0 {
+		query = query.Offset(uint64(filters.Offset))
+	}
+
+	rows, err := query.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := make([]PostHit, 0)
+	for rows.Next() {
+		var hit PostHit
+		var content sql.NullString
+		p := &hit.Post
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &content, &p.Published, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+			&hit.Score, &hit.HighlightedTitle, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		p.Content = content.String
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// searchPostHitsWithoutIndex is SearchPostHits over LIKE matches
+func (s *SearchService) searchPostHitsWithoutIndex(ctx context.Context, filters SearchFilters, terms []searchTerm) ([]PostHit, error) {
+	filters.OrderBy, filters.OrderDir = "", ""
+	posts, err := s.SearchPosts(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]PostHit, len(posts))
+	for i, post := range posts {
+		hits[i] = PostHit{
+			Post:             post,
+			HighlightedTitle: highlightTerms(post.Title, terms),
+			Snippet:          snippetAround(post.Content, terms),
+		}
+	}
+	return hits, nil
+}
+
+// detectFullText checks whether the posts_fts index can be used. Every
+// search checks again, since the index can appear after the service is made
+// and lose its triggers when a build without FTS5 opens the database.
+func (s *SearchService) detectFullText(ctx context.Context) error {
+	if s.dialect != database.SQLite {
+		return nil
+	}
+	ok, err := database.HasFullTextIndex(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	s.fullText.Store(ok)
+	return nil
+}
+
+// GetTopUsers returns the users with the most posts, including those with
+// none. Deleted users and posts are left out.
 func (s *SearchService) GetTopUsers(ctx context.Context, limit int) ([]UserWithStats, error) {
-	// TODO: Build complex aggregation query with Squirrel
-	// query := s.psql.Select(
-	//     "u.id",
-	//     "u.name",
-	//     "u.email",
-	//     "COUNT(p.id) as post_count",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_count",
-	//     "MAX(p.created_at) as last_post_date",
-	// ).From("users u").
-	//   LeftJoin("posts p ON u.id = p.user_id").
-	//   GroupBy("u.id", "u.name", "u.email").
-	//   OrderBy("post_count DESC").
-	//   Limit(uint64(limit))
-	//
-	// Notice how Squirrel makes complex queries more readable
-	// compared to building SQL strings manually
-
-	return nil, fmt.Errorf("TODO: implement GetTopUsers with Squirrel aggregation")
+	rows, err := s.builder.Select(
+		"u.id",
+		"u.name",
+		"u.email",
+		"u.created_at",
+		"u.updated_at",
+		"COUNT(p.id) AS post_count",
+		"COUNT(CASE WHEN p.published THEN 1 END) AS published_count",
+		"COALESCE(CAST(MAX(p.created_at) AS TEXT), '') AS last_post_date",
+	).From("users u").
+		// Filtering posts in the join keeps users whose posts are all deleted
+		LeftJoin("posts p ON u.id = p.user_id AND p.deleted_at IS NULL").
+		Where("u.deleted_at IS NULL").
+		GroupBy("u.id", "u.name", "u.email", "u.created_at", "u.updated_at").
+		OrderBy("post_count DESC", "u.id").
+		Limit(uint64(limitOrDefault(limit))).
+		RunWith(s.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]UserWithStats, 0)
+	for rows.Next() {
+		var u UserWithStats
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt,
+			&u.PostCount, &u.PublishedCount, &u.LastPostDate); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
 }
 
 // UserWithStats represents a user with post statistics
@@ -167,3 +466,44 @@ type UserWithStats struct {
 	PublishedCount int    `db:"published_count"`
 	LastPostDate   string `db:"last_post_date"`
 }
+
+// containsAll matches rows whose title or content contains every term,
+// ignoring case
+func (s *SearchService) containsAll(table string, terms []searchTerm) squirrel.Sqlizer {
+	all := squirrel.And{}
+	for _, term := range terms {
+		all = append(all, squirrel.Or{
+			s.containsExpr(table+"title", term.text),
+			s.containsExpr(table+"content", term.text),
+		})
+	}
+	return all
+}
+
+// containsExpr matches rows where column contains text, ignoring case.
+// LIKE wildcards in text are escaped so they match literally.
+func (s *SearchService) containsExpr(column, text string) squirrel.Sqlizer {
+	pattern := "%" + likeEscaper.Replace(text) + "%"
+	return squirrel.Expr(column+" "+s.dialect.CaseInsensitiveLike()+` ? ESCAPE '\'`, pattern)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// wordCountExpr counts the whitespace-separated words in column. Postgres
+// can split on any run of whitespace; SQLite has no regular expressions, so
+// it counts single spaces.
+func (s *SearchService) wordCountExpr(column string) string {
+	if s.dialect == database.Postgres {
+		return "COALESCE(array_length(regexp_split_to_array(NULLIF(btrim(" + column + `), ''), '\s+'), 1), 0)`
+	}
+	trimmed := "TRIM(COALESCE(" + column + ", ''))"
+	return "CASE WHEN " + trimmed + " = '' THEN 0 ELSE LENGTH(" + trimmed + ") - LENGTH(REPLACE(" + trimmed + ", ' ', '')) + 1 END"
+}
+
+// limitOrDefault applies the default search limit to an unset limit
+func limitOrDefault(limit int) int {
+	if limit <= 0 {
+		return defaultSearchLimit
+	}
+	return limit
+}
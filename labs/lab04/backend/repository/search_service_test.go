@@ -1,110 +1,280 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/Masterminds/squirrel"
 )
 
 // TestSearchService tests the Squirrel query builder approach
 func TestSearchService(t *testing.T) {
-	// Initialize database for testing
-	db, err := database.InitDB()
+	db := openTestDB(t)
+	searchService := NewSearchService(db)
+	ctx := context.Background()
+
+	// An empty database still aggregates
+	stats, err := searchService.GetPostStats(ctx)
 	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
+		t.Fatalf("GetPostStats() on empty tables failed: %v", err)
+	}
+	if !reflect.DeepEqual(*stats, PostStats{ByCategory: []CategoryStats{}}) {
+		t.Errorf("Expected zero stats for empty tables, got %+v", stats)
 	}
-	defer database.CloseDB(db)
 
-	// Run migrations
-	if err := database.RunMigrations(db); err != nil {
-		t.Fatalf("Failed to run migrations: %v", err)
+	users := NewUserRepository(db)
+	alice, _ := users.Create(&models.CreateUserRequest{Name: "Alice Smith", Email: "alice@example.com"})
+	bob, _ := users.Create(&models.CreateUserRequest{Name: "Bob Jones", Email: "bob@example.com"})
+	carol, _ := users.Create(&models.CreateUserRequest{Name: "Carol Smithers", Email: "carol@example.com"})
+	if alice == nil || bob == nil || carol == nil {
+		t.Fatal("Failed to create test users")
 	}
 
-	// Create service instance
-	searchService := NewSearchService(db)
+	base := time.Now().Add(-time.Hour)
+	insertPost := func(userID int, title, content string, published bool, age int) {
+		t.Helper()
+		at := base.Add(time.Duration(age) * time.Minute)
+		_, err := db.Exec(database.DialectOf(db).Rebind(
+			`INSERT INTO posts (user_id, title, content, published, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`),
+			userID, title, content, published, at, at)
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+	insertPost(alice.ID, "Learning Golang", "Go is a simple language", true, 1)
+	insertPost(alice.ID, "Draft about SQL", "Queries and more queries here", false, 2)
+	insertPost(alice.ID, "100% coverage", "Aiming for full test coverage", true, 3)
+	insertPost(bob.ID, "Golang tips", "Short", true, 4)
 
-	// TODO: Test SearchPosts with various filters
 	t.Run("SearchPosts with filters", func(t *testing.T) {
-		// TODO: Test dynamic query building with Squirrel
-		// - Test empty filters (should return all posts)
-		// - Test search by query string
-		// - Test filter by user ID
-		// - Test filter by published status
-		// - Test pagination (limit/offset)
-		// - Test sorting (order by different fields)
-		//
-		// Example test structure:
-		// filters := SearchFilters{
-		//     Query: "golang",
-		//     Published: &[]bool{true}[0],
-		//     Limit: 10,
-		//     OrderBy: "created_at",
-		//     OrderDir: "DESC",
-		// }
-		// posts, err := searchService.SearchPosts(context.Background(), filters)
-		// assert.NoError(t, err)
-		// assert.LessOrEqual(t, len(posts), 10)
+		published := true
+		minWords := 4
+		tests := []struct {
+			name    string
+			filters SearchFilters
+			want    []string
+		}{
+			{"no filters, newest first", SearchFilters{},
+				[]string{"Golang tips", "100% coverage", "Draft about SQL", "Learning Golang"}},
+			{"query ignores case", SearchFilters{Query: "GOLANG", OrderDir: "asc"},
+				[]string{"Learning Golang", "Golang tips"}},
+			{"query matches content", SearchFilters{Query: "queries"},
+				[]string{"Draft about SQL"}},
+			{"wildcards match literally", SearchFilters{Query: "100%"},
+				[]string{"100% coverage"}},
+			{"user", SearchFilters{UserID: &bob.ID},
+				[]string{"Golang tips"}},
+			{"published and word count", SearchFilters{Published: &published, MinWordCount: &minWords},
+				[]string{"100% coverage", "Learning Golang"}},
+			{"ordered by title", SearchFilters{OrderBy: "title", OrderDir: "ASC"},
+				[]string{"100% coverage", "Draft about SQL", "Golang tips", "Learning Golang"}},
+			{"paged", SearchFilters{Limit: 2, Offset: 1},
+				[]string{"100% coverage", "Draft about SQL"}},
+		}
+		for _, tt := range tests {
+			posts, err := searchService.SearchPosts(ctx, tt.filters)
+			if err != nil {
+				t.Errorf("%s: SearchPosts() failed: %v", tt.name, err)
+				continue
+			}
+			if got := postTitles(posts); !slices.Equal(got, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			}
+		}
 
-		// Use searchService to avoid "declared and not used" error
-		_ = searchService
-		t.Skip("TODO: implement SearchPosts test with Squirrel filters")
+		if _, err := searchService.SearchPosts(ctx, SearchFilters{OrderBy: "id; DROP TABLE posts"}); !errors.Is(err, ErrInvalidOrderBy) {
+			t.Errorf("Expected ErrInvalidOrderBy, got %v", err)
+		}
+		if _, err := searchService.SearchPosts(ctx, SearchFilters{OrderDir: "sideways"}); !errors.Is(err, ErrInvalidOrderDir) {
+			t.Errorf("Expected ErrInvalidOrderDir, got %v", err)
+		}
 	})
 
-	// TODO: Test SearchUsers functionality
 	t.Run("SearchUsers", func(t *testing.T) {
-		// TODO: Test user search with Squirrel
-		// - Test exact name matches
-		// - Test partial name matches with LIKE
-		// - Test case insensitive search
-		// - Test limit functionality
-
-		_ = searchService
-		t.Skip("TODO: implement SearchUsers test with Squirrel")
+		found, err := searchService.SearchUsers(ctx, "smith", 0)
+		if err != nil {
+			t.Fatalf("SearchUsers() failed: %v", err)
+		}
+		if len(found) != 2 || found[0].Name != "Alice Smith" || found[1].Name != "Carol Smithers" {
+			t.Errorf("Expected Alice and Carol, got %+v", found)
+		}
+
+		found, _ = searchService.SearchUsers(ctx, "smith", 1)
+		if len(found) != 1 {
+			t.Errorf("Expected limit of 1, got %d users", len(found))
+		}
 	})
 
-	// TODO: Test GetPostStats with complex aggregation
 	t.Run("GetPostStats", func(t *testing.T) {
-		// TODO: Test complex aggregation query
-		// - Insert test data (users and posts)
-		// - Test aggregation calculations
-		// - Verify JOIN functionality
-		// - Test with no data (empty tables)
-
-		_ = searchService
-		t.Skip("TODO: implement GetPostStats test with Squirrel JOINs")
+		stats, err := searchService.GetPostStats(ctx)
+		if err != nil {
+			t.Fatalf("GetPostStats() failed: %v", err)
+		}
+		want := PostStats{TotalPosts: 4, PublishedPosts: 3, ActiveUsers: 2, AvgContentLength: (23 + 29 + 29 + 5) / 4.0, ByCategory: []CategoryStats{}}
+		if !reflect.DeepEqual(*stats, want) {
+			t.Errorf("Expected %+v, got %+v", want, *stats)
+		}
 	})
 
-	// TODO: Test GetTopUsers with aggregation and sorting
 	t.Run("GetTopUsers", func(t *testing.T) {
-		// TODO: Test user ranking with post statistics
-		// - Insert users with different post counts
-		// - Test ordering by post count
-		// - Test LEFT JOIN behavior (users with no posts)
-		// - Test limit functionality
-
-		_ = searchService
-		t.Skip("TODO: implement GetTopUsers test with Squirrel aggregation")
+		top, err := searchService.GetTopUsers(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetTopUsers() failed: %v", err)
+		}
+		if len(top) != 3 {
+			t.Fatalf("Expected users without posts to be included, got %d users", len(top))
+		}
+		if top[0].ID != alice.ID || top[0].PostCount != 3 || top[0].PublishedCount != 2 || top[0].LastPostDate == "" {
+			t.Errorf("Expected Alice first with 3 posts, got %+v", top[0])
+		}
+		if top[2].ID != carol.ID || top[2].PostCount != 0 || top[2].LastPostDate != "" {
+			t.Errorf("Expected Carol last with no posts, got %+v", top[2])
+		}
 	})
+}
 
-	// TODO: Test BuildDynamicQuery helper
-	t.Run("BuildDynamicQuery", func(t *testing.T) {
-		// TODO: Test query building step by step
-		// - Test with different filter combinations
-		// - Verify generated SQL syntax
-		// - Test parameter binding
-		//
-		// Example:
-		// baseQuery := searchService.psql.Select("*").From("posts")
-		// filters := SearchFilters{Query: "test", Published: &[]bool{true}[0]}
-		// query := searchService.BuildDynamicQuery(baseQuery, filters)
-		// sql, args, err := query.ToSql()
-		// assert.NoError(t, err)
-		// assert.Contains(t, sql, "WHERE")
-		// assert.Contains(t, sql, "published")
+func TestSearchPostsByCategory(t *testing.T) {
+	categoryRepo, db := setupCategoryRepo(t)
+	searchService := NewSearchService(db)
+	posts := NewPostRepository(db)
+	ctx := context.Background()
 
-		_ = searchService
-		t.Skip("TODO: implement BuildDynamicQuery test")
-	})
+	alice, err := NewUserRepository(db).Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	golang := &models.Category{Name: "Golang"}
+	databases := &models.Category{Name: "Databases"}
+	retired := &models.Category{Name: "Retired"}
+	for _, category := range []*models.Category{golang, databases, retired} {
+		if err := categoryRepo.Create(category); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	filed := map[string][]uint{
+		"Go generics":   {golang.ID},
+		"SQL from Go":   {golang.ID, databases.ID, retired.ID},
+		"Index tuning":  {databases.ID},
+		"Uncategorised": nil,
+	}
+	for _, title := range []string{"Go generics", "SQL from Go", "Index tuning", "Uncategorised"} {
+		post, err := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: title, Content: "Some content", Published: title != "Index tuning"})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		if err := posts.AssignCategories(post.ID, filed[title]...); err != nil {
+			t.Fatalf("AssignCategories() failed: %v", err)
+		}
+	}
+	if err := categoryRepo.Delete(retired.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    []string
+	}{
+		{"any category", SearchFilters{CategoryIDs: []uint{golang.ID, databases.ID}},
+			[]string{"Index tuning", "SQL from Go", "Go generics"}},
+		{"all categories", SearchFilters{CategoryIDs: []uint{golang.ID, databases.ID}, CategoryMatch: "ALL"},
+			[]string{"SQL from Go"}},
+		{"repeated IDs count once", SearchFilters{CategoryIDs: []uint{golang.ID, golang.ID}, CategoryMatch: "all"},
+			[]string{"SQL from Go", "Go generics"}},
+		{"deleted category matches nothing", SearchFilters{CategoryIDs: []uint{golang.ID, retired.ID}, CategoryMatch: "all"},
+			[]string{}},
+		{"combined with other filters", SearchFilters{CategoryIDs: []uint{databases.ID}, Query: "tuning"},
+			[]string{"Index tuning"}},
+	}
+	for _, tt := range tests {
+		found, err := searchService.SearchPosts(ctx, tt.filters)
+		if err != nil {
+			t.Errorf("%s: SearchPosts() failed: %v", tt.name, err)
+			continue
+		}
+		if got := postTitles(found); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	if _, err := searchService.SearchPosts(ctx, SearchFilters{CategoryIDs: []uint{golang.ID}, CategoryMatch: "most"}); !errors.Is(err, ErrInvalidCategoryMatch) {
+		t.Errorf("Expected ErrInvalidCategoryMatch, got %v", err)
+	}
+	tooMany := make([]uint, categoriesPerQuery+1)
+	for i := range tooMany {
+		tooMany[i] = uint(i + 1)
+	}
+	if _, err := searchService.SearchPosts(ctx, SearchFilters{CategoryIDs: tooMany}); !errors.Is(err, ErrTooManyCategories) {
+		t.Errorf("Expected ErrTooManyCategories, got %v", err)
+	}
+	if _, err := searchService.SearchPostHits(ctx, SearchFilters{Query: "go", CategoryIDs: tooMany}); !errors.Is(err, ErrTooManyCategories) {
+		t.Errorf("Expected ErrTooManyCategories from SearchPostHits, got %v", err)
+	}
+
+	hits, err := searchService.SearchPostHits(ctx, SearchFilters{Query: "go", CategoryIDs: []uint{databases.ID}})
+	if err != nil || len(hits) != 1 || hits[0].Title != "SQL from Go" {
+		t.Errorf("Expected the category to filter hits, got %+v, %v", hits, err)
+	}
+
+	stats, err := searchService.GetPostStats(ctx)
+	if err != nil {
+		t.Fatalf("GetPostStats() failed: %v", err)
+	}
+	want := []CategoryStats{
+		{CategoryID: databases.ID, Name: "Databases", PostCount: 2, PublishedCount: 1},
+		{CategoryID: golang.ID, Name: "Golang", PostCount: 2, PublishedCount: 2},
+	}
+	if !slices.Equal(stats.ByCategory, want) {
+		t.Errorf("Expected %+v, got %+v", want, stats.ByCategory)
+	}
+}
+
+func TestBuildDynamicQuery(t *testing.T) {
+	published := true
+	minWords := 3
+	userID := 7
+	filters := SearchFilters{Query: "go_lang", UserID: &userID, Published: &published, MinWordCount: &minWords}
+
+	for _, dialect := range []database.Dialect{database.SQLite, database.Postgres} {
+		s := &SearchService{
+			dialect: dialect,
+			builder: squirrel.StatementBuilder.PlaceholderFormat(dialect.Placeholders()),
+		}
+		query, args, err := s.BuildDynamicQuery(s.builder.Select("id").From("posts"), filters).ToSql()
+		if err != nil {
+			t.Fatalf("%s: ToSql() failed: %v", dialect, err)
+		}
+
+		wantArgs := []interface{}{`%go\_lang%`, `%go\_lang%`, 7, true, 3}
+		if !slices.Equal(args, wantArgs) {
+			t.Errorf("%s: expected args %v, got %v", dialect, wantArgs, args)
+		}
+		switch dialect {
+		case database.Postgres:
+			if !strings.Contains(query, "title ILIKE $1") || !strings.Contains(query, ">= $5") || strings.Contains(query, "?") {
+				t.Errorf("Expected numbered ILIKE placeholders, got %s", query)
+			}
+		default:
+			if !strings.Contains(query, "title LIKE ?") || strings.Contains(query, "$1") {
+				t.Errorf("Expected question mark LIKE placeholders, got %s", query)
+			}
+		}
+	}
+}
+
+func postTitles(posts []models.Post) []string {
+	titles := make([]string, len(posts))
+	for i, p := range posts {
+		titles[i] = p.Title
+	}
+	return titles
 }
 
 // TestSquirrelQueryBuilder tests Squirrel query building functionality
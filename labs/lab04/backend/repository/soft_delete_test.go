@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"lab04-backend/models"
+)
+
+func TestSoftDelete(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db)
+	posts := NewPostRepository(db)
+
+	alice, err := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	first, _ := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "First post", Content: "Hello", Published: true})
+	second, _ := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Second post"})
+	removed, _ := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Removed post"})
+	if first == nil || second == nil || removed == nil {
+		t.Fatal("Failed to create posts")
+	}
+
+	// A post deleted on its own
+	if err := posts.Delete(removed.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := posts.Delete(removed.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+	if _, err := posts.GetByID(removed.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a deleted post to be hidden, got %v", err)
+	}
+	if got, err := posts.GetByIDIncludingDeleted(removed.ID); err != nil || got.DeletedAt == nil {
+		t.Errorf("Expected the deleted post with its deletion time, got %+v, %v", got, err)
+	}
+	if n, _ := posts.CountByUserID(alice.ID); n != 2 {
+		t.Errorf("Expected 2 posts left, got %d", n)
+	}
+
+	// Deleting the user takes their remaining posts with them
+	if err := users.Delete(alice.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := users.GetByID(alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a deleted user to be hidden, got %v", err)
+	}
+	if _, err := users.GetByEmail(alice.Email); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a deleted user to be hidden by email, got %v", err)
+	}
+	if all, _ := users.GetAll(); len(all) != 0 {
+		t.Errorf("Expected no users listed, got %d", len(all))
+	}
+	if all, _ := posts.GetAll(); len(all) != 0 {
+		t.Errorf("Expected the user's posts to be deleted with them, got %d", len(all))
+	}
+	name := "Renamed"
+	if _, err := users.Update(alice.ID, &models.UpdateUserRequest{Name: &name}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows updating a deleted user, got %v", err)
+	}
+	if err := posts.Restore(first.ID); !errors.Is(err, ErrUserDeleted) {
+		t.Errorf("Expected ErrUserDeleted restoring a deleted user's post, got %v", err)
+	}
+
+	search := NewSearchService(db)
+	ctx := context.Background()
+	if found, _ := search.SearchPosts(ctx, SearchFilters{}); len(found) != 0 {
+		t.Errorf("Expected search to leave out deleted posts, got %d", len(found))
+	}
+	if found, _ := search.SearchUsers(ctx, "alice", 0); len(found) != 0 {
+		t.Errorf("Expected search to leave out deleted users, got %d", len(found))
+	}
+	if stats, _ := search.GetPostStats(ctx); stats.TotalPosts != 0 {
+		t.Errorf("Expected stats to leave out deleted posts, got %+v", stats)
+	}
+	if top, _ := search.GetTopUsers(ctx, 10); len(top) != 0 {
+		t.Errorf("Expected top users to leave out deleted users, got %+v", top)
+	}
+
+	// Restoring the user brings back only the posts deleted with them
+	if err := users.Restore(alice.ID); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if err := users.Restore(alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows restoring a user who is not deleted, got %v", err)
+	}
+	restored, _ := users.GetByID(alice.ID)
+	if restored == nil || restored.DeletedAt != nil {
+		t.Errorf("Expected the user to be restored, got %+v", restored)
+	}
+	remaining, _ := posts.GetByUserID(alice.ID)
+	if len(remaining) != 2 || remaining[0].ID != second.ID || remaining[1].ID != first.ID {
+		t.Errorf("Expected the two cascade-deleted posts back, got %+v", remaining)
+	}
+
+	if err := posts.Restore(removed.ID); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if n, _ := posts.Count(); n != 3 {
+		t.Errorf("Expected all 3 posts after restoring the last one, got %d", n)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db)
+	posts := NewPostRepository(db)
+
+	alice, _ := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	kept, _ := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Kept post"})
+	purged, _ := posts.Create(&models.CreatePostRequest{UserID: alice.ID, Title: "Purged post"})
+
+	if err := posts.Purge(purged.ID); err != nil {
+		t.Fatalf("Purge() failed: %v", err)
+	}
+	if _, err := posts.GetByIDIncludingDeleted(purged.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a purged post to be gone, got %v", err)
+	}
+
+	// Purging works on a soft deleted user and removes their posts
+	users.Delete(alice.ID)
+	if err := users.Purge(alice.ID); err != nil {
+		t.Fatalf("Purge() failed: %v", err)
+	}
+	if _, err := users.GetByIDIncludingDeleted(alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a purged user to be gone, got %v", err)
+	}
+	if _, err := posts.GetByIDIncludingDeleted(kept.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the purged user's posts to be gone, got %v", err)
+	}
+	if err := users.Purge(alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows purging twice, got %v", err)
+	}
+
+	// The email is free again once the user is purged
+	if _, err := users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Errorf("Expected to reuse a purged user's email, got %v", err)
+	}
+}
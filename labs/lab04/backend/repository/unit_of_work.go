@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories use, so the same
+// repository code runs inside or outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Default retry policy for transactions that find the database busy
+const (
+	DefaultMaxAttempts = 5
+	DefaultRetryDelay  = 20 * time.Millisecond
+)
+
+// UnitOfWork runs functions atomically against repositories bound to one
+// transaction
+type UnitOfWork struct {
+	db      *sql.DB
+	dialect database.Dialect
+
+	// MaxAttempts is how many times a transaction is tried while SQLite
+	// reports the database as busy or locked
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry. It doubles after each
+	// further attempt.
+	RetryDelay time.Duration
+}
+
+// Tx gives a unit of work's function the repositories bound to its
+// transaction
+type Tx struct {
+	Users *UserRepository
+	Posts *PostRepository
+
+	tx         *sql.Tx
+	ctx        context.Context
+	savepoints int // Savepoints created so far, to name the next one
+}
+
+// NewUnitOfWork creates a UnitOfWork with the default retry policy
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db:          db,
+		dialect:     database.DialectOf(db),
+		MaxAttempts: DefaultMaxAttempts,
+		RetryDelay:  DefaultRetryDelay,
+	}
+}
+
+// Do runs fn in a transaction and commits it if fn returns nil. The
+// transaction is rolled back if fn returns an error or panics; the panic is
+// then re-raised. The repositories run their queries with ctx, so
+// cancelling it or reaching its deadline stops them. Changes are audited as
+// made by the actor set on ctx with models.WithActor.
+//
+// If SQLite reports the database busy, the whole transaction is retried
+// with backoff, so fn may run more than once and must not have side effects
+// outside the database.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(tx *Tx) error) error {
+	delay := u.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := u.try(ctx, fn)
+		if err == nil || !isBusy(err) || attempt >= u.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// try runs fn in a single transaction
+func (u *UnitOfWork) try(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	actor := models.ActorFrom(ctx)
+	tx := &Tx{
+		Users: &UserRepository{db: sqlTx, dialect: u.dialect, actor: actor, ctx: ctx},
+		Posts: &PostRepository{db: sqlTx, dialect: u.dialect, actor: actor, ctx: ctx},
+		tx:    sqlTx,
+		ctx:   ctx,
+	}
+	if err := fn(tx); err != nil {
+		if rollbackErr := sqlTx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// Savepoint runs fn in a nested transaction. If fn returns an error or
+// panics, only its changes are rolled back and the error is returned to
+// the caller, which may carry on with the outer transaction.
+func (t *Tx) Savepoint(fn func(tx *Tx) error) (err error) {
+	t.savepoints++
+	name := fmt.Sprintf("sp_%d", t.savepoints)
+	if _, err := t.tx.ExecContext(t.ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	rollback := func() error {
+		if _, err := t.tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			return err
+		}
+		// Rolling back leaves the savepoint open, so release it too
+		_, err := t.tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+name)
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	_, err = t.tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// inTx runs fn in a transaction so a repository method made of several
+// statements is atomic. If db is already a transaction, such as a unit of
+// work's, fn joins it.
+func inTx(ctx context.Context, db DBTX, fn func(db DBTX) error) error {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// isBusy reports whether err is SQLite refusing a lock held by another
+// connection
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
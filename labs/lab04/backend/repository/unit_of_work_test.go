@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+)
+
+func TestUnitOfWork_CommitAndRollback(t *testing.T) {
+	db := openTestDB(t)
+	uow := NewUnitOfWork(db)
+	users := NewUserRepository(db)
+	posts := NewPostRepository(db)
+	ctx := context.Background()
+
+	err := uow.Do(ctx, func(tx *Tx) error {
+		user, err := tx.Users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "First post", Content: "Hello"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+	if n, _ := posts.Count(); n != 1 {
+		t.Errorf("Expected the committed post, got %d posts", n)
+	}
+
+	// The post fails validation, so the user must not be created either
+	err = uow.Do(ctx, func(tx *Tx) error {
+		user, err := tx.Users.Create(&models.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "No"})
+		return err
+	})
+	if !errors.Is(err, models.ErrTitleTooShort) {
+		t.Errorf("Expected the post's validation error, got %v", err)
+	}
+	if _, err := users.GetByEmail("bob@example.com"); err == nil {
+		t.Error("Expected the user to be rolled back with the post")
+	}
+}
+
+func TestUnitOfWork_RollbackOnPanic(t *testing.T) {
+	db := openTestDB(t)
+	uow := NewUnitOfWork(db)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("Expected the panic to be re-raised, got %v", p)
+			}
+		}()
+		uow.Do(context.Background(), func(tx *Tx) error {
+			tx.Users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+			panic("boom")
+		})
+	}()
+
+	if n, _ := NewUserRepository(db).Count(); n != 0 {
+		t.Errorf("Expected the panicking transaction to be rolled back, got %d users", n)
+	}
+	// The connection must have been returned to the pool in a usable state
+	if err := uow.Do(context.Background(), func(tx *Tx) error { return nil }); err != nil {
+		t.Errorf("Expected a new transaction after the panic, got %v", err)
+	}
+}
+
+func TestUnitOfWork_CancelledContext(t *testing.T) {
+	db := openTestDB(t)
+	uow := NewUnitOfWork(db)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := uow.Do(ctx, func(tx *Tx) error {
+		if _, err := tx.Users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		cancel()
+		_, err := tx.Users.Create(&models.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the query after cancelling to fail, got %v", err)
+	}
+	if n, _ := NewUserRepository(db).Count(); n != 0 {
+		t.Errorf("Expected the cancelled transaction to be rolled back, got %d users", n)
+	}
+
+	// Repositories outside a unit of work take a context the same way
+	if _, err := NewUserRepository(db).WithContext(ctx).Count(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the query, got %v", err)
+	}
+}
+
+func TestUnitOfWork_Savepoints(t *testing.T) {
+	db := openTestDB(t)
+	uow := NewUnitOfWork(db)
+	errSkip := errors.New("skip")
+
+	err := uow.Do(context.Background(), func(tx *Tx) error {
+		user, err := tx.Users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Savepoint(func(tx *Tx) error {
+			if _, err := tx.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Kept post"}); err != nil {
+				return err
+			}
+			// A failed inner savepoint only undoes its own work
+			err := tx.Savepoint(func(tx *Tx) error {
+				tx.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Dropped post"})
+				return errSkip
+			})
+			if !errors.Is(err, errSkip) {
+				t.Errorf("Expected the inner savepoint's error, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		func() {
+			defer func() { recover() }()
+			tx.Savepoint(func(tx *Tx) error {
+				tx.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Panicked post"})
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+
+	posts, err := NewPostRepository(db).GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() failed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Title != "Kept post" {
+		t.Errorf("Expected only the released savepoint's post, got %+v", posts)
+	}
+}
+
+func TestUnitOfWork_RetriesWhenBusy(t *testing.T) {
+	if os.Getenv(postgresURLEnv) != "" {
+		t.Skip("SQLITE_BUSY only applies to SQLite")
+	}
+
+	// Without a busy timeout SQLite reports a held lock straight away
+	path := filepath.Join(t.TempDir(), "busy.db")
+	config := database.DefaultConfig()
+	config.DatabasePath = path + "?_busy_timeout=0"
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer database.CloseDB(db)
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() failed: %v", err)
+	}
+
+	blocker, err := database.InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer database.CloseDB(blocker)
+	lock, err := blocker.Begin()
+	if err != nil {
+		t.Fatalf("Begin() failed: %v", err)
+	}
+	if _, err := lock.Exec(`INSERT INTO users (name, email) VALUES ('Lock', 'lock@example.com')`); err != nil {
+		t.Fatalf("Failed to take the write lock: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		lock.Commit()
+	}()
+
+	uow := NewUnitOfWork(db)
+	uow.MaxAttempts = 10
+	uow.RetryDelay = 10 * time.Millisecond
+	attempts := 0
+	err = uow.Do(context.Background(), func(tx *Tx) error {
+		attempts++
+		_, err := tx.Users.Create(&models.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Expected Do() to succeed once the lock is released, got %v", err)
+	}
+	if attempts < 2 {
+		t.Errorf("Expected a retry while the database was busy, got %d attempts", attempts)
+	}
+
+	// Giving up reports the busy error
+	lock, _ = blocker.Begin()
+	lock.Exec(`INSERT INTO users (name, email) VALUES ('Lock2', 'lock2@example.com')`)
+	defer lock.Rollback()
+	uow.MaxAttempts = 2
+	err = uow.Do(context.Background(), func(tx *Tx) error {
+		_, err := tx.Users.Create(&models.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+		return err
+	})
+	if !isBusy(err) {
+		t.Errorf("Expected a busy error after the last attempt, got %v", err)
+	}
+}
@@ -1,82 +1,299 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"strings"
+	"time"
 
+	"lab04-backend/database"
 	"lab04-backend/models"
 )
 
 // UserRepository handles database operations for users
 // This repository demonstrates MANUAL SQL approach with database/sql package
 type UserRepository struct {
-	db *sql.DB
+	db      DBTX
+	dialect database.Dialect
+	actor   string          // Recorded in the audit log for changes
+	ctx     context.Context // Governs every query; see WithContext
 }
 
 // NewUserRepository creates a new UserRepository
 func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+	return &UserRepository{db: db, dialect: database.DialectOf(db), ctx: context.Background()}
 }
 
-// TODO: Implement Create method
+// WithActor returns a copy of the repository that records actor in the
+// audit log as the author of its changes
+func (r *UserRepository) WithActor(actor string) *UserRepository {
+	repo := *r
+	repo.actor = actor
+	return &repo
+}
+
+// WithContext returns a copy of the repository whose queries stop when ctx
+// is cancelled or times out. Repositories of a unit of work already use
+// the context given to UnitOfWork.Do.
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	repo := *r
+	repo.ctx = ctx
+	return &repo
+}
+
+// withDB returns a copy of the repository running on db, such as a
+// transaction
+func (r *UserRepository) withDB(db DBTX) *UserRepository {
+	repo := *r
+	repo.db = db
+	return &repo
+}
+
+// Create validates and inserts a new user
 func (r *UserRepository) Create(req *models.CreateUserRequest) (*models.User, error) {
-	// TODO: Create a new user in the database
-	// - Validate the request
-	// - Insert into users table
-	// - Return the created user with ID and timestamps
-	// Use RETURNING clause to get the generated ID and timestamps
-	return nil, fmt.Errorf("TODO: implement Create method")
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Timestamps come from Go rather than CURRENT_TIMESTAMP, which only has
+	// second precision
+	user := req.ToUser()
+	err := inTx(r.ctx, r.db, func(db DBTX) error {
+		row := db.QueryRowContext(r.ctx, r.dialect.Rebind(
+			`INSERT INTO users (name, email, created_at, updated_at) VALUES (?, ?, ?, ?)
+			RETURNING `+models.UserColumns),
+			user.Name, user.Email, user.CreatedAt, user.UpdatedAt,
+		)
+		if err := user.ScanRow(row); err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditCreate, "users", user.ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-// TODO: Implement GetByID method
+// GetByID returns a user, or sql.ErrNoRows if there is none or they are
+// deleted
 func (r *UserRepository) GetByID(id int) (*models.User, error) {
-	// TODO: Get user by ID from database
-	// - Query users table by ID
-	// - Return user or sql.ErrNoRows if not found
-	// - Handle scanning properly
-	return nil, fmt.Errorf("TODO: implement GetByID method")
+	return r.getUser(`WHERE id = ? AND deleted_at IS NULL`, id)
 }
 
-// TODO: Implement GetByEmail method
+// GetByIDIncludingDeleted returns a user even if they are soft deleted, or
+// sql.ErrNoRows if there is none
+func (r *UserRepository) GetByIDIncludingDeleted(id int) (*models.User, error) {
+	return r.getUser(`WHERE id = ?`, id)
+}
+
+// GetByEmail returns a user, or sql.ErrNoRows if there is none or they are
+// deleted
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	// TODO: Get user by email from database
-	// - Query users table by email
-	// - Return user or sql.ErrNoRows if not found
-	// - Handle scanning properly
-	return nil, fmt.Errorf("TODO: implement GetByEmail method")
+	return r.getUser(`WHERE email = ? AND deleted_at IS NULL`, email)
+}
+
+func (r *UserRepository) getUser(where string, args ...interface{}) (*models.User, error) {
+	var user models.User
+	row := r.db.QueryRowContext(r.ctx, r.dialect.Rebind(`SELECT `+models.UserColumns+` FROM users `+where), args...)
+	if err := user.ScanRow(row); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-// TODO: Implement GetAll method
+// GetAll returns every user that is not deleted, oldest first
 func (r *UserRepository) GetAll() ([]models.User, error) {
-	// TODO: Get all users from database
-	// - Query all users ordered by created_at
-	// - Return slice of users
-	// - Handle empty result properly
-	return nil, fmt.Errorf("TODO: implement GetAll method")
+	rows, err := r.db.QueryContext(r.ctx, `SELECT `+models.UserColumns+` FROM users WHERE deleted_at IS NULL ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	return models.ScanUsers(rows)
 }
 
-// TODO: Implement Update method
+// Update changes the fields set in req and returns the updated user, or
+// sql.ErrNoRows if there is none or they are deleted
 func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.User, error) {
-	// TODO: Update user in database
-	// - Build dynamic UPDATE query based on non-nil fields in req
-	// - Update updated_at timestamp
-	// - Return updated user
-	// - Handle case where user doesn't exist
-	return nil, fmt.Errorf("TODO: implement Update method")
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []interface{}
+	if req.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *req.Name)
+	}
+	if req.Email != nil {
+		sets = append(sets, "email = ?")
+		args = append(args, *req.Email)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now().UTC(), id)
+
+	var user models.User
+	err := inTx(r.ctx, r.db, func(db DBTX) error {
+		before, err := r.withDB(db).getUser(`WHERE id = ? AND deleted_at IS NULL`+forUpdate(r.dialect), id)
+		if err != nil {
+			return err
+		}
+		row := db.QueryRowContext(r.ctx, r.dialect.Rebind(
+			`UPDATE users SET `+strings.Join(sets, ", ")+` WHERE id = ? AND deleted_at IS NULL RETURNING `+models.UserColumns),
+			args...,
+		)
+		if err := user.ScanRow(row); err != nil {
+			return err
+		}
+		return recordChange(r.ctx, db, r.dialect, r.actor, models.AuditUpdate, "users", id, before, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-// TODO: Implement Delete method
+// Delete soft deletes a user along with their posts, which are stamped
+// with the same time so Restore can tell them from posts deleted earlier.
+// It returns sql.ErrNoRows if there is no such user or they are already
+// deleted. A deleted user's email stays taken until they are purged.
 func (r *UserRepository) Delete(id int) error {
-	// TODO: Delete user from database
-	// - Delete from users table by ID
-	// - Return error if user doesn't exist
-	// - Consider cascading deletes for posts
-	return fmt.Errorf("TODO: implement Delete method")
+	now := time.Now().UTC()
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		result, err := db.ExecContext(r.ctx, r.dialect.Rebind(`UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`), now, id)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result); err != nil {
+			return err
+		}
+		if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditDelete, "users", id, deletedAt{nil}, deletedAt{now}); err != nil {
+			return err
+		}
+
+		postIDs, err := queryIDs(r.ctx, db, r.dialect.Rebind(
+			`UPDATE posts SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL RETURNING id`),
+			now, id,
+		)
+		if err != nil {
+			return err
+		}
+		for _, postID := range postIDs {
+			if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditDelete, "posts", postID, deletedAt{nil}, deletedAt{now}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// TODO: Implement Count method
+// Restore undeletes a user and the posts deleted along with them. Posts
+// deleted on their own before the user stay deleted. It returns
+// sql.ErrNoRows if there is no such deleted user.
+func (r *UserRepository) Restore(id int) error {
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		var deleted time.Time
+		err := db.QueryRowContext(r.ctx, r.dialect.Rebind(
+			`SELECT deleted_at FROM users WHERE id = ? AND deleted_at IS NOT NULL`+forUpdate(r.dialect)), id,
+		).Scan(&deleted)
+		if err != nil {
+			return err
+		}
+
+		// Posts first, while the user's deleted_at still identifies them
+		postIDs, err := queryIDs(r.ctx, db, r.dialect.Rebind(
+			`UPDATE posts SET deleted_at = NULL
+			WHERE user_id = ? AND deleted_at = (SELECT deleted_at FROM users WHERE id = ?)
+			RETURNING id`),
+			id, id,
+		)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(r.ctx, r.dialect.Rebind(`UPDATE users SET deleted_at = NULL WHERE id = ?`), id); err != nil {
+			return err
+		}
+
+		if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditRestore, "users", id, deletedAt{deleted}, deletedAt{nil}); err != nil {
+			return err
+		}
+		for _, postID := range postIDs {
+			if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditRestore, "posts", postID, deletedAt{deleted}, deletedAt{nil}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Purge permanently removes a user, deleted or not, and through the foreign
+// key their posts. It returns sql.ErrNoRows if there is no such user. The
+// audit log keeps their last values.
+func (r *UserRepository) Purge(id int) error {
+	return inTx(r.ctx, r.db, func(db DBTX) error {
+		user, err := r.withDB(db).getUser(`WHERE id = ?`+forUpdate(r.dialect), id)
+		if err != nil {
+			return err
+		}
+		rows, err := db.QueryContext(r.ctx, r.dialect.Rebind(`SELECT `+models.PostColumns+` FROM posts WHERE user_id = ? ORDER BY id`), id)
+		if err != nil {
+			return err
+		}
+		posts, err := models.ScanPosts(rows)
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.ExecContext(r.ctx, r.dialect.Rebind(`DELETE FROM users WHERE id = ?`), id); err != nil {
+			return err
+		}
+		if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditPurge, "users", id, user, nil); err != nil {
+			return err
+		}
+		for i := range posts {
+			if err := recordChange(r.ctx, db, r.dialect, r.actor, models.AuditPurge, "posts", posts[i].ID, &posts[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Count returns the number of users that are not deleted
 func (r *UserRepository) Count() (int, error) {
-	// TODO: Count total number of users
-	// - Return count of users in database
-	return 0, fmt.Errorf("TODO: implement Count method")
+	var count int
+	err := r.db.QueryRowContext(r.ctx, `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`).Scan(&count)
+	return count, err
+}
+
+// queryIDs runs a statement that returns ids, such as an UPDATE with
+// RETURNING id, and reads them all so the connection is free for the next
+// statement
+func queryIDs(ctx context.Context, db DBTX, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// expectAffected turns a statement that matched no rows into sql.ErrNoRows
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
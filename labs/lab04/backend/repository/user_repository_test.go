@@ -1,43 +1,85 @@
 package repository
 
 import (
+	"database/sql"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"lab04-backend/database"
 	"lab04-backend/models"
 )
 
-func setupTestDB(t *testing.T) (*UserRepository, func()) {
-	// Create test database
-	testDB := "./test_user_repo.db"
+// postgresURLEnv names the environment variable holding a Postgres
+// connection URL. When it is set the repository tests run against Postgres,
+// each in a schema of its own, instead of a SQLite file.
+const postgresURLEnv = "LAB04_TEST_POSTGRES_URL"
+
+// openTestDB returns a migrated database that is removed when the test ends
+func openTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+
 	config := &database.Config{
-		DatabasePath:    testDB,
+		Dialect:         database.SQLite,
+		DatabasePath:    filepath.Join(t.TempDir(), "test_repo.db"),
 		MaxOpenConns:    5,
 		MaxIdleConns:    1,
 		ConnMaxLifetime: 0,
 		ConnMaxIdleTime: 0,
 	}
+	if pgURL := os.Getenv(postgresURLEnv); pgURL != "" {
+		config.Dialect = database.Postgres
+		config.DatabasePath = postgresTestSchema(t, pgURL)
+	}
 
 	db, err := database.InitDBWithConfig(config)
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
+	t.Cleanup(func() { database.CloseDB(db) })
 
-	err = database.RunMigrations(db)
-	if err != nil {
+	if err := database.RunMigrations(db); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
+	return db
+}
 
-	repo := NewUserRepository(db)
+// postgresTestSchema creates a schema for one test and returns a connection
+// URL whose search_path selects it. The schema is dropped on cleanup.
+func postgresTestSchema(t testing.TB, rawURL string) string {
+	t.Helper()
 
-	// Return cleanup function
-	cleanup := func() {
-		database.CloseDB(db)
-		os.Remove(testDB)
+	admin, err := sql.Open("pgx", rawURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
 	}
+	schema := fmt.Sprintf("lab04_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Invalid %s: %v", postgresURLEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
 
-	return repo, cleanup
+func setupTestDB(t *testing.T) (*UserRepository, func()) {
+	db := openTestDB(t)
+	// Closing is registered with t.Cleanup; the function is kept for callers
+	return NewUserRepository(db), func() {}
 }
 
 func TestUserRepository_Create(t *testing.T) {